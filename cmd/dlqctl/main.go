@@ -0,0 +1,156 @@
+// dlqctl 死信队列运维工具：查看、重放与清理 chat.messages.dlq 中的消息
+//
+// 用法:
+//
+//	dlqctl [flags] list|depth|replay|purge
+//
+// Broker 地址默认读取环境变量 KAFKA_BROKERS
+package main
+
+import (
+	"coca-ai/internal/mq"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	brokers := flag.String("brokers", os.Getenv("KAFKA_BROKERS"), "Kafka Broker 地址，逗号分隔")
+	topic := flag.String("topic", mq.TopicChatMessagesDLQ, "死信 Topic")
+	target := flag.String("target", mq.TopicChatMessages, "重放的目标 Topic")
+	group := flag.String("group", "", "记录处理进度的消费者组，默认 coca-dlqctl")
+	sessionID := flag.Int64("session", 0, "按会话 ID 筛选")
+	errContains := flag.String("error", "", "按失败原因筛选 (包含，忽略大小写)")
+	positions := flag.String("offsets", "", "按位置筛选，格式 partition:offset，逗号分隔")
+	all := flag.Bool("all", false, "包含已确认的消息")
+	limit := flag.Int("limit", 0, "最多处理条数，0 表示不限制")
+	asJSON := flag.Bool("json", false, "list 以 JSON 输出")
+	purge := flag.Bool("purge", false, "replay 后将全部死信消息标记为已确认，带筛选条件重放时必须设置")
+	yes := flag.Bool("yes", false, "确认执行 purge 或 replay -purge")
+	timeout := flag.Duration("timeout", 5*time.Minute, "执行超时时间")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] list|depth|replay|purge\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if strings.TrimSpace(*brokers) == "" {
+		fatalf("brokers is required (-brokers or KAFKA_BROKERS)")
+	}
+
+	filter := mq.DLQFilter{
+		SessionID:     *sessionID,
+		ErrorContains: *errContains,
+		IncludeAcked:  *all,
+		Limit:         *limit,
+	}
+	for _, raw := range strings.Split(*positions, ",") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		pos, err := mq.ParseDLQPosition(raw)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		filter.Positions = append(filter.Positions, pos)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	l := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	dlq := mq.NewDLQ(&mq.DLQConfig{
+		Brokers:     splitCSV(*brokers),
+		Topic:       *topic,
+		TargetTopic: *target,
+		GroupID:     *group,
+	}, l)
+	defer dlq.Close()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "list":
+		entries, err := dlq.List(ctx, filter)
+		if err != nil {
+			fatalf("list failed: %v", err)
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(entries)
+			return
+		}
+		printEntries(entries)
+	case "depth":
+		depth, err := dlq.Depth(ctx)
+		if err != nil {
+			fatalf("depth failed: %v", err)
+		}
+		fmt.Println(depth)
+	case "replay":
+		if *purge && !*yes {
+			fatalf("replay -purge marks all dead letters as handled, rerun with -yes to confirm")
+		}
+		replayed, err := dlq.Replay(ctx, filter, *purge)
+		if errors.Is(err, mq.ErrDLQReplayNotPurged) {
+			fatalf("%v (-purge -yes)", err)
+		}
+		if err != nil {
+			fatalf("replay failed after %d messages: %v", replayed, err)
+		}
+		fmt.Printf("replayed %d messages to %s\n", replayed, *target)
+	case "purge":
+		if !*yes {
+			fatalf("purge marks all dead letters as handled, rerun with -yes to confirm")
+		}
+		purged, err := dlq.Purge(ctx)
+		if err != nil {
+			fatalf("purge failed: %v", err)
+		}
+		fmt.Printf("purged %d messages\n", purged)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// printEntries 以表格输出死信消息
+func printEntries(entries []mq.DLQEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POSITION\tTIME\tSESSION\tMESSAGE\tROLE\tACKED\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%t\t%s\n",
+			e.DLQPosition, e.Time.Format(time.RFC3339), e.SessionID, e.MessageID, e.Role, e.Acked, e.Error)
+	}
+	_ = w.Flush()
+}
+
+func splitCSV(value string) []string {
+	parts := strings.Split(value, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "dlqctl: "+format+"\n", args...)
+	os.Exit(1)
+}
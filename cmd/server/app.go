@@ -1,27 +1,226 @@
 package main
 
 import (
-	"context"
+	"coca-ai/internal/config"
+	"coca-ai/internal/handler"
+	"coca-ai/internal/ioc"
 	"coca-ai/internal/mq"
+	"coca-ai/internal/service"
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
+// defaultShutdownTimeout 停机时等待请求与生成结束的默认时长
+const defaultShutdownTimeout = 60 * time.Second
+
 type App struct {
-	Engine   *gin.Engine
-	Consumer *mq.Consumer
+	Engine          *gin.Engine
+	Consumer        *mq.Consumer
+	SummaryConsumer *mq.SummaryConsumer
+	OutboxRelay     *mq.OutboxRelay
+	DLQ             *mq.DLQ
+	Producer        *mq.Producer
+	Streams         *service.StreamService
+	Generations     *service.GenerationManager
+	Events          *service.SessionEventBus
+	WS              *handler.WSHandler
+	NodeLease       *ioc.NodeLease
+	Redis           redis.Cmdable
+	DB              *gorm.DB
+	// ShutdownTracing 在其他组件关闭后上报剩余的 Span
+	ShutdownTracing ioc.TracerShutdown
+
+	shutdownTimeout time.Duration
+	l               *slog.Logger
 }
 
-func NewApp(engine *gin.Engine, consumer *mq.Consumer) *App {
+func NewApp(engine *gin.Engine, consumer *mq.Consumer, summaryConsumer *mq.SummaryConsumer, outboxRelay *mq.OutboxRelay, dlq *mq.DLQ,
+	producer *mq.Producer, streams *service.StreamService, generations *service.GenerationManager, events *service.SessionEventBus,
+	ws *handler.WSHandler, nodeLease *ioc.NodeLease, redisClient redis.Cmdable, db *gorm.DB, l *slog.Logger) *App {
+	timeout := defaultShutdownTimeout
+	if s := config.Get().Server.ShutdownTimeoutS; s > 0 {
+		timeout = time.Duration(s) * time.Second
+	}
 	return &App{
-		Engine:   engine,
-		Consumer: consumer,
+		Engine:          engine,
+		Consumer:        consumer,
+		SummaryConsumer: summaryConsumer,
+		OutboxRelay:     outboxRelay,
+		DLQ:             dlq,
+		Producer:        producer,
+		Streams:         streams,
+		Generations:     generations,
+		Events:          events,
+		WS:              ws,
+		NodeLease:       nodeLease,
+		Redis:           redisClient,
+		DB:              db,
+		shutdownTimeout: timeout,
+		l:               l.With("component", "app"),
 	}
 }
 
+// Run 启动 HTTP 服务与后台任务，收到 SIGINT/SIGTERM 后优雅停机
+// 停机顺序：停止接收请求并等待进行中的请求 (WebSocket 连接通知客户端后关闭) → 等待后台生成结束 → 停止消费者与后台任务 →
+// 关闭 Kafka 客户端 → 上报剩余 Span → 关闭 Redis 与 MySQL
 func (a *App) Run(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 后台任务使用独立的 context，在 HTTP 请求处理完后才停止
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	a.startWorkers(workerCtx, &workers)
+
+	server := &http.Server{Addr: addr, Handler: a.Engine}
+	if a.WS != nil {
+		// 被接管的 WebSocket 连接不受 Shutdown 管理，需单独关闭；其生成计入 Streams 一并等待
+		server.RegisterOnShutdown(a.WS.Shutdown)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		a.l.Info("http server listening", "addr", addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.l.Info("shutdown signal received, draining", "timeout", a.shutdownTimeout)
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			runErr = err
+		}
+	}
+	// 再次收到信号时按默认行为立即退出
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		a.l.Error("http server shutdown failed", logger.Error(err))
+	}
+	if a.Streams != nil {
+		if err := a.Streams.Wait(shutdownCtx); err != nil {
+			a.l.Warn("generations still running at shutdown", logger.Error(err))
+		}
+	}
+
+	stopWorkers()
+	if err := waitGroup(shutdownCtx, &workers); err != nil {
+		a.l.Warn("background workers did not stop in time", logger.Error(err))
+	}
+
+	a.close(shutdownCtx)
+	a.l.Info("shutdown complete")
+	return runErr
+}
+
+// startWorkers 启动消费者、跨实例订阅与后台任务
+func (a *App) startWorkers(ctx context.Context, workers *sync.WaitGroup) {
+	run := func(name string, start func(ctx context.Context) error) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := start(ctx); err != nil && ctx.Err() == nil {
+				a.l.Error("background worker stopped with error", logger.Error(err), "worker", name)
+			}
+		}()
+	}
+
+	if a.Consumer != nil {
+		run("message_consumer", a.Consumer.Start)
+	}
+	// 接收其他实例发出的停止请求与会话事件
+	if a.Generations != nil {
+		run("generation_stop_subscriber", func(ctx context.Context) error {
+			a.Generations.Subscribe(ctx)
+			return nil
+		})
+	}
+	if a.Events != nil {
+		run("session_event_bus", func(ctx context.Context) error {
+			a.Events.Run(ctx)
+			return nil
+		})
+	}
+	if a.SummaryConsumer != nil {
+		run("summary_consumer", a.SummaryConsumer.Start)
+	}
+	if a.OutboxRelay != nil {
+		run("outbox_relay", a.OutboxRelay.Start)
+	}
+	if a.DLQ != nil {
+		a.DLQ.StartDepthMetric(ctx, time.Minute)
+	}
+	if a.NodeLease != nil {
+		run("node_lease", a.NodeLease.Keep)
+	}
+}
+
+// close 按依赖顺序关闭各组件
+func (a *App) close(ctx context.Context) {
+	closeWith := func(name string, closer io.Closer) {
+		if err := closer.Close(); err != nil {
+			a.l.Error("close failed", logger.Error(err), "resource", name)
+		}
+	}
+
+	// 消费者 (含死信写入) 与生产者，生产者关闭时发送缓冲中的消息
 	if a.Consumer != nil {
-		a.Consumer.StartAsync(context.Background())
+		closeWith("message_consumer", a.Consumer)
+	}
+	if a.SummaryConsumer != nil {
+		closeWith("summary_consumer", a.SummaryConsumer)
+	}
+	if a.DLQ != nil {
+		closeWith("dlq", a.DLQ)
+	}
+	if a.Producer != nil {
+		closeWith("kafka_producer", a.Producer)
+	}
+
+	if a.ShutdownTracing != nil {
+		if err := a.ShutdownTracing(ctx); err != nil {
+			a.l.Error("shutdown tracing failed", logger.Error(err))
+		}
+	}
+
+	if closer, ok := a.Redis.(io.Closer); ok {
+		closeWith("redis", closer)
+	}
+	if a.DB != nil {
+		if sqlDB, err := a.DB.DB(); err == nil {
+			closeWith("mysql", sqlDB)
+		}
+	}
+}
+
+// waitGroup 等待 wg 结束，ctx 结束时返回其错误
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return a.Engine.Run(addr)
 }
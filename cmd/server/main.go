@@ -6,8 +6,9 @@ import (
 )
 
 func main() {
-	ioc.InitJaeger()
+	shutdownTracing := ioc.InitJaeger()
 	app := InitApp()
+	app.ShutdownTracing = shutdownTracing
 	if err := app.Run(":8080"); err != nil {
 		log.Fatalf("server stopped: %v", err)
 	}
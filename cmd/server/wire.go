@@ -19,16 +19,28 @@ import (
 func InitApp() *App {
 	wire.Build(
 		// 基础组件
+		ioc.InitLogger,
 		ioc.InitDB,
 		ioc.InitRedis,
 		ioc.InitWebServer,
 		// LLM 客户端
+		ioc.InitResponseCache,
 		ioc.InitLLMClient,
+		ioc.InitToolRegistry,
+		ioc.InitNodeLease,
+		ioc.InitIDGenerator,
 		// Kafka
 		ioc.InitKafkaProducer,
 		ioc.InitKafkaConsumer,
 		mq.NewMessagePersistHandler,
 		ioc.BindKafkaHandlers,
+		dao.NewOutboxDAO,
+		mq.NewMessagePublisher,
+		ioc.InitOutboxRelay,
+		ioc.InitDLQ,
+		handler.NewAdminHandler,
+		ioc.InitSummaryConsumer,
+		ioc.BindSummaryHandler,
 		// User 模块
 		dao.NewUserDAO,
 		repository.NewUserRepository,
@@ -36,16 +48,51 @@ func InitApp() *App {
 		jwtx.NewJWTHandler,
 		handler.NewUserHandler,
 		middleware.NewLoginJWTMiddleware,
+		ioc.InitRateLimiter,
+		middleware.NewRateLimitMiddleware,
+		// API Key 模块
+		dao.NewAPIKeyDAO,
+		repository.NewAPIKeyRepository,
+		service.NewAPIKeyService,
+		handler.NewAPIKeyHandler,
+		middleware.NewAPIKeyMiddleware,
 		handler.NewPingHandler,
+		// 用量与配额
+		dao.NewUsageDAO,
+		repository.NewUsageRepository,
+		service.NewUsageService,
+		handler.NewUsageHandler,
 		// Chat 模块
 		dao.NewSessionDAO,
 		dao.NewMessageDAO,
 		ioc.InitMessageCache,
 		repository.NewSessionRepository,
+		wire.Bind(new(service.SessionStore), new(*repository.SessionRepository)),
 		repository.NewMessageRepository,
+		dao.NewSummaryDAO,
+		ioc.InitSummaryCache,
+		repository.NewSummaryRepository,
+		service.NewSummaryService,
 		service.NewContextService,
+		service.NewGenerationManager,
+		service.NewSessionEventBus,
 		service.NewChatService,
+		ioc.InitGenerationCache,
+		repository.NewGenerationRepository,
+		service.NewStreamService,
 		handler.NewChatHandler,
+		handler.NewWSHandler,
+		service.NewCompletionService,
+		handler.NewOpenAIHandler,
+		// Document 模块 (RAG)
+		dao.NewDocumentDAO,
+		dao.NewDocumentChunkDAO,
+		repository.NewDocumentRepository,
+		ioc.InitEmbedder,
+		ioc.InitVectorStore,
+		ioc.InitChunker,
+		service.NewDocumentService,
+		handler.NewDocumentHandler,
 		NewApp,
 	)
 	return nil
@@ -21,7 +21,10 @@ import (
 
 func InitApp() *App {
 	pingHandler := handler.NewPingHandler()
-	db := ioc.InitDB()
+	logger := ioc.InitLogger()
+	dlq := ioc.InitDLQ(logger)
+	adminHandler := handler.NewAdminHandler(dlq)
+	db := ioc.InitDB(logger)
 	userDAO := dao.NewUserDAO(db)
 	cmdable := ioc.InitRedis()
 	userRepository := repository.NewUserRepository(userDAO, cmdable)
@@ -33,16 +36,56 @@ func InitApp() *App {
 	messageDAO := dao.NewMessageDAO(db)
 	messageCache := ioc.InitMessageCache(cmdable)
 	messageRepository := repository.NewMessageRepository(messageDAO, messageCache)
-	consumer := ioc.InitKafkaConsumer()
-	messagePersistHandler := mq.NewMessagePersistHandler(messageDAO)
+	consumer := ioc.InitKafkaConsumer(logger)
+	nodeLease := ioc.InitNodeLease(cmdable, logger)
+	node := ioc.InitIDGenerator(nodeLease)
+	messagePersistHandler := mq.NewMessagePersistHandler(messageDAO, node, logger)
 	consumer = ioc.BindKafkaHandlers(consumer, messagePersistHandler)
-	chatClient := ioc.InitLLMClient()
-	producer := ioc.InitKafkaProducer()
-	contextService := service.NewContextService(messageRepository, chatClient)
-	chatService := service.NewChatService(sessionRepository, messageRepository, chatClient, producer, contextService)
-	chatHandler := handler.NewChatHandler(chatService)
+	responseCache := ioc.InitResponseCache(cmdable)
+	chatClient := ioc.InitLLMClient(responseCache, logger)
+	producer := ioc.InitKafkaProducer(logger)
+	documentDAO := dao.NewDocumentDAO(db)
+	documentRepository := repository.NewDocumentRepository(documentDAO)
+	embedder := ioc.InitEmbedder()
+	documentChunkDAO := dao.NewDocumentChunkDAO(db)
+	vectorStore := ioc.InitVectorStore(documentChunkDAO)
+	chunker := ioc.InitChunker()
+	documentService := service.NewDocumentService(documentRepository, embedder, vectorStore, chunker, logger)
+	summaryDAO := dao.NewSummaryDAO(db)
+	summaryCache := ioc.InitSummaryCache(cmdable)
+	summaryRepository := repository.NewSummaryRepository(summaryDAO, summaryCache, logger)
+	summaryService := service.NewSummaryService(summaryRepository, messageRepository, chatClient, producer, logger)
+	contextService := service.NewContextService(messageRepository, chatClient, documentService, summaryService, logger)
+	registry := ioc.InitToolRegistry()
+	generationManager := service.NewGenerationManager(cmdable, logger)
+	sessionEventBus := service.NewSessionEventBus(cmdable, logger)
+	usageDAO := dao.NewUsageDAO(db)
+	usageRepository := repository.NewUsageRepository(usageDAO)
+	usageService := service.NewUsageService(usageRepository, logger)
+	outboxDAO := dao.NewOutboxDAO(db)
+	messagePublisher := mq.NewMessagePublisher(producer, outboxDAO, messagePersistHandler, logger)
+	chatService := service.NewChatService(sessionRepository, messageRepository, chatClient, messagePublisher, contextService, registry, summaryService, node, generationManager, sessionEventBus, usageService, logger)
+	generationCache := ioc.InitGenerationCache(cmdable)
+	generationRepository := repository.NewGenerationRepository(generationCache)
+	streamService := service.NewStreamService(generationRepository, node, logger)
+	chatHandler := handler.NewChatHandler(chatService, streamService, logger)
+	limiter := ioc.InitRateLimiter(cmdable)
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(limiter)
+	wsHandler := handler.NewWSHandler(chatService, streamService, sessionEventBus, rateLimitMiddleware, logger)
+	completionService := service.NewCompletionService(chatClient, chatService, usageService, logger)
+	openAIHandler := handler.NewOpenAIHandler(completionService)
+	documentHandler := handler.NewDocumentHandler(documentService)
 	loginJWTMiddleware := middleware.NewLoginJWTMiddleware(jwtHandler, cmdable)
-	engine := ioc.InitWebServer(pingHandler, userHandler, chatHandler, loginJWTMiddleware)
-	app := NewApp(engine, consumer)
+	apiKeyDAO := dao.NewAPIKeyDAO(db)
+	apiKeyRepository := repository.NewAPIKeyRepository(apiKeyDAO)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepository, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	usageHandler := handler.NewUsageHandler(usageService)
+	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(apiKeyService, loginJWTMiddleware)
+	engine := ioc.InitWebServer(pingHandler, adminHandler, userHandler, chatHandler, wsHandler, openAIHandler, documentHandler, apiKeyHandler, usageHandler, loginJWTMiddleware, apiKeyMiddleware, rateLimitMiddleware, logger)
+	summaryConsumer := ioc.InitSummaryConsumer(logger)
+	summaryConsumer = ioc.BindSummaryHandler(summaryConsumer, summaryService)
+	outboxRelay := ioc.InitOutboxRelay(producer, outboxDAO, logger)
+	app := NewApp(engine, consumer, summaryConsumer, outboxRelay, dlq, producer, streamService, generationManager, sessionEventBus, wsHandler, nodeLease, cmdable, db, logger)
 	return app
 }
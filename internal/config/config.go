@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -16,11 +17,25 @@ type Config struct {
 	Kafka  KafkaConfig  `mapstructure:"kafka"`
 	Jaeger JaegerConfig `mapstructure:"jaeger"`
 	Logger LoggerConfig `mapstructure:"logger"`
+	RAG    RAGConfig    `mapstructure:"rag"`
+
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
 	Addr string `mapstructure:"addr"`
+	// NodeID ID 生成节点号 (0-31)，启动时在 Redis 中租用，被其他实例占用时启动失败；
+	// 不配置时自动租用空闲的节点号
+	NodeID *int64 `mapstructure:"node_id"`
+	// AdminToken 管理接口 (/admin) 的访问令牌，为空时不开放管理接口
+	AdminToken string `mapstructure:"admin_token"`
+	// ShutdownTimeoutS 停机时等待请求与生成结束的最长时间 (秒)，默认 60
+	ShutdownTimeoutS int `mapstructure:"shutdown_timeout_s"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，仅来自这些地址的 X-Forwarded-For 用于识别客户端 IP；
+	// 为空时不信任任何代理，直接使用连接的对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 // MySQLConfig MySQL 配置
@@ -44,15 +59,107 @@ type RedisConfig struct {
 
 // LLMConfig LLM 配置
 type LLMConfig struct {
-	Provider string `mapstructure:"provider"` // qwen, openai
+	Provider string `mapstructure:"provider"` // 当前使用的 Provider 名称: qwen, openai, deepseek, ollama, fake
 	BaseURL  string `mapstructure:"base_url"`
 	Model    string `mapstructure:"model"`
 	APIKey   string `mapstructure:"api_key"`
+	// Providers 按名称配置的多个 Provider，仅 qwen 未配置的字段回落到上面的全局字段
+	Providers map[string]LLMProviderConfig `mapstructure:"providers"`
+	Embedding EmbeddingConfig              `mapstructure:"embedding"`
+	// ContextWindow 未知模型的上下文窗口 (token)，已知模型使用内置值
+	ContextWindow int `mapstructure:"context_window"`
+	// ContextWindows 按模型名覆盖上下文窗口
+	ContextWindows map[string]int `mapstructure:"context_windows"`
+	// ReservedOutput 为模型输出预留的 token 数，会话设置了 max_tokens 时以其为准
+	ReservedOutput int `mapstructure:"reserved_output"`
+	// Fallbacks 主 Provider 不可用时依次尝试的备用 Provider 与模型
+	Fallbacks      []LLMFallbackConfig     `mapstructure:"fallbacks"`
+	Retry          LLMRetryConfig          `mapstructure:"retry"`
+	CircuitBreaker LLMCircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	ResponseCache  LLMResponseCacheConfig  `mapstructure:"response_cache"`
+}
+
+// LLMFallbackConfig 备用 Provider 配置
+type LLMFallbackConfig struct {
+	Provider string `mapstructure:"provider"` // llm.providers 中的名称
+	Model    string `mapstructure:"model"`    // 为空时使用该 Provider 的默认模型
+}
+
+// LLMRetryConfig LLM 调用重试配置 (仅在输出第一个 token 前重试)
+type LLMRetryConfig struct {
+	MaxRetries   int `mapstructure:"max_retries"` // 负数表示不重试
+	BackoffMS    int `mapstructure:"backoff_ms"`
+	MaxBackoffMS int `mapstructure:"max_backoff_ms"`
+}
+
+// LLMCircuitBreakerConfig Provider 熔断配置
+type LLMCircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"` // 连续失败次数，负数表示不熔断
+	CooldownSeconds  int `mapstructure:"cooldown_seconds"`
+}
+
+// LLMResponseCacheConfig 模型回复缓存配置 (默认关闭)
+// 新会话中的重复提问直接回放缓存的回复，不调用模型
+type LLMResponseCacheConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	TTLSeconds       int  `mapstructure:"ttl_seconds"`
+	MaxMessages      int  `mapstructure:"max_messages"`       // 参与缓存的对话最多包含的消息数 (不含 system)
+	ReplayIntervalMS int  `mapstructure:"replay_interval_ms"` // 回放分片间隔，负数表示不等待
+}
+
+// LLMProviderConfig 单个 LLM Provider 配置
+type LLMProviderConfig struct {
+	Type    string   `mapstructure:"type"` // Provider 驱动类型，为空时与名称相同
+	BaseURL string   `mapstructure:"base_url"`
+	APIKey  string   `mapstructure:"api_key"`
+	Model   string   `mapstructure:"model"`  // 默认模型
+	Models  []string `mapstructure:"models"` // 可用模型列表
+}
+
+// EmbeddingConfig 向量化模型配置
+type EmbeddingConfig struct {
+	Provider   string `mapstructure:"provider"` // hash (离线), openai
+	BaseURL    string `mapstructure:"base_url"`
+	APIKey     string `mapstructure:"api_key"`
+	Model      string `mapstructure:"model"`
+	Dimensions int    `mapstructure:"dimensions"`
+}
+
+// RAGConfig 检索增强配置
+type RAGConfig struct {
+	VectorStore  string  `mapstructure:"vector_store"` // mysql, memory
+	ChunkSize    int     `mapstructure:"chunk_size"`   // 切片字符数
+	ChunkOverlap int     `mapstructure:"chunk_overlap"`
+	TopK         int     `mapstructure:"top_k"`
+	MinScore     float32 `mapstructure:"min_score"` // 低于该相似度的切片不注入上下文
+}
+
+// RateLimitConfig 限流配置
+// 规则未配置时使用默认值，limit 为负数表示不限流
+type RateLimitConfig struct {
+	Send   RateLimitRule `mapstructure:"send"`   // 发送消息，按用户或 API Key 计数
+	Login  RateLimitRule `mapstructure:"login"`  // 登录，按客户端 IP 计数
+	Signup RateLimitRule `mapstructure:"signup"` // 注册，按客户端 IP 计数
+}
+
+// RateLimitRule 限流规则：window_seconds 秒内最多 limit 次请求
+type RateLimitRule struct {
+	Limit         int `mapstructure:"limit"`
+	WindowSeconds int `mapstructure:"window_seconds"`
+}
+
+// QuotaConfig 用户 token 配额配置
+// 配额按自然月计算，0 表示不限额
+type QuotaConfig struct {
+	MonthlyTokens int64 `mapstructure:"monthly_tokens"` // 默认每月 token 配额
+	// Users 按用户 ID 覆盖配额，负数表示该用户不限额
+	Users map[string]int64 `mapstructure:"users"`
 }
 
 // LoggerConfig 日志配置
 type LoggerConfig struct {
-	Level string `mapstructure:"level"`
+	Level  string `mapstructure:"level"`  // debug, info, warn, error，默认 info
+	Format string `mapstructure:"format"` // json 或 text，为空时 release 模式 (GIN_MODE=release) 使用 json
 }
 
 // KafkaConfig Kafka 配置
@@ -61,6 +168,7 @@ type KafkaConfig struct {
 	DLQTopic string              `mapstructure:"dlq_topic"`
 	Producer KafkaProducerConfig `mapstructure:"producer"`
 	Consumer KafkaConsumerConfig `mapstructure:"consumer"`
+	Outbox   KafkaOutboxConfig   `mapstructure:"outbox"`
 }
 
 type KafkaProducerConfig struct {
@@ -74,20 +182,37 @@ type KafkaProducerConfig struct {
 }
 
 type KafkaConsumerConfig struct {
-	GroupID          string `mapstructure:"group_id"`
-	MinBytes         int    `mapstructure:"min_bytes"`
-	MaxBytes         int    `mapstructure:"max_bytes"`
-	MaxWaitMS        int    `mapstructure:"max_wait_ms"`
-	StartOffset      string `mapstructure:"start_offset"`
-	MaxRetry         int    `mapstructure:"max_retry"`
-	RetryBackoffMS   int    `mapstructure:"retry_backoff_ms"`
-	CommitTimeoutMS  int    `mapstructure:"commit_timeout_ms"`
+	GroupID         string `mapstructure:"group_id"`
+	MinBytes        int    `mapstructure:"min_bytes"`
+	MaxBytes        int    `mapstructure:"max_bytes"`
+	MaxWaitMS       int    `mapstructure:"max_wait_ms"`
+	StartOffset     string `mapstructure:"start_offset"`
+	MaxRetry        int    `mapstructure:"max_retry"`
+	RetryBackoffMS  int    `mapstructure:"retry_backoff_ms"`
+	CommitTimeoutMS int    `mapstructure:"commit_timeout_ms"`
+	// 批量消费，BatchSize 大于 1 时启用
+	BatchSize      int `mapstructure:"batch_size"`
+	BatchTimeoutMS int `mapstructure:"batch_timeout_ms"`
+	Workers        int `mapstructure:"workers"`
+}
+
+// KafkaOutboxConfig 消息发件箱配置
+// 发送 Kafka 失败的消息事件写入 MySQL 发件箱，由后台任务重新发布
+type KafkaOutboxConfig struct {
+	IntervalMS     int `mapstructure:"interval_ms"`     // 扫描间隔，默认 1000
+	BatchSize      int `mapstructure:"batch_size"`      // 单次最多发布条数，默认 100
+	MaxAttempts    int `mapstructure:"max_attempts"`    // 最大发布次数，超过后标记为 dead，默认 20
+	RetentionHours int `mapstructure:"retention_hours"` // 已发布事件的保留时间，默认 72
 }
 
-// JaegerConfig Jaeger 配置
+// JaegerConfig 链路追踪配置
+// 通过 OTLP gRPC 上报到 Jaeger (或任意 OTLP Collector)，Endpoint 为空时不启用
 type JaegerConfig struct {
-	AgentHost string `mapstructure:"agent_host"`
-	AgentPort string `mapstructure:"agent_port"`
+	Endpoint    string  `mapstructure:"endpoint"`     // OTLP gRPC 地址，如 jaeger:4317
+	TLS         bool    `mapstructure:"tls"`          // 是否使用 TLS，默认明文
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 采样率 (0, 1]，默认 1
+	ServiceName string  `mapstructure:"service_name"`
+	Environment string  `mapstructure:"environment"`
 }
 
 var globalConfig *Config
@@ -109,6 +234,9 @@ func InitConfig() *Config {
 	}
 
 	// 环境变量覆盖 (优先级: 环境变量 > 配置文件)
+	if provider := os.Getenv("LLM_PROVIDER"); provider != "" {
+		cfg.LLM.Provider = provider
+	}
 	if apiKey := os.Getenv("QWEN_API_KEY"); apiKey != "" {
 		cfg.LLM.APIKey = apiKey
 	}
@@ -118,16 +246,31 @@ func InitConfig() *Config {
 	if model := os.Getenv("QWEN_MODEL"); model != "" {
 		cfg.LLM.Model = model
 	}
+	if nodeID := os.Getenv("NODE_ID"); nodeID != "" {
+		if id, err := strconv.ParseInt(nodeID, 10, 64); err == nil {
+			cfg.Server.NodeID = &id
+		}
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		cfg.Server.AdminToken = token
+	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.Server.TrustedProxies = splitAndTrimCSV(proxies)
+	}
 	// Kafka 环境变量覆盖
 	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
 		cfg.Kafka.Brokers = splitAndTrimCSV(brokers)
 	}
-	// Jaeger 环境变量覆盖
-	if host := os.Getenv("JAEGER_AGENT_HOST"); host != "" {
-		cfg.Jaeger.AgentHost = host
+	// 日志环境变量覆盖
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		cfg.Logger.Level = level
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		cfg.Logger.Format = format
 	}
-	if port := os.Getenv("JAEGER_AGENT_PORT"); port != "" {
-		cfg.Jaeger.AgentPort = port
+	// Jaeger 环境变量覆盖
+	if endpoint := os.Getenv("JAEGER_ENDPOINT"); endpoint != "" {
+		cfg.Jaeger.Endpoint = endpoint
 	}
 
 	globalConfig = &cfg
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// APIKeyPrefix API Key 的固定前缀，用于与 JWT 区分
+const APIKeyPrefix = "coca_"
+
+// API Key 的权限范围
+const (
+	APIKeyScopeChat        = "chat"        // 会话与消息 (/chat)
+	APIKeyScopeCompletions = "completions" // OpenAI 兼容接口 (/v1)
+	APIKeyScopeDocuments   = "documents"   // 知识库文档 (/documents)
+)
+
+// APIKeyScopes 所有可用的权限范围
+var APIKeyScopes = []string{APIKeyScopeChat, APIKeyScopeCompletions, APIKeyScopeDocuments}
+
+// APIKey 用户的个人 API Key，供脚本与 CI 以编程方式访问
+// 明文只在创建时返回一次，服务端仅保存哈希
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string    // 明文的前若干位，便于用户辨认
+	Scopes     []string  // 权限范围
+	ExpiresAt  time.Time // 零值表示永不过期
+	LastUsedAt time.Time // 零值表示从未使用
+	RevokedAt  time.Time // 零值表示未吊销
+	CreatedAt  time.Time
+}
+
+// HasScope 是否拥有指定权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired 在 now 时是否已过期
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Revoked 是否已吊销
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// Document 用户上传的知识库文档
+type Document struct {
+	ID          int64
+	UserID      int64
+	Title       string
+	ContentType string // text/plain, text/markdown, application/pdf (已提取文本)
+	Size        int    // 文本字符数
+	ChunkCount  int
+	CreatedAt   time.Time
+}
+
+// DocumentChunk 文档切片
+type DocumentChunk struct {
+	ID         int64
+	DocumentID int64
+	UserID     int64
+	Index      int // 在文档中的序号
+	Content    string
+	Embedding  []float32
+}
+
+// Citation 回复引用的资料来源
+type Citation struct {
+	DocumentID int64
+	Title      string
+	ChunkIndex int
+	Snippet    string
+	Score      float32
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Generation 一次 AI 回复的生成过程
+// 生成在服务端独立运行，流式事件缓冲在 Redis 中，客户端断线后可凭 ID 续传
+type Generation struct {
+	ID        int64
+	UserID    int64
+	SessionID int64
+}
+
+// IdempotentRequest 携带 Idempotency-Key 的发送请求
+// 同一 Key 的重试重放首次请求启动的生成，而不是再发送一轮对话
+type IdempotentRequest struct {
+	Fingerprint  string // 请求内容摘要，同一 Key 用于不同请求时拒绝
+	GenerationID int64  // 首次请求启动的生成，0 表示仍在处理中
+}
+
+// GenerationEvent 生成过程中的一条流式事件
+type GenerationEvent struct {
+	ID    string // 事件 ID (单调递增)，作为 SSE 的 id 字段
+	Event string // 事件类型
+	Data  string // 事件内容 (JSON)
+}
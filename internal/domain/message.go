@@ -3,21 +3,47 @@ package domain
 import "time"
 
 // MessageRole 定义消息角色类型
-type MessageRole string 
+type MessageRole string
 
 const (
 	RoleUser      MessageRole = "user"      // 用户消息
 	RoleAssistant MessageRole = "assistant" // AI 助手回复
 	RoleSystem    MessageRole = "system"    // 系统提示 (System Prompt)
+	RoleToolCall  MessageRole = "tool_call" // AI 发起的工具调用
+	RoleTool      MessageRole = "tool"      // 工具执行结果
 )
 
+// FinishReasonCancelled 生成被用户停止或连接中断时的结束原因
+const FinishReasonCancelled = "cancelled"
+
+// ToolCall 表示 AI 发起的一次工具调用
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON 格式的参数
+}
+
 // Message 表示一条对话消息
+// 会话中的消息通过 ParentID 组成一棵树，重新生成与编辑会在同一父消息下产生兄弟分支
 type Message struct {
-	ID        int64
-	SessionID int64
-	Role      MessageRole
-	Content   string
-	CreatedAt time.Time
+	ID         int64
+	SessionID  int64
+	ParentID   int64 // 父消息 ID，0 表示根消息
+	Role       MessageRole
+	Content    string
+	ToolCalls  []ToolCall // RoleToolCall 消息中的工具调用
+	ToolCallID string     // RoleTool 消息对应的工具调用 ID
+	// FinishReason AI 回复的结束原因 (stop, length, cancelled 等)
+	FinishReason string
+	// PromptTokens / CompletionTokens 生成该消息消耗的 token 数 (仅 AI 生成的消息)
+	PromptTokens     int
+	CompletionTokens int
+	// Provider / Model 实际生成该消息的 Provider 与模型 (故障转移时可能与会话设置不同)
+	Provider   string
+	Model      string
+	Citations  []Citation // 本次回复引用的资料 (仅在生成时返回，不持久化)
+	SiblingIDs []int64    // 同一父消息下的所有分支 (含自身，仅查询时填充)
+	CreatedAt  time.Time
 }
 
 // IsUser 判断是否为用户消息
@@ -34,3 +60,13 @@ func (m *Message) IsAssistant() bool {
 func (m *Message) IsSystem() bool {
 	return m.Role == RoleSystem
 }
+
+// IsToolCall 判断是否为工具调用消息
+func (m *Message) IsToolCall() bool {
+	return m.Role == RoleToolCall
+}
+
+// IsToolResult 判断是否为工具结果消息
+func (m *Message) IsToolResult() bool {
+	return m.Role == RoleTool
+}
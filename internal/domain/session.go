@@ -5,9 +5,39 @@ import "time"
 // Session 表示一个对话会话
 // 一个用户可以有多个 Session，每个 Session 包含多条 Message
 type Session struct {
-	ID        int64
-	UserID    int64
-	Title     string
+	ID     int64
+	UserID int64
+	Title  string
+
+	// 生成参数，零值表示使用 Provider 默认值
+	Model       string
+	Temperature *float32
+	TopP        *float32
+	MaxTokens   *int
+	Stop        []string
+
+	// ActiveMessageID 当前分支的末端消息，0 表示尚未启用分支 (按时间顺序展示)
+	ActiveMessageID int64
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// SessionEventType 会话事件类型
+type SessionEventType string
+
+const (
+	SessionEventCreated      SessionEventType = "session.created"
+	SessionEventTitleChanged SessionEventType = "session.title_changed"
+	SessionEventDeleted      SessionEventType = "session.deleted"
+	SessionEventTyping       SessionEventType = "session.typing"
+)
+
+// SessionEvent 会话变更事件，推送给该用户在各设备上的连接
+type SessionEvent struct {
+	Type      SessionEventType `json:"type"`
+	UserID    int64            `json:"user_id"`
+	SessionID int64            `json:"session_id"`
+	Title     string           `json:"title,omitempty"`
+	Origin    string           `json:"origin,omitempty"` // 发起事件的连接 ID，推送时跳过该连接
+}
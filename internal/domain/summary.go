@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// Summary 会话历史摘要
+// 覆盖会话开头到 CoveredMessageID (含) 的所有消息，随旧消息移出上下文窗口增量前移
+type Summary struct {
+	SessionID        int64
+	Content          string
+	CoveredMessageID int64 // 摘要覆盖的最后一条消息 ID
+	CoveredCount     int   // 摘要覆盖的消息条数
+	UpdatedAt        time.Time
+}
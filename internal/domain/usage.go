@@ -0,0 +1,31 @@
+package domain
+
+// DailyUsage 用户某一天的 token 用量
+type DailyUsage struct {
+	Day              string // 日期 (2006-01-02，服务器本地时区)
+	PromptTokens     int64
+	CompletionTokens int64
+	Requests         int64 // 模型调用次数
+}
+
+// TotalTokens 总 token 数
+func (u DailyUsage) TotalTokens() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageReport 用户的用量统计
+type UsageReport struct {
+	Days []DailyUsage // 按日期升序
+	// MonthTokens 本自然月已用 token 数
+	MonthTokens int64
+	// MonthlyQuota 每月 token 配额，0 表示不限
+	MonthlyQuota int64
+}
+
+// Remaining 本月剩余 token 数，不限额时返回 -1
+func (r UsageReport) Remaining() int64 {
+	if r.MonthlyQuota <= 0 {
+		return -1
+	}
+	return max(r.MonthlyQuota-r.MonthTokens, 0)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"coca-ai/internal/mq"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDLQListLimit 查看死信消息时默认返回的条数
+const defaultDLQListLimit = 100
+
+// AdminHandler 处理运维管理请求
+type AdminHandler struct {
+	dlq *mq.DLQ
+}
+
+// NewAdminHandler 创建 AdminHandler 实例，未配置 Kafka 时 dlq 为 nil
+func NewAdminHandler(dlq *mq.DLQ) *AdminHandler {
+	return &AdminHandler{dlq: dlq}
+}
+
+// ==================== Request/Response 结构体定义 ====================
+
+// DLQListResp 死信消息列表响应
+type DLQListResp struct {
+	Topic   string        `json:"topic"`
+	Depth   int64         `json:"depth"` // 未确认的消息总数
+	Entries []mq.DLQEntry `json:"entries"`
+}
+
+// DLQReplayReq 死信消息重放请求，不设置任何条件时重放全部未确认的消息并将其确认
+// 设置了筛选条件时须同时设置 purge，重放后将全部死信消息标记为已确认
+type DLQReplayReq struct {
+	SessionID     int64            `json:"session_id"`
+	ErrorContains string           `json:"error"`
+	Positions     []mq.DLQPosition `json:"positions"`
+	IncludeAcked  bool             `json:"include_acked"`
+	Purge         bool             `json:"purge"`
+}
+
+// ==================== 路由注册 ====================
+
+// RegisterRoutes 注册管理路由
+func (h *AdminHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+	adminGroup := server.Group("/admin")
+	adminGroup.Use(authMiddleware)
+	{
+		adminGroup.GET("/dlq", h.ListDLQ)
+		adminGroup.POST("/dlq/replay", h.ReplayDLQ)
+		adminGroup.POST("/dlq/purge", h.PurgeDLQ)
+	}
+}
+
+// ==================== Handler 方法 ====================
+
+// ListDLQ 查看死信消息
+// GET /admin/dlq?session_id=&error=&all=false&limit=100
+func (h *AdminHandler) ListDLQ(c *gin.Context) {
+	if !h.checkDLQ(c) {
+		return
+	}
+
+	filter := mq.DLQFilter{
+		ErrorContains: c.Query("error"),
+		IncludeAcked:  c.Query("all") == "true",
+		Limit:         defaultDLQListLimit,
+	}
+	if raw := c.Query("session_id"); raw != "" {
+		sessionID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+			return
+		}
+		filter.SessionID = sessionID
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	depth, err := h.dlq.Depth(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	entries, err := h.dlq.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": DLQListResp{Topic: h.dlq.Topic(), Depth: depth, Entries: entries},
+	})
+}
+
+// ReplayDLQ 将死信消息重新发送到 chat.messages
+// POST /admin/dlq/replay
+func (h *AdminHandler) ReplayDLQ(c *gin.Context) {
+	if !h.checkDLQ(c) {
+		return
+	}
+
+	// 请求体可选
+	var req DLQReplayReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	replayed, err := h.dlq.Replay(c.Request.Context(), mq.DLQFilter{
+		SessionID:     req.SessionID,
+		ErrorContains: req.ErrorContains,
+		Positions:     req.Positions,
+		IncludeAcked:  req.IncludeAcked,
+	}, req.Purge)
+	if errors.Is(err, mq.ErrDLQReplayNotPurged) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error(), "data": gin.H{"replayed": replayed}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"replayed": replayed},
+	})
+}
+
+// PurgeDLQ 将全部死信消息标记为已确认
+// POST /admin/dlq/purge
+func (h *AdminHandler) PurgeDLQ(c *gin.Context) {
+	if !h.checkDLQ(c) {
+		return
+	}
+
+	purged, err := h.dlq.Purge(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"purged": purged},
+	})
+}
+
+// checkDLQ 未配置 Kafka 时返回 503
+func (h *AdminHandler) checkDLQ(c *gin.Context) bool {
+	if h.dlq == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "msg": "Kafka is not configured"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler 处理个人 API Key 管理相关的 HTTP 请求
+type APIKeyHandler struct {
+	svc *service.APIKeyService
+}
+
+// NewAPIKeyHandler 创建 APIKeyHandler 实例
+func NewAPIKeyHandler(svc *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc}
+}
+
+// ==================== Request/Response 结构体定义 ====================
+
+// CreateAPIKeyReq 创建 API Key 请求
+type CreateAPIKeyReq struct {
+	Name          string   `json:"name" binding:"required,max=64"`
+	Scopes        []string `json:"scopes"`                                   // 为空表示全部权限
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,gt=0"` // 为空表示永不过期
+}
+
+// RenameAPIKeyReq 重命名 API Key 请求
+type RenameAPIKeyReq struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// APIKeyItem API Key 列表项
+type APIKeyItem struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	Revoked    bool     `json:"revoked"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreateAPIKeyResp 创建 API Key 响应，Key 明文只返回这一次
+type CreateAPIKeyResp struct {
+	APIKeyItem
+	Key string `json:"key"`
+}
+
+// ==================== 路由注册 ====================
+
+// RegisterRoutes 注册 API Key 管理路由
+// 只允许通过登录 (JWT) 管理，不能用 API Key 创建新的 API Key
+func (h *APIKeyHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+	keyGroup := server.Group("/users/me/api-keys")
+	keyGroup.Use(authMiddleware)
+	{
+		keyGroup.POST("", h.Create)
+		keyGroup.GET("", h.List)
+		keyGroup.PUT("/:id", h.Rename)
+		keyGroup.DELETE("/:id", h.Revoke)
+	}
+}
+
+// ==================== Handler 方法 ====================
+
+// Create 创建 API Key
+// POST /users/me/api-keys
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	var req CreateAPIKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var expiresAt time.Time
+	if req.ExpiresInDays > 0 {
+		expiresAt = time.Now().AddDate(0, 0, req.ExpiresInDays)
+	}
+	key, secret, err := h.svc.Create(c.Request.Context(), userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": CreateAPIKeyResp{
+			APIKeyItem: toAPIKeyItem(key),
+			Key:        secret,
+		},
+	})
+}
+
+// List 获取用户的 API Key 列表
+// GET /users/me/api-keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	keys, err := h.svc.List(c.Request.Context(), userID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	items := make([]APIKeyItem, len(keys))
+	for i := range keys {
+		items[i] = toAPIKeyItem(&keys[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": items,
+	})
+}
+
+// Rename 重命名 API Key
+// PUT /users/me/api-keys/:id
+func (h *APIKeyHandler) Rename(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid API key ID"})
+		return
+	}
+
+	var req RenameAPIKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.svc.Rename(c.Request.Context(), userID, id, req.Name); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "API key renamed",
+	})
+}
+
+// Revoke 吊销 API Key
+// DELETE /users/me/api-keys/:id
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.svc.Revoke(c.Request.Context(), userID, id); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "API key revoked",
+	})
+}
+
+// writeError 将 Service 层错误映射为 HTTP 响应
+func (h *APIKeyHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "API key not found"})
+	case errors.Is(err, service.ErrInvalidScope), errors.Is(err, service.ErrTooManyAPIKeys):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+	}
+}
+
+func toAPIKeyItem(key *domain.APIKey) APIKeyItem {
+	item := APIKeyItem{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		Revoked:   key.Revoked(),
+		CreatedAt: key.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if !key.ExpiresAt.IsZero() {
+		item.ExpiresAt = key.ExpiresAt.Format("2006-01-02T15:04:05Z")
+	}
+	if !key.LastUsedAt.IsZero() {
+		item.LastUsedAt = key.LastUsedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return item
+}
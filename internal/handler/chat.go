@@ -1,21 +1,44 @@
 package handler
 
 import (
+	"coca-ai/internal/domain"
 	"coca-ai/internal/service"
+	"coca-ai/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// HeaderIdempotencyKey 发送消息的幂等 Key，客户端重试时携带相同的值
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// maxIdempotencyKeyLen 幂等 Key 的最大长度
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotency 发送请求的幂等信息，零值表示不做幂等处理
+type idempotency struct {
+	key         string
+	fingerprint string
+}
+
 // ChatHandler 处理聊天相关的 HTTP 请求
 type ChatHandler struct {
-	chatSvc *service.ChatService
+	chatSvc   *service.ChatService
+	streamSvc *service.StreamService
+	l         *slog.Logger
 }
 
 // NewChatHandler 创建 ChatHandler 实例
-func NewChatHandler(chatSvc *service.ChatService) *ChatHandler {
-	return &ChatHandler{chatSvc: chatSvc}
+func NewChatHandler(chatSvc *service.ChatService, streamSvc *service.StreamService, l *slog.Logger) *ChatHandler {
+	return &ChatHandler{chatSvc: chatSvc, streamSvc: streamSvc, l: l.With("component", "chat_handler")}
 }
 
 // ==================== Request/Response 结构体定义 ====================
@@ -38,18 +61,73 @@ type SessionListItem struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
+// RegenerateReq 重新生成回复请求
+type RegenerateReq struct {
+	MessageID int64 `json:"message_id"` // 要重新生成的消息，0 表示当前分支的最后一条
+}
+
+// SwitchBranchReq 切换分支请求
+type SwitchBranchReq struct {
+	MessageID int64 `json:"message_id" binding:"required"`
+}
+
 // MessageItem 消息列表项
 type MessageItem struct {
-	ID        int64  `json:"id"`
-	Role      string `json:"role"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
+	ID           int64          `json:"id"`
+	ParentID     int64          `json:"parent_id"`
+	SiblingIDs   []int64        `json:"sibling_ids,omitempty"` // 同一父消息下的所有分支 (含自身)
+	Role         string         `json:"role"`
+	Content      string         `json:"content"`
+	ToolCalls    []ToolCallItem `json:"tool_calls,omitempty"`
+	ToolCallID   string         `json:"tool_call_id,omitempty"`
+	FinishReason string         `json:"finish_reason,omitempty"` // cancelled 表示回复被中途停止
+	// 生成该消息消耗的 token 数 (仅 AI 生成的消息)
+	PromptTokens     int    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `json:"completion_tokens,omitempty"`
+	Provider         string `json:"provider,omitempty"` // 实际生成该消息的 Provider
+	Model            string `json:"model,omitempty"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// ToolCallItem 工具调用项
+type ToolCallItem struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// CitationItem 回复引用的资料来源
+type CitationItem struct {
+	Index      int     `json:"index"` // 对应回复中的 [n] 标注
+	DocumentID int64   `json:"document_id"`
+	Title      string  `json:"title"`
+	ChunkIndex int     `json:"chunk_index"`
+	Snippet    string  `json:"snippet"`
+	Score      float32 `json:"score"`
+}
+
+// SessionSettings 会话生成参数
+type SessionSettings struct {
+	Model       string   `json:"model"`
+	Temperature *float32 `json:"temperature" binding:"omitempty,gte=0,lte=2"`
+	TopP        *float32 `json:"top_p" binding:"omitempty,gt=0,lte=1"`
+	MaxTokens   *int     `json:"max_tokens" binding:"omitempty,gt=0"`
+	Stop        []string `json:"stop" binding:"omitempty,max=4,dive,max=32"` // 以 JSON 数组存入 varchar(1024)，按转义后的最坏长度限制每项长度
+}
+
+// SummaryResp 会话摘要响应
+type SummaryResp struct {
+	Content          string `json:"content"`
+	CoveredMessageID int64  `json:"covered_message_id"` // 摘要覆盖到的最后一条消息 ID，0 表示尚未生成
+	CoveredCount     int    `json:"covered_count"`
+	UpdatedAt        string `json:"updated_at,omitempty"`
 }
 
 // ==================== 路由注册 ====================
 
 // RegisterRoutes 注册聊天相关路由
-func (h *ChatHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+// sendLimiter 作用于会触发模型生成的接口
+func (h *ChatHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc, sendLimiter gin.HandlerFunc) {
 	chatGroup := server.Group("/chat")
 	chatGroup.Use(authMiddleware) // 所有聊天接口都需要登录
 	{
@@ -57,10 +135,19 @@ func (h *ChatHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.Hand
 		chatGroup.POST("/sessions", h.CreateSession)
 		chatGroup.GET("/sessions", h.GetSessionList)
 		chatGroup.DELETE("/sessions/:id", h.DeleteSession)
+		chatGroup.GET("/sessions/:id/settings", h.GetSessionSettings)
+		chatGroup.PUT("/sessions/:id/settings", h.UpdateSessionSettings)
+		chatGroup.GET("/sessions/:id/summary", h.GetSummary)
+		chatGroup.GET("/models", h.ListModels)
 
 		// 消息管理
 		chatGroup.GET("/sessions/:id/messages", h.GetMessages)
-		chatGroup.POST("/sessions/:id/messages", h.SendMessage)
+		chatGroup.POST("/sessions/:id/messages", sendLimiter, h.SendMessage)
+		chatGroup.POST("/sessions/:id/messages/:message_id/edit", sendLimiter, h.EditMessage)
+		chatGroup.POST("/sessions/:id/regenerate", sendLimiter, h.RegenerateMessage)
+		chatGroup.PUT("/sessions/:id/branch", h.SwitchBranch)
+		chatGroup.POST("/sessions/:id/stop", h.StopGeneration)
+		chatGroup.GET("/generations/:id/stream", h.ResumeGeneration)
 	}
 }
 
@@ -124,38 +211,40 @@ func (h *ChatHandler) GetSessionList(c *gin.Context) {
 // GetMessages 获取会话的历史消息
 // GET /chat/sessions/:id/messages
 func (h *ChatHandler) GetMessages(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
 	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
 		return
 	}
 
-	messages, err := h.chatSvc.GetMessages(c.Request.Context(), sessionID)
+	messages, err := h.chatSvc.GetMessages(c.Request.Context(), userID, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		h.writeError(c, err)
 		return
 	}
 
-	// 转换为响应格式
-	items := make([]MessageItem, len(messages))
-	for i, m := range messages {
-		items[i] = MessageItem{
-			ID:        m.ID,
-			Role:      string(m.Role),
-			Content:   m.Content,
-			CreatedAt: m.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
-		"data": items,
+		"data": toMessageItems(messages),
 	})
 }
 
 // SendMessage 发送消息并流式返回 AI 回复 (SSE)
+// 携带 Idempotency-Key 时，相同 Key 的重试重放首次请求的生成，不会重复发送
 // POST /chat/sessions/:id/messages
 func (h *ChatHandler) SendMessage(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
 	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
@@ -168,44 +257,336 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// 设置 SSE 响应头
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+	idem := idempotency{key: c.GetHeader(HeaderIdempotencyKey)}
+	if len(idem.key) > maxIdempotencyKeyLen {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Idempotency-Key is too long"})
+		return
+	}
+	if idem.key != "" {
+		sum := sha256.Sum256([]byte(strconv.FormatInt(sessionID, 10) + "\n" + req.Content))
+		idem.fingerprint = hex.EncodeToString(sum[:])
+	}
+
+	// 进入 SSE 之前先校验会话归属与配额，保证能返回正确的 HTTP 状态码
+	if _, err := h.chatSvc.GetSession(c.Request.Context(), userID, sessionID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	if err := h.chatSvc.CheckQuota(c.Request.Context(), userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.streamReply(c, userID, sessionID, idem, func(ctx context.Context, callback service.StreamCallback) (*domain.Message, error) {
+		return h.chatSvc.SendMessage(ctx, userID, sessionID, req.Content, callback)
+	})
+}
+
+// EditMessage 编辑用户消息并重新发送，流式返回新分支的 AI 回复 (SSE)
+// POST /chat/sessions/:id/messages/:message_id/edit
+func (h *ChatHandler) EditMessage(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid message ID"})
+		return
+	}
+
+	var req SendMessageReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: content is required"})
+		return
+	}
+
+	if _, err := h.chatSvc.GetSession(c.Request.Context(), userID, sessionID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	if err := h.chatSvc.CheckQuota(c.Request.Context(), userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.streamReply(c, userID, sessionID, idempotency{}, func(ctx context.Context, callback service.StreamCallback) (*domain.Message, error) {
+		return h.chatSvc.EditMessage(ctx, userID, sessionID, messageID, req.Content, callback)
+	})
+}
+
+// RegenerateMessage 重新生成回复，流式返回新分支的 AI 回复 (SSE)
+// POST /chat/sessions/:id/regenerate
+func (h *ChatHandler) RegenerateMessage(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	// 请求体可选
+	var req RegenerateReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	if _, err := h.chatSvc.GetSession(c.Request.Context(), userID, sessionID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	if err := h.chatSvc.CheckQuota(c.Request.Context(), userID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	h.streamReply(c, userID, sessionID, idempotency{}, func(ctx context.Context, callback service.StreamCallback) (*domain.Message, error) {
+		return h.chatSvc.RegenerateMessage(ctx, userID, sessionID, req.MessageID, callback)
+	})
+}
+
+// SwitchBranch 切换当前分支，返回切换后的消息列表
+// PUT /chat/sessions/:id/branch
+func (h *ChatHandler) SwitchBranch(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	var req SwitchBranchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: message_id is required"})
+		return
+	}
+
+	messages, err := h.chatSvc.SwitchBranch(c.Request.Context(), userID, sessionID, req.MessageID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": toMessageItems(messages),
+	})
+}
+
+// StopGeneration 停止会话正在进行的生成，已生成的部分回复会被保存
+// POST /chat/sessions/:id/stop
+func (h *ChatHandler) StopGeneration(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	if err := h.chatSvc.StopGeneration(c.Request.Context(), userID, sessionID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "Generation stopped",
+	})
+}
+
+// streamReply 在后台启动生成，并以 SSE 流式输出 AI 回复，结束时发送 done 事件
+// 每个事件带有 id 字段，连接中断后可通过 GET /chat/generations/:id/stream 续传
+func (h *ChatHandler) streamReply(c *gin.Context, userID int64, sessionID int64, idem idempotency, run func(ctx context.Context, callback service.StreamCallback) (*domain.Message, error)) {
+	generationID, replayed, err := h.streamSvc.StartIdempotent(c.Request.Context(), userID, sessionID, idem.key, idem.fingerprint, func(ctx context.Context, emit service.EmitFunc) (any, error) {
+		assistantMsg, err := run(ctx, func(event service.StreamEvent) error {
+			if name, data := streamEventPayload(event); name != "" {
+				emit(name, data)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return donePayload(assistantMsg), nil
+	})
+	if errors.Is(err, service.ErrIdempotencyKeyReuse) || errors.Is(err, service.ErrRequestInProgress) {
+		h.writeError(c, err)
+		return
+	}
+	if err != nil && idem.key != "" {
+		// 无法保证幂等时不能发送，由客户端携带同一 Key 重试
+		h.l.ErrorContext(c.Request.Context(), "start idempotent generation failed", logger.Error(err), "session_id", sessionID)
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": 503, "msg": "Service temporarily unavailable, retry with the same Idempotency-Key"})
+		return
+	}
+	if err != nil {
+		// 事件流不可用时退化为直接输出，不支持续传
+		h.l.WarnContext(c.Request.Context(), "start resumable generation failed, streaming directly", logger.Error(err))
+		defer h.streamSvc.Track()()
+		setSSEHeaders(c)
+		streamDirect(c, run)
+		return
+	}
+
+	if replayed {
+		// 重放首次请求的生成；事件流已过期时无法重放，也不能再次发送
+		if _, err := h.streamSvc.GetGeneration(c.Request.Context(), userID, generationID); err != nil {
+			h.writeError(c, err)
+			return
+		}
+		c.Header("Idempotent-Replayed", "true")
+	}
+
+	setSSEHeaders(c)
+	c.Header("X-Generation-ID", strconv.FormatInt(generationID, 10))
+	h.followGeneration(c, userID, generationID, "0")
+}
+
+// ResumeGeneration 续传生成的流式输出 (SSE)
+// 先重放 Last-Event-ID 之后的事件，再持续输出直到生成结束
+// GET /chat/generations/:id/stream
+func (h *ChatHandler) ResumeGeneration(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
 
-	// 调用 ChatService 发送消息，流式返回
-	assistantMsg, err := h.chatSvc.SendMessage(c.Request.Context(), sessionID, req.Content, func(delta string) error {
-		c.SSEvent("message", gin.H{"delta": delta})
+	generationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid generation ID"})
+		return
+	}
+
+	// 浏览器 EventSource 重连时通过请求头携带，手动续传时也可通过查询参数指定
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.DefaultQuery("last_event_id", "0")
+	}
+
+	if _, err := h.streamSvc.GetGeneration(c.Request.Context(), userID, generationID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	setSSEHeaders(c)
+	h.followGeneration(c, userID, generationID, lastEventID)
+}
+
+// followGeneration 将生成事件流写为带 id 的 SSE
+func (h *ChatHandler) followGeneration(c *gin.Context, userID int64, generationID int64, afterID string) {
+	err := h.streamSvc.Follow(c.Request.Context(), userID, generationID, afterID, func(event domain.GenerationEvent) error {
+		if _, err := fmt.Fprintf(c.Writer, "id:%s\nevent:%s\ndata:%s\n\n", event.ID, event.Event, event.Data); err != nil {
+			return err
+		}
 		c.Writer.Flush()
 		return nil
 	})
+	if err != nil {
+		c.SSEvent(service.GenerationEventError, gin.H{"msg": err.Error()})
+		c.Writer.Flush()
+	}
+}
+
+// streamDirect 在当前请求内生成并直接以 SSE 输出，客户端断开即停止生成
+func streamDirect(c *gin.Context, run func(ctx context.Context, callback service.StreamCallback) (*domain.Message, error)) {
+	assistantMsg, err := run(c.Request.Context(), func(event service.StreamEvent) error {
+		if name, data := streamEventPayload(event); name != "" {
+			c.SSEvent(name, data)
+			c.Writer.Flush()
+		}
+		return nil
+	})
 
 	if err != nil {
-		c.SSEvent("error", gin.H{"msg": err.Error()})
+		c.SSEvent(service.GenerationEventError, gin.H{"msg": err.Error()})
 		c.Writer.Flush()
 		return
 	}
 
-	// 发送完成事件
-	c.SSEvent("done", gin.H{
-		"message_id": assistantMsg.ID,
-		"content":    assistantMsg.Content,
-	})
+	c.SSEvent(service.GenerationEventDone, donePayload(assistantMsg))
 	c.Writer.Flush()
 }
 
+// setSSEHeaders 设置 SSE 响应头
+func setSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+}
+
+// donePayload 构建 done 事件内容
+func donePayload(assistantMsg *domain.Message) gin.H {
+	citations := make([]CitationItem, len(assistantMsg.Citations))
+	for i, ct := range assistantMsg.Citations {
+		citations[i] = CitationItem{
+			Index:      i + 1,
+			DocumentID: ct.DocumentID,
+			Title:      ct.Title,
+			ChunkIndex: ct.ChunkIndex,
+			Snippet:    ct.Snippet,
+			Score:      ct.Score,
+		}
+	}
+	return gin.H{
+		"message_id":    assistantMsg.ID,
+		"parent_id":     assistantMsg.ParentID,
+		"content":       assistantMsg.Content,
+		"finish_reason": assistantMsg.FinishReason,
+		"citations":     citations,
+		"provider":      assistantMsg.Provider,
+		"model":         assistantMsg.Model,
+		"usage": gin.H{
+			"prompt_tokens":     assistantMsg.PromptTokens,
+			"completion_tokens": assistantMsg.CompletionTokens,
+		},
+	}
+}
+
 // DeleteSession 删除会话
 // DELETE /chat/sessions/:id
 func (h *ChatHandler) DeleteSession(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
 	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
 		return
 	}
 
-	if err := h.chatSvc.DeleteSession(c.Request.Context(), sessionID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+	if err := h.chatSvc.DeleteSession(c.Request.Context(), userID, sessionID); err != nil {
+		h.writeError(c, err)
 		return
 	}
 
@@ -214,3 +595,201 @@ func (h *ChatHandler) DeleteSession(c *gin.Context) {
 		"msg":  "Session deleted",
 	})
 }
+
+// GetSessionSettings 获取会话的模型与生成参数
+// GET /chat/sessions/:id/settings
+func (h *ChatHandler) GetSessionSettings(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.chatSvc.GetSession(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": toSessionSettings(session),
+	})
+}
+
+// GetSummary 获取会话的历史摘要 (模型"记住"的早期对话)
+// GET /chat/sessions/:id/summary
+func (h *ChatHandler) GetSummary(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	summary, err := h.chatSvc.GetSummary(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	resp := SummaryResp{}
+	if summary != nil {
+		resp = SummaryResp{
+			Content:          summary.Content,
+			CoveredMessageID: summary.CoveredMessageID,
+			CoveredCount:     summary.CoveredCount,
+			UpdatedAt:        summary.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": resp,
+	})
+}
+
+// UpdateSessionSettings 更新会话的模型与生成参数
+// PUT /chat/sessions/:id/settings
+func (h *ChatHandler) UpdateSessionSettings(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid session ID"})
+		return
+	}
+
+	var req SessionSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+
+	session, err := h.chatSvc.UpdateSessionSettings(c.Request.Context(), userID, sessionID, domain.Session{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": toSessionSettings(session),
+	})
+}
+
+// ListModels 获取可用模型列表
+// GET /chat/models
+func (h *ChatHandler) ListModels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": h.chatSvc.ListModels(),
+	})
+}
+
+func toSessionSettings(session *domain.Session) SessionSettings {
+	return SessionSettings{
+		Model:       session.Model,
+		Temperature: session.Temperature,
+		TopP:        session.TopP,
+		MaxTokens:   session.MaxTokens,
+		Stop:        session.Stop,
+	}
+}
+
+// toMessageItems 将领域消息转换为响应格式
+func toMessageItems(messages []domain.Message) []MessageItem {
+	items := make([]MessageItem, len(messages))
+	for i, m := range messages {
+		items[i] = MessageItem{
+			ID:               m.ID,
+			ParentID:         m.ParentID,
+			SiblingIDs:       m.SiblingIDs,
+			Role:             string(m.Role),
+			Content:          m.Content,
+			ToolCallID:       m.ToolCallID,
+			FinishReason:     m.FinishReason,
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			Provider:         m.Provider,
+			Model:            m.Model,
+			CreatedAt:        m.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+		for _, call := range m.ToolCalls {
+			items[i].ToolCalls = append(items[i].ToolCalls, ToolCallItem{
+				ID:        call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			})
+		}
+	}
+	return items
+}
+
+// streamEventPayload 将流式事件转换为 SSE 事件名与内容，未知事件返回空事件名
+func streamEventPayload(event service.StreamEvent) (string, any) {
+	switch event.Type {
+	case service.StreamEventDelta:
+		return string(event.Type), gin.H{"delta": event.Delta}
+	case service.StreamEventToolCall:
+		return string(event.Type), gin.H{
+			"tool_call_id": event.ToolCall.ID,
+			"name":         event.ToolCall.Name,
+			"arguments":    event.ToolCall.Arguments,
+		}
+	case service.StreamEventToolResult:
+		return string(event.Type), gin.H{
+			"tool_call_id": event.ToolCall.ID,
+			"name":         event.ToolCall.Name,
+			"result":       event.ToolResult,
+		}
+	case service.StreamEventContext:
+		return string(event.Type), event.Context
+	}
+	return "", nil
+}
+
+// writeError 将 Service 层错误映射为 HTTP 响应
+func (h *ChatHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "Session not found"})
+	case errors.Is(err, service.ErrSessionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "Forbidden"})
+	case errors.Is(err, service.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "Message not found"})
+	case errors.Is(err, service.ErrGenerationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "Generation not found"})
+	case errors.Is(err, service.ErrModelNotSupported), errors.Is(err, service.ErrMessageNotEditable):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"code": 429, "msg": err.Error()})
+	case errors.Is(err, service.ErrRequestInProgress):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+	case errors.Is(err, service.ErrIdempotencyKeyReuse):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+	}
+}
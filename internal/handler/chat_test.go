@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/service"
+	"coca-ai/internal/service/servicetest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ownerID     = servicetest.OwnerID
+	otherUserID = servicetest.OtherUserID
+	sessionID   = servicetest.SessionID
+	missingID   = servicetest.MissingID
+)
+
+// newTestServer 以 userID 登录注册聊天路由，会话 sessionID 属于 ownerID
+func newTestServer(t *testing.T, userID int64) (*gin.Engine, *servicetest.SessionStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := servicetest.NewSessionStore(domain.Session{ID: sessionID, UserID: ownerID, Title: "owner's chat"})
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	chatSvc := service.NewChatService(store, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, l)
+	h := NewChatHandler(chatSvc, nil, l)
+
+	server := gin.New()
+	auth := func(c *gin.Context) { c.Set("uid", userID) }
+	noLimit := func(c *gin.Context) { c.Next() }
+	h.RegisterRoutes(server, auth, noLimit)
+	return server, store
+}
+
+// sessionRoutes 所有按会话 ID 访问的路由，body 为通过参数校验的最小请求体
+var sessionRoutes = []struct {
+	method string
+	path   string
+	body   string
+}{
+	{http.MethodDelete, "/chat/sessions/%d", ""},
+	{http.MethodGet, "/chat/sessions/%d/settings", ""},
+	{http.MethodPut, "/chat/sessions/%d/settings", `{"model":""}`},
+	{http.MethodGet, "/chat/sessions/%d/summary", ""},
+	{http.MethodGet, "/chat/sessions/%d/messages", ""},
+	{http.MethodPost, "/chat/sessions/%d/messages", `{"content":"hello"}`},
+	{http.MethodPost, "/chat/sessions/%d/messages/100/edit", `{"content":"hello"}`},
+	{http.MethodPost, "/chat/sessions/%d/regenerate", ""},
+	{http.MethodPut, "/chat/sessions/%d/branch", `{"message_id":100}`},
+	{http.MethodPost, "/chat/sessions/%d/stop", ""},
+}
+
+func doRequest(server *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d, body = %s", w.Code, want, w.Body.String())
+	}
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response failed: %v, body = %s", err, w.Body.String())
+	}
+	if resp.Code != want {
+		t.Fatalf("body code = %d, want %d", resp.Code, want)
+	}
+}
+
+func TestChatHandler_ForeignSessionForbidden(t *testing.T) {
+	for _, route := range sessionRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			server, store := newTestServer(t, otherUserID)
+			w := doRequest(server, route.method, fmt.Sprintf(route.path, sessionID), route.body)
+			assertStatus(t, w, http.StatusForbidden)
+			if store.Writes != 0 {
+				t.Fatalf("session store modified %d times by a forbidden request", store.Writes)
+			}
+		})
+	}
+}
+
+func TestChatHandler_MissingSessionNotFound(t *testing.T) {
+	for _, route := range sessionRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			server, _ := newTestServer(t, ownerID)
+			w := doRequest(server, route.method, fmt.Sprintf(route.path, missingID), route.body)
+			assertStatus(t, w, http.StatusNotFound)
+		})
+	}
+}
+
+func TestChatHandler_SessionListExcludesForeignSessions(t *testing.T) {
+	server, _ := newTestServer(t, otherUserID)
+	w := doRequest(server, http.MethodGet, "/chat/sessions", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Data []SessionListItem `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response failed: %v", err)
+	}
+	if len(resp.Data) != 0 {
+		t.Fatalf("session list = %+v, want empty", resp.Data)
+	}
+}
+
+func TestChatHandler_writeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "not found", err: service.ErrSessionNotFound, want: http.StatusNotFound},
+		{name: "forbidden", err: service.ErrSessionForbidden, want: http.StatusForbidden},
+		{name: "wrapped forbidden", err: fmt.Errorf("load session: %w", service.ErrSessionForbidden), want: http.StatusForbidden},
+		{name: "message not found", err: service.ErrMessageNotFound, want: http.StatusNotFound},
+		{name: "unknown", err: errors.New("boom"), want: http.StatusInternalServerError},
+	}
+
+	gin.SetMode(gin.TestMode)
+	h := &ChatHandler{}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			h.writeError(c, tc.err)
+			assertStatus(t, w, tc.want)
+		})
+	}
+}
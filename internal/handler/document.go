@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"coca-ai/internal/service"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadBytes 上传文件大小上限
+const maxUploadBytes = 2 << 20
+
+// DocumentHandler 处理知识库文档相关的 HTTP 请求
+type DocumentHandler struct {
+	docSvc *service.DocumentService
+}
+
+// NewDocumentHandler 创建 DocumentHandler 实例
+func NewDocumentHandler(docSvc *service.DocumentService) *DocumentHandler {
+	return &DocumentHandler{docSvc: docSvc}
+}
+
+// ==================== Request/Response 结构体定义 ====================
+
+// UploadDocumentReq 上传文档请求 (JSON)
+// PDF 需由客户端提取文本后以 content_type=application/pdf 上传
+type UploadDocumentReq struct {
+	Title       string `json:"title" binding:"required,max=255"`
+	ContentType string `json:"content_type" binding:"omitempty,oneof=text/plain text/markdown application/pdf"`
+	Content     string `json:"content" binding:"required"`
+}
+
+// DocumentItem 文档列表项
+type DocumentItem struct {
+	DocumentID  int64  `json:"document_id"`
+	Title       string `json:"title"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	ChunkCount  int    `json:"chunk_count"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ==================== 路由注册 ====================
+
+// RegisterRoutes 注册文档相关路由
+func (h *DocumentHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+	docGroup := server.Group("/documents")
+	docGroup.Use(authMiddleware)
+	{
+		docGroup.POST("", h.Upload)
+		docGroup.GET("", h.List)
+		docGroup.DELETE("/:id", h.Delete)
+	}
+}
+
+// ==================== Handler 方法 ====================
+
+// Upload 上传文档
+// POST /documents
+// 支持 JSON 请求体，或 multipart/form-data 上传 .txt / .md 文件 (字段名 file)
+func (h *DocumentHandler) Upload(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	var req UploadDocumentReq
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if !h.bindFile(c, &req) {
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.ContentType == "" {
+		req.ContentType = "text/plain"
+	}
+
+	doc, err := h.docSvc.Ingest(c.Request.Context(), userID, req.Title, req.ContentType, req.Content)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": DocumentItem{
+			DocumentID:  doc.ID,
+			Title:       doc.Title,
+			ContentType: doc.ContentType,
+			Size:        doc.Size,
+			ChunkCount:  doc.ChunkCount,
+			CreatedAt:   doc.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		},
+	})
+}
+
+// List 获取文档列表
+// GET /documents
+func (h *DocumentHandler) List(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	docs, err := h.docSvc.ListDocuments(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	items := make([]DocumentItem, len(docs))
+	for i, d := range docs {
+		items[i] = DocumentItem{
+			DocumentID:  d.ID,
+			Title:       d.Title,
+			ContentType: d.ContentType,
+			Size:        d.Size,
+			ChunkCount:  d.ChunkCount,
+			CreatedAt:   d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": items,
+	})
+}
+
+// Delete 删除文档
+// DELETE /documents/:id
+func (h *DocumentHandler) Delete(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	documentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid document ID"})
+		return
+	}
+
+	if err := h.docSvc.DeleteDocument(c.Request.Context(), userID, documentID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "Document deleted",
+	})
+}
+
+// bindFile 从 multipart 表单读取上传的文本文件
+func (h *DocumentHandler) bindFile(c *gin.Context, req *UploadDocumentReq) bool {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid request: file is required"})
+		return false
+	}
+	if fileHeader.Size > maxUploadBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "File too large"})
+		return false
+	}
+
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".md", ".markdown":
+		req.ContentType = "text/markdown"
+	case ".txt", "":
+		req.ContentType = "text/plain"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Only .txt and .md files are supported, extract PDF text before uploading"})
+		return false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadBytes))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return false
+	}
+	if !utf8.Valid(data) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "File must be UTF-8 encoded text"})
+		return false
+	}
+
+	req.Content = string(data)
+	req.Title = c.PostForm("title")
+	if req.Title == "" {
+		req.Title = fileHeader.Filename
+	}
+	return true
+}
+
+// writeError 将 Service 层错误映射为 HTTP 响应
+func (h *DocumentHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDocumentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "Document not found"})
+	case errors.Is(err, service.ErrDocumentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "Forbidden"})
+	case errors.Is(err, service.ErrDocumentEmpty), errors.Is(err, service.ErrDocumentTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 校验管理接口的访问令牌
+// 请求需携带 Authorization: Bearer <admin_token>，令牌按常量时间比较
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provided, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+			return
+		}
+		ctx.Next()
+	}
+}
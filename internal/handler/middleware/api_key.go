@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyMiddleware 同时接受个人 API Key 与 JWT 的登录校验
+// Authorization: Bearer coca_... 按 API Key 校验 (含权限范围)，其他 Token 交给 LoginJWTMiddleware
+type APIKeyMiddleware struct {
+	svc *service.APIKeyService
+	jwt *LoginJWTMiddleware
+}
+
+func NewAPIKeyMiddleware(svc *service.APIKeyService, jwt *LoginJWTMiddleware) *APIKeyMiddleware {
+	return &APIKeyMiddleware{
+		svc: svc,
+		jwt: jwt,
+	}
+}
+
+// Check 检查登录状态，使用 API Key 时要求拥有 scope 权限
+func (m *APIKeyMiddleware) Check(scope string) gin.HandlerFunc {
+	jwtCheck := m.jwt.Check()
+	return func(ctx *gin.Context) {
+		tokenStr := m.jwt.extractToken(ctx)
+		if !strings.HasPrefix(tokenStr, domain.APIKeyPrefix) {
+			jwtCheck(ctx)
+			return
+		}
+
+		key, err := m.svc.Authenticate(ctx.Request.Context(), tokenStr, scope)
+		switch {
+		case errors.Is(err, service.ErrAPIKeyScopeDenied):
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		case err != nil:
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		// 与 JWT 一致写入 uid，后续 Handler 无需区分认证方式
+		ctx.Set("uid", key.UserID)
+		ctx.Set("api_key", key)
+
+		ctx.Next()
+	}
+}
@@ -66,6 +66,10 @@ func (m *LoginJWTMiddleware) Check() gin.HandlerFunc {
 func (m *LoginJWTMiddleware) extractToken(ctx *gin.Context) string {
 	authCode := ctx.GetHeader("Authorization")
 	if authCode == "" {
+		// 浏览器无法为 WebSocket 握手设置请求头，允许通过查询参数携带 Access Token
+		if ctx.IsWebsocket() {
+			return ctx.Query("access_token")
+		}
 		return ""
 	}
 	segs := strings.Split(authCode, " ")
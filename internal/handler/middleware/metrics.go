@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"coca-ai/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录 HTTP 请求耗时
+// 按路由模板而非实际路径统计，避免路径参数导致标签基数膨胀；未匹配的路由记为 unmatched
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/domain"
+	"coca-ai/pkg/ratelimit"
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认限流规则
+var (
+	DefaultSendRule   = ratelimit.Rule{Limit: 20, Window: time.Minute}
+	DefaultLoginRule  = ratelimit.Rule{Limit: 10, Window: time.Minute}
+	DefaultSignupRule = ratelimit.Rule{Limit: 5, Window: time.Hour}
+)
+
+// RateLimitMiddleware 限流中间件
+// 计数保存在 Redis 中多实例共享，Redis.FailOpen 开启时 Redis 故障退化为单机内存限流
+type RateLimitMiddleware struct {
+	limiter ratelimit.Limiter
+	send    ratelimit.Rule
+	login   ratelimit.Rule
+	signup  ratelimit.Rule
+}
+
+func NewRateLimitMiddleware(limiter ratelimit.Limiter) *RateLimitMiddleware {
+	cfg := config.Get().RateLimit
+	return &RateLimitMiddleware{
+		limiter: limiter,
+		send:    toRule(cfg.Send, DefaultSendRule),
+		login:   toRule(cfg.Login, DefaultLoginRule),
+		signup:  toRule(cfg.Signup, DefaultSignupRule),
+	}
+}
+
+// Send 发送消息限流，需放在登录校验之后
+// API Key 请求同时按 Key 与所属用户计数，同一用户的多个 Key 共享用户额度
+func (m *RateLimitMiddleware) Send() gin.HandlerFunc {
+	return m.limitKeys("send", m.send, ClientKeys)
+}
+
+// Login 登录限流
+func (m *RateLimitMiddleware) Login() gin.HandlerFunc {
+	return m.Limit("login", m.login, func(ctx *gin.Context) string {
+		return "ip:" + ctx.ClientIP()
+	})
+}
+
+// Signup 注册限流
+func (m *RateLimitMiddleware) Signup() gin.HandlerFunc {
+	return m.Limit("signup", m.signup, func(ctx *gin.Context) string {
+		return "ip:" + ctx.ClientIP()
+	})
+}
+
+// AllowSend 判断一次发送是否允许，供不经过 HTTP 中间件的入口 (如 WebSocket 帧) 使用
+// keys 由 ClientKeys 生成
+func (m *RateLimitMiddleware) AllowSend(ctx context.Context, keys []string) (ratelimit.Result, error) {
+	if !m.send.Enabled() {
+		return ratelimit.Result{Allowed: true}, nil
+	}
+	return m.allowAll(ctx, "send", m.send, keys)
+}
+
+// Limit 按 rule 限流，name 区分不同规则，keyFunc 从请求中提取计数对象
+// 可用于任意路由或路由组，超限时返回 429 并带上 Retry-After
+func (m *RateLimitMiddleware) Limit(name string, rule ratelimit.Rule, keyFunc func(ctx *gin.Context) string) gin.HandlerFunc {
+	return m.limitKeys(name, rule, func(ctx *gin.Context) []string {
+		return []string{keyFunc(ctx)}
+	})
+}
+
+// limitKeys 按 rule 限流，keysFunc 返回的每个计数对象都不得超限
+func (m *RateLimitMiddleware) limitKeys(name string, rule ratelimit.Rule, keysFunc func(ctx *gin.Context) []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !rule.Enabled() {
+			ctx.Next()
+			return
+		}
+
+		res, err := m.allowAll(ctx.Request.Context(), name, rule, keysFunc(ctx))
+		if err != nil {
+			// 与登录校验一致：Redis 故障且未开启 FailOpen 时拒绝请求
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		if !res.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(RetryAfterSeconds(res.RetryAfter)))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"code": 429,
+				"msg":  "请求过于频繁，请稍后再试",
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// allowAll 依次判断各计数对象，任一超限即拒绝，返回剩余次数最少的结果
+// 先判断的对象已计入窗口，被后续对象拒绝时不回退
+func (m *RateLimitMiddleware) allowAll(ctx context.Context, name string, rule ratelimit.Rule, keys []string) (ratelimit.Result, error) {
+	result := ratelimit.Result{Allowed: true, Remaining: rule.Limit}
+	for _, key := range keys {
+		res, err := m.limiter.Allow(ctx, name+":"+key, rule)
+		if err != nil || !res.Allowed {
+			return res, err
+		}
+		result.Remaining = min(result.Remaining, res.Remaining)
+	}
+	return result, nil
+}
+
+// ClientKeys 返回请求的限流对象：登录用户按用户计数，API Key 请求另按 Key 计数，其余按 IP 计数
+func ClientKeys(ctx *gin.Context) []string {
+	uid := ctx.GetInt64("uid")
+	if uid == 0 {
+		return []string{"ip:" + ctx.ClientIP()}
+	}
+	keys := []string{"user:" + strconv.FormatInt(uid, 10)}
+	if v, ok := ctx.Get("api_key"); ok {
+		if key, ok := v.(*domain.APIKey); ok {
+			keys = append(keys, "key:"+strconv.FormatInt(key.ID, 10))
+		}
+	}
+	return keys
+}
+
+// RetryAfterSeconds 将等待时长向上取整为秒 (至少 1 秒)
+func RetryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
+
+// toRule 将配置转换为限流规则，未配置时使用默认规则，limit 为负数时不限流
+func toRule(cfg config.RateLimitRule, def ratelimit.Rule) ratelimit.Rule {
+	if cfg.Limit < 0 {
+		return ratelimit.Rule{}
+	}
+	rule := def
+	if cfg.Limit > 0 {
+		rule.Limit = cfg.Limit
+	}
+	if cfg.WindowSeconds > 0 {
+		rule.Window = time.Duration(cfg.WindowSeconds) * time.Second
+	}
+	return rule
+}
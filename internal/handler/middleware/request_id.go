@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"coca-ai/pkg/logger"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID 请求 ID 头
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLen 客户端传入的请求 ID 最大长度，超出或包含非法字符时重新生成
+const maxRequestIDLen = 64
+
+// RequestID 为每个请求分配请求 ID
+// 优先沿用客户端或网关传入的 X-Request-ID，写入响应头与 Request.Context，供下游日志与 Kafka 消息携带
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx.Header(HeaderRequestID, id)
+		ctx.Set("request_id", id)
+		ctx.Request = ctx.Request.WithContext(logger.WithRequestID(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
+
+// AccessLog 记录访问日志
+// 只记录路由与路径，不记录查询参数与请求体，避免泄露凭证与消息内容
+func AccessLog(l *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+		if uid := ctx.GetInt64("uid"); uid > 0 {
+			attrs = append(attrs, slog.Int64("uid", uid))
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", ctx.Errors.String()))
+		}
+		l.LogAttrs(ctx.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
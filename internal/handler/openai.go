@@ -0,0 +1,427 @@
+package handler
+
+import (
+	"coca-ai/internal/llm"
+	"coca-ai/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OpenAIHandler OpenAI 兼容接口 (/v1)
+// 供已使用 OpenAI SDK 的内部工具直接接入
+type OpenAIHandler struct {
+	completionSvc *service.CompletionService
+}
+
+// NewOpenAIHandler 创建 OpenAIHandler 实例
+func NewOpenAIHandler(completionSvc *service.CompletionService) *OpenAIHandler {
+	return &OpenAIHandler{completionSvc: completionSvc}
+}
+
+// ==================== Request/Response 结构体定义 ====================
+
+// ChatCompletionReq Chat Completions 请求
+type ChatCompletionReq struct {
+	Model       string               `json:"model"`
+	Messages    []ChatCompletionMsg  `json:"messages" binding:"required,min=1"`
+	Stream      bool                 `json:"stream"`
+	Temperature *float32             `json:"temperature" binding:"omitempty,gte=0,lte=2"`
+	TopP        *float32             `json:"top_p" binding:"omitempty,gt=0,lte=1"`
+	MaxTokens   *int                 `json:"max_tokens" binding:"omitempty,gt=0"`
+	Stop        json.RawMessage      `json:"stop"` // string 或 []string
+	Tools       []ChatCompletionTool `json:"tools"`
+	// StreamOptions 流式选项，include_usage 为 true 时在 [DONE] 前输出一个携带用量的分片
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	// SessionID 扩展字段：绑定 coca 会话，本轮问答写入会话历史；也可通过 X-Session-ID 请求头指定
+	SessionID int64 `json:"session_id,omitempty"`
+}
+
+// ChatCompletionMsg Chat Completions 消息
+type ChatCompletionMsg struct {
+	Role       string                   `json:"role"`
+	Content    json.RawMessage          `json:"content,omitempty"` // string 或 [{type, text}]
+	ToolCalls  []ChatCompletionToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string                   `json:"tool_call_id,omitempty"`
+}
+
+// ChatCompletionTool 请求中提供给模型的工具
+type ChatCompletionTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// ChatCompletionToolCall 模型发起的工具调用
+type ChatCompletionToolCall struct {
+	Index    *int   `json:"index,omitempty"` // 仅流式响应使用
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatCompletionResp 非流式响应
+type ChatCompletionResp struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   *ChatCompletionUsage   `json:"usage,omitempty"`
+}
+
+// ChatCompletionChoice 非流式响应的候选回复
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionRespMsg `json:"message"`
+	FinishReason string                `json:"finish_reason"`
+}
+
+// ChatCompletionRespMsg 响应中的 assistant 消息
+type ChatCompletionRespMsg struct {
+	Role      string                   `json:"role,omitempty"`
+	Content   *string                  `json:"content,omitempty"`
+	ToolCalls []ChatCompletionToolCall `json:"tool_calls,omitempty"`
+}
+
+// ChatCompletionChunk 流式响应分片
+type ChatCompletionChunk struct {
+	ID      string                `json:"id"`
+	Object  string                `json:"object"`
+	Created int64                 `json:"created"`
+	Model   string                `json:"model"`
+	Choices []ChatCompletionDelta `json:"choices"`
+	Usage   *ChatCompletionUsage  `json:"usage,omitempty"`
+}
+
+// ChatCompletionUsage token 用量
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletionDelta 流式响应分片中的增量
+type ChatCompletionDelta struct {
+	Index        int                   `json:"index"`
+	Delta        ChatCompletionRespMsg `json:"delta"`
+	FinishReason *string               `json:"finish_reason"`
+}
+
+// ModelItem 模型列表项
+type ModelItem struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ==================== 路由注册 ====================
+
+// RegisterRoutes 注册 OpenAI 兼容路由
+func (h *OpenAIHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc, sendLimiter gin.HandlerFunc) {
+	v1 := server.Group("/v1")
+	v1.Use(authMiddleware)
+	{
+		v1.POST("/chat/completions", sendLimiter, h.ChatCompletions)
+		v1.GET("/models", h.ListModels)
+	}
+}
+
+// ==================== Handler 方法 ====================
+
+// ChatCompletions 对话补全，stream=true 时以 SSE 输出
+// POST /v1/chat/completions
+func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		writeOpenAIError(c, http.StatusUnauthorized, "invalid_api_key", "Unauthorized")
+		return
+	}
+
+	var req ChatCompletionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if req.SessionID == 0 {
+		if header := c.GetHeader("X-Session-ID"); header != "" {
+			sessionID, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "Invalid X-Session-ID")
+				return
+			}
+			req.SessionID = sessionID
+		}
+	}
+
+	completionReq, err := toCompletionRequest(userID, &req)
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	model, err := h.completionSvc.Prepare(c.Request.Context(), completionReq)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	id := "chatcmpl-" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	created := time.Now().Unix()
+
+	if !req.Stream {
+		reply, err := h.completionSvc.Complete(c.Request.Context(), completionReq, nil)
+		if err != nil {
+			h.writeError(c, err)
+			return
+		}
+		// 故障转移时实际使用的模型可能与请求不同
+		if reply.Model != "" {
+			model = reply.Model
+		}
+		c.JSON(http.StatusOK, ChatCompletionResp{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   model,
+			Choices: []ChatCompletionChoice{{
+				Message:      toRespMessage(reply, false),
+				FinishReason: finishReasonOf(reply),
+			}},
+			Usage: toUsage(reply),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	writeChunk := func(delta ChatCompletionRespMsg, finishReason *string) {
+		data, _ := json.Marshal(ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChatCompletionDelta{{Delta: delta, FinishReason: finishReason}},
+		})
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		c.Writer.Flush()
+	}
+
+	empty := ""
+	writeChunk(ChatCompletionRespMsg{Role: "assistant", Content: &empty}, nil)
+	reply, err := h.completionSvc.Complete(c.Request.Context(), completionReq, func(delta string) error {
+		writeChunk(ChatCompletionRespMsg{Content: &delta}, nil)
+		return nil
+	})
+	if err != nil {
+		// 流已开始，按 OpenAI 的约定在流中返回错误
+		data, _ := json.Marshal(gin.H{"error": gin.H{"message": err.Error(), "type": "server_error"}})
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		c.Writer.Flush()
+		return
+	}
+	if len(reply.ToolCalls) > 0 {
+		writeChunk(ChatCompletionRespMsg{ToolCalls: toRespMessage(reply, true).ToolCalls}, nil)
+	}
+	finishReason := finishReasonOf(reply)
+	writeChunk(ChatCompletionRespMsg{}, &finishReason)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		data, _ := json.Marshal(ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChatCompletionDelta{},
+			Usage:   toUsage(reply),
+		})
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		c.Writer.Flush()
+	}
+	_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// ListModels 获取可用模型列表
+// GET /v1/models
+func (h *OpenAIHandler) ListModels(c *gin.Context) {
+	models := h.completionSvc.ListModels()
+	items := make([]ModelItem, len(models))
+	for i, m := range models {
+		items[i] = ModelItem{ID: m, Object: "model", OwnedBy: "coca-ai"}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   items,
+	})
+}
+
+// writeError 将 Service 层错误映射为 OpenAI 格式的错误响应
+func (h *OpenAIHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrModelNotSupported):
+		writeOpenAIError(c, http.StatusNotFound, "model_not_found", err.Error())
+	case errors.Is(err, service.ErrSessionNotFound):
+		writeOpenAIError(c, http.StatusNotFound, "invalid_request_error", "Session not found")
+	case errors.Is(err, service.ErrSessionForbidden):
+		writeOpenAIError(c, http.StatusForbidden, "invalid_request_error", "Forbidden")
+	case errors.Is(err, service.ErrQuotaExceeded):
+		writeOpenAIError(c, http.StatusTooManyRequests, "insufficient_quota", err.Error())
+	default:
+		writeOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+}
+
+// writeOpenAIError 输出 OpenAI 格式的错误
+func writeOpenAIError(c *gin.Context, status int, errType string, msg string) {
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message": msg,
+			"type":    errType,
+		},
+	})
+}
+
+// toCompletionRequest 将 OpenAI 请求转换为补全请求
+func toCompletionRequest(userID int64, req *ChatCompletionReq) (*service.CompletionRequest, error) {
+	messages := make([]llm.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		content, err := parseContent(m.Content)
+		if err != nil {
+			return nil, err
+		}
+		msg := llm.Message{Role: m.Role, Content: content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		messages = append(messages, msg)
+	}
+
+	var opts []llm.Option
+	if req.Model != "" {
+		opts = append(opts, llm.WithModel(req.Model))
+	}
+	if req.Temperature != nil {
+		opts = append(opts, llm.WithTemperature(*req.Temperature))
+	}
+	if req.TopP != nil {
+		opts = append(opts, llm.WithTopP(*req.TopP))
+	}
+	if req.MaxTokens != nil {
+		opts = append(opts, llm.WithMaxTokens(*req.MaxTokens))
+	}
+	if len(req.Stop) > 0 && string(req.Stop) != "null" {
+		var stop []string
+		if err := json.Unmarshal(req.Stop, &stop); err != nil {
+			var single string
+			if err := json.Unmarshal(req.Stop, &single); err != nil {
+				return nil, errors.New("stop must be a string or an array of strings")
+			}
+			stop = []string{single}
+		}
+		opts = append(opts, llm.WithStop(stop))
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]llm.ToolDefinition, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			tools = append(tools, llm.ToolDefinition{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			})
+		}
+		opts = append(opts, llm.WithTools(tools))
+	}
+
+	return &service.CompletionRequest{
+		UserID:    userID,
+		SessionID: req.SessionID,
+		Messages:  messages,
+		Options:   opts,
+	}, nil
+}
+
+// parseContent 解析消息内容，数组形式只保留文本部分
+func parseContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("content must be a string or an array of content parts")
+	}
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.Type == "text" {
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String(), nil
+}
+
+// toRespMessage 将模型回复转换为响应消息，indexed 为 true 时工具调用带 index (流式)
+func toRespMessage(reply *llm.Message, indexed bool) ChatCompletionRespMsg {
+	msg := ChatCompletionRespMsg{Role: "assistant", Content: &reply.Content}
+	if len(reply.ToolCalls) > 0 && reply.Content == "" {
+		msg.Content = nil
+	}
+	for i, call := range reply.ToolCalls {
+		item := ChatCompletionToolCall{ID: call.ID, Type: "function"}
+		item.Function.Name = call.Name
+		item.Function.Arguments = call.Arguments
+		if indexed {
+			index := i
+			item.Index = &index
+		}
+		msg.ToolCalls = append(msg.ToolCalls, item)
+	}
+	return msg
+}
+
+// toUsage 转换为 OpenAI 格式的用量
+func toUsage(reply *llm.Message) *ChatCompletionUsage {
+	return &ChatCompletionUsage{
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		TotalTokens:      reply.Usage.Total(),
+	}
+}
+
+// finishReasonOf 返回回复的结束原因，Provider 未提供时按是否调用工具推断
+func finishReasonOf(reply *llm.Message) string {
+	if reply.FinishReason != "" {
+		return reply.FinishReason
+	}
+	if len(reply.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
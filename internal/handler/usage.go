@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"coca-ai/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler 处理用户 token 用量查询
+type UsageHandler struct {
+	svc *service.UsageService
+}
+
+// NewUsageHandler 创建 UsageHandler 实例
+func NewUsageHandler(svc *service.UsageService) *UsageHandler {
+	return &UsageHandler{svc: svc}
+}
+
+// ==================== Request/Response 结构体定义 ====================
+
+// DailyUsageItem 每日用量
+type DailyUsageItem struct {
+	Day              string `json:"day"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	Requests         int64  `json:"requests"`
+}
+
+// UsageResp 用量查询响应
+type UsageResp struct {
+	Days         []DailyUsageItem `json:"days"`
+	MonthTokens  int64            `json:"month_tokens"`
+	MonthlyQuota int64            `json:"monthly_quota"` // 0 表示不限额
+	Remaining    int64            `json:"remaining"`     // -1 表示不限额
+}
+
+// ==================== 路由注册 ====================
+
+// RegisterRoutes 注册用量查询路由
+func (h *UsageHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+	server.GET("/users/me/usage", authMiddleware, h.GetUsage)
+}
+
+// ==================== Handler 方法 ====================
+
+// GetUsage 查询最近若干天的每日用量与本月配额
+// GET /users/me/usage?days=30
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	days := service.DefaultUsageDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > service.MaxUsageDays {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid days"})
+			return
+		}
+		days = n
+	}
+
+	report, err := h.svc.GetUsage(c.Request.Context(), userID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	resp := UsageResp{
+		Days:         make([]DailyUsageItem, len(report.Days)),
+		MonthTokens:  report.MonthTokens,
+		MonthlyQuota: report.MonthlyQuota,
+		Remaining:    report.Remaining(),
+	}
+	for i, day := range report.Days {
+		resp.Days[i] = DailyUsageItem{
+			Day:              day.Day,
+			PromptTokens:     day.PromptTokens,
+			CompletionTokens: day.CompletionTokens,
+			TotalTokens:      day.TotalTokens(),
+			Requests:         day.Requests,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": resp,
+	})
+}
@@ -25,11 +25,11 @@ func NewUserHandler(svc service.UserService, jwtHandler *jwtx.JWTHandler) *UserH
 }
 
 // RegisterRoutes 注册路由
-func (h *UserHandler) RegisterRoutes(server *gin.Engine, md *middleware.LoginJWTMiddleware) {
+func (h *UserHandler) RegisterRoutes(server *gin.Engine, md *middleware.LoginJWTMiddleware, limiter *middleware.RateLimitMiddleware) {
 	userGroup := server.Group("/users")
 	{
-		userGroup.POST("/signup", h.SignUp)
-		userGroup.POST("/login", h.Login)
+		userGroup.POST("/signup", limiter.Signup(), h.SignUp)
+		userGroup.POST("/login", limiter.Login(), h.Login)
 		userGroup.POST("/refresh_token", h.RefreshToken)
 		// 只有 Logout 需要登录保护
 		userGroup.POST("/logout", md.Check(), h.Logout)
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/handler/middleware"
+	"coca-ai/internal/service"
+	"coca-ai/pkg/logger"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsMaxFrameSize = 64 * 1024
+	wsSendBuffer   = 256
+)
+
+// WebSocket 客户端帧类型
+const (
+	WSFrameSend   = "send"   // 发送消息
+	WSFrameStop   = "stop"   // 停止生成
+	WSFrameTyping = "typing" // 正在输入
+	WSFramePing   = "ping"   // 心跳
+)
+
+// WSHandler 处理 WebSocket 聊天连接
+// 一个连接可同时进行多个会话的对话，并接收该用户在其他设备上的会话变更
+type WSHandler struct {
+	chatSvc   *service.ChatService
+	streamSvc *service.StreamService
+	events    *service.SessionEventBus
+	limiter   *middleware.RateLimitMiddleware
+	upgrader  websocket.Upgrader
+	l         *slog.Logger
+
+	// 已建立的连接，停机时逐一关闭 (被接管的连接不受 http.Server.Shutdown 管理)
+	mu       sync.Mutex
+	clients  map[*wsClient]struct{}
+	shutdown bool
+}
+
+// NewWSHandler 创建 WSHandler 实例
+func NewWSHandler(chatSvc *service.ChatService, streamSvc *service.StreamService, events *service.SessionEventBus, limiter *middleware.RateLimitMiddleware, l *slog.Logger) *WSHandler {
+	return &WSHandler{
+		chatSvc:   chatSvc,
+		streamSvc: streamSvc,
+		events:    events,
+		limiter:   limiter,
+		clients:   make(map[*wsClient]struct{}),
+		l:         l.With("component", "ws_handler"),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// 与 CORS 配置保持一致，开发环境允许所有来源
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// WSRequest 客户端发送的帧
+type WSRequest struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"` // 客户端自定义的请求 ID，响应中原样带回
+	SessionID int64  `json:"session_id"`
+	Content   string `json:"content,omitempty"`
+}
+
+// WSResponse 服务端推送的帧
+// Type 为流式事件 (message/tool_call/tool_result/context/done/error)、会话事件 (session.*) 或 pong
+type WSResponse struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	SessionID int64  `json:"session_id,omitempty"`
+	Data      any    `json:"data,omitempty"`
+}
+
+// RegisterRoutes 注册 WebSocket 路由
+func (h *WSHandler) RegisterRoutes(server *gin.Engine, authMiddleware gin.HandlerFunc) {
+	server.GET("/chat/ws", authMiddleware, h.Serve)
+}
+
+// Serve 建立 WebSocket 连接
+// GET /chat/ws (Access Token 通过 Authorization 请求头或 access_token 查询参数携带)
+func (h *WSHandler) Serve(c *gin.Context) {
+	userID := c.GetInt64("uid")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Unauthorized"})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已写入错误响应
+		h.l.WarnContext(c.Request.Context(), "upgrade failed", logger.Error(err))
+		return
+	}
+
+	// 连接计入后台任务，停机时等待其关闭
+	defer h.streamSvc.Track()()
+	client := newWSClient(c.Request.Context(), conn, userID, middleware.ClientKeys(c))
+	defer client.close()
+	if !h.register(client) {
+		client.goAway()
+		return
+	}
+	defer h.unregister(client)
+
+	events, unsubscribe := h.events.Subscribe(userID)
+	defer unsubscribe()
+
+	go client.writeLoop()
+	go client.forward(events)
+	h.readLoop(client)
+}
+
+// Shutdown 停机时关闭全部连接并拒绝新连接，通过 http.Server.RegisterOnShutdown 注册
+// 进行中的生成不受影响，继续在后台完成并保存
+func (h *WSHandler) Shutdown() {
+	h.mu.Lock()
+	h.shutdown = true
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.goAway()
+	}
+}
+
+// register 登记连接，已停机时返回 false
+func (h *WSHandler) register(client *wsClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return false
+	}
+	h.clients[client] = struct{}{}
+	return true
+}
+
+// unregister 移除连接
+func (h *WSHandler) unregister(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, client)
+}
+
+// readLoop 读取并处理客户端帧，直到连接关闭
+func (h *WSHandler) readLoop(client *wsClient) {
+	client.conn.SetReadLimit(wsMaxFrameSize)
+	_ = client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var req WSRequest
+		if err := client.conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.l.WarnContext(client.ctx, "read frame failed", logger.Error(err), "uid", client.userID)
+			}
+			return
+		}
+		_ = client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		switch req.Type {
+		case WSFrameSend:
+			h.handleSend(client, req)
+		case WSFrameStop:
+			if err := h.chatSvc.StopGeneration(client.ctx, client.userID, req.SessionID); err != nil {
+				client.sendError(req, err)
+			}
+		case WSFrameTyping:
+			if err := h.chatSvc.NotifyTyping(client.ctx, client.userID, req.SessionID, client.id); err != nil {
+				client.sendError(req, err)
+			}
+		case WSFramePing:
+			client.push(WSResponse{Type: "pong", RequestID: req.RequestID})
+		default:
+			client.push(WSResponse{Type: service.GenerationEventError, RequestID: req.RequestID, SessionID: req.SessionID,
+				Data: gin.H{"msg": "unknown frame type: " + req.Type}})
+		}
+	}
+}
+
+// handleSend 在后台生成回复并推送流式事件
+// 同一会话同时只允许一个生成；连接断开后生成继续进行，回复照常保存
+func (h *WSHandler) handleSend(client *wsClient, req WSRequest) {
+	if req.Content == "" {
+		client.push(WSResponse{Type: service.GenerationEventError, RequestID: req.RequestID, SessionID: req.SessionID,
+			Data: gin.H{"msg": "content is required"}})
+		return
+	}
+	// WebSocket 帧不经过 HTTP 限流中间件，这里与 HTTP 发送共用同一计数
+	res, err := h.limiter.AllowSend(client.ctx, client.limitKeys)
+	if err != nil {
+		client.sendError(req, err)
+		return
+	}
+	if !res.Allowed {
+		client.push(WSResponse{Type: service.GenerationEventError, RequestID: req.RequestID, SessionID: req.SessionID,
+			Data: gin.H{"msg": "rate limited", "retry_after": middleware.RetryAfterSeconds(res.RetryAfter)}})
+		return
+	}
+	if !client.begin(req.SessionID) {
+		client.push(WSResponse{Type: service.GenerationEventError, RequestID: req.RequestID, SessionID: req.SessionID,
+			Data: gin.H{"msg": "generation in progress"}})
+		return
+	}
+
+	done := h.streamSvc.Track()
+	go func() {
+		defer done()
+		defer client.end(req.SessionID)
+
+		ctx := context.WithoutCancel(client.ctx)
+		assistantMsg, err := h.chatSvc.SendMessage(ctx, client.userID, req.SessionID, req.Content, func(event service.StreamEvent) error {
+			if name, data := streamEventPayload(event); name != "" {
+				client.push(WSResponse{Type: name, RequestID: req.RequestID, SessionID: req.SessionID, Data: data})
+			}
+			return nil
+		})
+		if err != nil {
+			client.sendError(req, err)
+			return
+		}
+		client.push(WSResponse{Type: service.GenerationEventDone, RequestID: req.RequestID, SessionID: req.SessionID,
+			Data: donePayload(assistantMsg)})
+	}()
+}
+
+// wsClient 一个 WebSocket 连接
+type wsClient struct {
+	id        string
+	userID    int64
+	limitKeys []string // 发送限流的计数对象
+	conn      *websocket.Conn
+	send      chan WSResponse
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	active map[int64]bool // 正在生成的会话
+}
+
+// newWSClient 创建连接，连接的 context 保留握手请求的请求 ID 与链路信息，但不随请求结束而取消
+func newWSClient(parent context.Context, conn *websocket.Conn, userID int64, limitKeys []string) *wsClient {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	return &wsClient{
+		id:        uuid.New().String(),
+		userID:    userID,
+		limitKeys: limitKeys,
+		conn:      conn,
+		send:      make(chan WSResponse, wsSendBuffer),
+		ctx:       ctx,
+		cancel:    cancel,
+		active:    make(map[int64]bool),
+	}
+}
+
+// push 推送一帧，连接关闭后直接丢弃
+func (c *wsClient) push(resp WSResponse) {
+	select {
+	case c.send <- resp:
+	case <-c.ctx.Done():
+	}
+}
+
+// sendError 推送错误帧
+func (c *wsClient) sendError(req WSRequest, err error) {
+	c.push(WSResponse{Type: service.GenerationEventError, RequestID: req.RequestID, SessionID: req.SessionID,
+		Data: gin.H{"msg": err.Error()}})
+}
+
+// forward 推送该用户的会话事件，跳过本连接发起的事件
+func (c *wsClient) forward(events <-chan domain.SessionEvent) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case event := <-events:
+			if event.Origin == c.id {
+				continue
+			}
+			c.push(WSResponse{Type: string(event.Type), SessionID: event.SessionID, Data: event})
+		}
+	}
+}
+
+// writeLoop 串行写出推送帧并定时发送 ping
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case resp := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteJSON(resp); err != nil {
+				c.cancel()
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+// begin 标记会话开始生成，已在生成中返回 false
+func (c *wsClient) begin(sessionID int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[sessionID] {
+		return false
+	}
+	c.active[sessionID] = true
+	return true
+}
+
+// end 标记会话生成结束
+func (c *wsClient) end(sessionID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.active, sessionID)
+}
+
+// goAway 通知客户端服务端即将停机并关闭连接，客户端应重连到其他实例
+func (c *wsClient) goAway() {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+	c.close()
+}
+
+// close 关闭连接
+func (c *wsClient) close() {
+	c.cancel()
+	_ = c.conn.Close()
+}
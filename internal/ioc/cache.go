@@ -16,3 +16,18 @@ func InitMessageCache(cmd redis.Cmdable) *cache.MessageCache {
 	}
 	return cache.NewMessageCache(cmd, maxLen)
 }
+
+// InitSummaryCache 初始化会话摘要缓存
+func InitSummaryCache(cmd redis.Cmdable) *cache.SummaryCache {
+	return cache.NewSummaryCache(cmd)
+}
+
+// InitGenerationCache 初始化生成事件缓存
+func InitGenerationCache(cmd redis.Cmdable) *cache.GenerationCache {
+	return cache.NewGenerationCache(cmd)
+}
+
+// InitResponseCache 初始化模型回复缓存
+func InitResponseCache(cmd redis.Cmdable) *cache.ResponseCache {
+	return cache.NewResponseCache(cmd)
+}
@@ -1,15 +1,23 @@
 package ioc
 
 import (
+	"coca-ai/internal/config"
 	"coca-ai/internal/repository/dao"
+	"coca-ai/pkg/logger"
+	"log/slog"
 	"os"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-func InitDB() *gorm.DB {
+// gormSlowThreshold 超过该耗时的 SQL 记录为慢查询
+const gormSlowThreshold = 200 * time.Millisecond
+
+func InitDB(l *slog.Logger) *gorm.DB {
 	// Default DSN for local development
 	dsn := "root:root@tcp(localhost:13306)/coca_db?charset=utf8mb4&parseTime=True&loc=Local"
 
@@ -23,17 +31,39 @@ func InitDB() *gorm.DB {
 	}
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newGormLogger(l),
 	})
 	if err != nil {
 		panic(err)
 	}
+	// 为每条 SQL 创建 Span (指标由 Prometheus 单独采集)，与日志一致不记录参数值
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		panic(err)
+	}
 
 	// 自动迁移表结构 (Auto Migration)
-	err = db.AutoMigrate(&dao.User{}, &dao.Session{}, &dao.Message{})
+	err = db.AutoMigrate(&dao.User{}, &dao.Session{}, &dao.Message{}, &dao.Document{}, &dao.DocumentChunk{}, &dao.Summary{}, &dao.APIKey{}, &dao.UserDailyUsage{}, &dao.OutboxEvent{})
 	if err != nil {
 		panic(err)
 	}
 
 	return db
 }
+
+// newGormLogger 将 GORM 日志输出到 slog，SQL 中只记录占位符，不记录参数值
+// 日志级别为 debug 时记录全部 SQL，否则只记录慢查询与错误
+func newGormLogger(l *slog.Logger) gormlogger.Interface {
+	level := gormlogger.Warn
+	switch logger.ParseLevel(config.Get().Logger.Level) {
+	case slog.LevelDebug:
+		level = gormlogger.Info
+	case slog.LevelError:
+		level = gormlogger.Error
+	}
+	return gormlogger.NewSlogLogger(l.With("component", "gorm"), gormlogger.Config{
+		LogLevel:                  level,
+		SlowThreshold:             gormSlowThreshold,
+		ParameterizedQueries:      true,
+		IgnoreRecordNotFoundError: true,
+	})
+}
@@ -0,0 +1,98 @@
+package ioc
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/repository/cache"
+	"coca-ai/pkg/logger"
+	"coca-ai/pkg/snowflake"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// nodeLeaseTTL 节点号租约有效期，实例存活期间每 1/3 有效期续期一次
+const nodeLeaseTTL = time.Minute
+
+// NodeLease 实例持有的 ID 生成节点号租约
+// 多实例共用 Redis 分配节点号，同一时刻每个节点号只属于一个实例，生成的消息 ID 不会重复
+type NodeLease struct {
+	cache  *cache.NodeCache
+	nodeID int64
+	owner  string
+	l      *slog.Logger
+}
+
+// InitNodeLease 从 Redis 租用 ID 生成节点号
+// 配置了 node_id 时租用该节点号，已被其他实例占用则启动失败；未配置时租用第一个空闲的节点号
+func InitNodeLease(client redis.Cmdable, l *slog.Logger) *NodeLease {
+	hostname, _ := os.Hostname()
+	lease := &NodeLease{
+		cache: cache.NewNodeCache(client),
+		owner: fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), uuid.NewString()),
+		l:     l.With("component", "node_lease"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	candidates := make([]int64, 0, snowflake.MaxNodeID+1)
+	if nodeID := config.Get().Server.NodeID; nodeID != nil {
+		candidates = append(candidates, *nodeID)
+	} else {
+		for id := int64(0); id <= snowflake.MaxNodeID; id++ {
+			candidates = append(candidates, id)
+		}
+	}
+	for _, id := range candidates {
+		ok, err := lease.cache.Acquire(ctx, id, lease.owner, nodeLeaseTTL)
+		if err != nil {
+			panic("Failed to acquire id generator node: " + err.Error())
+		}
+		if ok {
+			lease.nodeID = id
+			lease.l.Info("acquired id generator node", "node_id", id)
+			return lease
+		}
+	}
+	if len(candidates) == 1 {
+		panic(fmt.Sprintf("Failed to acquire id generator node: node %d is held by another instance", candidates[0]))
+	}
+	panic("Failed to acquire id generator node: all nodes are held by other instances")
+}
+
+// Keep 定期续期租约直到 ctx 结束，随后释放节点号
+func (n *NodeLease) Keep(ctx context.Context) error {
+	ticker := time.NewTicker(nodeLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+			defer cancel()
+			return n.cache.Release(releaseCtx, n.nodeID, n.owner)
+		case <-ticker.C:
+			ok, err := n.cache.Renew(ctx, n.nodeID, n.owner, nodeLeaseTTL)
+			if err != nil {
+				n.l.WarnContext(ctx, "renew id generator node failed", logger.Error(err), "node_id", n.nodeID)
+				continue
+			}
+			if !ok {
+				// 租约过期后被其他实例占用，两个实例的消息 ID 可能重复，由消费端的冲突检查拦截
+				n.l.ErrorContext(ctx, "id generator node taken by another instance", "node_id", n.nodeID)
+			}
+		}
+	}
+}
+
+// InitIDGenerator 以租用的节点号初始化消息 ID 生成器
+func InitIDGenerator(lease *NodeLease) *snowflake.Node {
+	node, err := snowflake.NewNode(lease.nodeID)
+	if err != nil {
+		panic("Failed to create id generator: " + err.Error())
+	}
+	return node
+}
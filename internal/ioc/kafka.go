@@ -3,10 +3,13 @@ package ioc
 import (
 	"coca-ai/internal/config"
 	"coca-ai/internal/mq"
+	"coca-ai/internal/repository/dao"
+	"coca-ai/internal/service"
+	"log/slog"
 )
 
 // InitKafkaProducer 初始化 Kafka 生产者
-func InitKafkaProducer() *mq.Producer {
+func InitKafkaProducer(l *slog.Logger) *mq.Producer {
 	cfg := config.Get()
 
 	if len(cfg.Kafka.Brokers) == 0 {
@@ -15,19 +18,19 @@ func InitKafkaProducer() *mq.Producer {
 	}
 
 	return mq.NewProducer(&mq.ProducerConfig{
-		Brokers:       cfg.Kafka.Brokers,
-		RequiredAcks:  cfg.Kafka.Producer.RequiredAcks,
-		Async:         cfg.Kafka.Producer.Async,
-		BatchSize:     cfg.Kafka.Producer.BatchSize,
-		BatchTimeout:  cfg.Kafka.Producer.BatchTimeoutMS,
-		Compression:   cfg.Kafka.Producer.Compression,
-		WriteTimeout:  cfg.Kafka.Producer.WriteTimeoutMS,
-		MaxAttempts:   cfg.Kafka.Producer.MaxAttempts,
-	})
+		Brokers:      cfg.Kafka.Brokers,
+		RequiredAcks: cfg.Kafka.Producer.RequiredAcks,
+		Async:        cfg.Kafka.Producer.Async,
+		BatchSize:    cfg.Kafka.Producer.BatchSize,
+		BatchTimeout: cfg.Kafka.Producer.BatchTimeoutMS,
+		Compression:  cfg.Kafka.Producer.Compression,
+		WriteTimeout: cfg.Kafka.Producer.WriteTimeoutMS,
+		MaxAttempts:  cfg.Kafka.Producer.MaxAttempts,
+	}, l)
 }
 
 // InitKafkaConsumer 初始化 Kafka 消费者
-func InitKafkaConsumer() *mq.Consumer {
+func InitKafkaConsumer(l *slog.Logger) *mq.Consumer {
 	cfg := config.Get()
 
 	if len(cfg.Kafka.Brokers) == 0 {
@@ -46,7 +49,40 @@ func InitKafkaConsumer() *mq.Consumer {
 		RetryBackoffMS: cfg.Kafka.Consumer.RetryBackoffMS,
 		CommitTimeout:  cfg.Kafka.Consumer.CommitTimeoutMS,
 		DLQTopic:       cfg.Kafka.DLQTopic,
-	})
+		BatchSize:      cfg.Kafka.Consumer.BatchSize,
+		BatchTimeoutMS: cfg.Kafka.Consumer.BatchTimeoutMS,
+		Workers:        cfg.Kafka.Consumer.Workers,
+	}, l)
+}
+
+// InitOutboxRelay 初始化发件箱转发任务
+func InitOutboxRelay(producer *mq.Producer, outboxDAO *dao.OutboxDAO, l *slog.Logger) *mq.OutboxRelay {
+	if producer == nil {
+		// 无 Kafka 时消息直接落库，不会写入发件箱
+		return nil
+	}
+
+	cfg := config.Get()
+	return mq.NewOutboxRelay(producer, outboxDAO, &mq.OutboxConfig{
+		IntervalMS:     cfg.Kafka.Outbox.IntervalMS,
+		BatchSize:      cfg.Kafka.Outbox.BatchSize,
+		MaxAttempts:    cfg.Kafka.Outbox.MaxAttempts,
+		RetentionHours: cfg.Kafka.Outbox.RetentionHours,
+	}, l)
+}
+
+// InitDLQ 初始化死信队列工具
+func InitDLQ(l *slog.Logger) *mq.DLQ {
+	cfg := config.Get()
+
+	if len(cfg.Kafka.Brokers) == 0 {
+		return nil
+	}
+
+	return mq.NewDLQ(&mq.DLQConfig{
+		Brokers: cfg.Kafka.Brokers,
+		Topic:   cfg.Kafka.DLQTopic,
+	}, l)
 }
 
 // BindKafkaHandlers 绑定 Kafka 消费处理器
@@ -56,6 +92,35 @@ func BindKafkaHandlers(consumer *mq.Consumer, handler *mq.MessagePersistHandler)
 	}
 	if handler != nil {
 		consumer.RegisterHandler(handler.Handle)
+		consumer.RegisterBatchHandler(handler.HandleBatch)
+	}
+	return consumer
+}
+
+// InitSummaryConsumer 初始化摘要任务消费者
+func InitSummaryConsumer(l *slog.Logger) *mq.SummaryConsumer {
+	cfg := config.Get()
+
+	if len(cfg.Kafka.Brokers) == 0 {
+		// 无 Kafka 时摘要在本地协程中生成
+		return nil
+	}
+
+	return mq.NewSummaryConsumer(&mq.ConsumerConfig{
+		Brokers:       cfg.Kafka.Brokers,
+		GroupID:       cfg.Kafka.Consumer.GroupID,
+		MaxBytes:      cfg.Kafka.Consumer.MaxBytes,
+		MaxWait:       cfg.Kafka.Consumer.MaxWaitMS,
+		StartOffset:   cfg.Kafka.Consumer.StartOffset,
+		CommitTimeout: cfg.Kafka.Consumer.CommitTimeoutMS,
+	}, l)
+}
+
+// BindSummaryHandler 绑定摘要任务处理器
+func BindSummaryHandler(consumer *mq.SummaryConsumer, summarySvc *service.SummaryService) *mq.SummaryConsumer {
+	if consumer == nil {
+		return nil
 	}
+	consumer.RegisterHandler(summarySvc.HandleEvent)
 	return consumer
 }
@@ -3,24 +3,137 @@ package ioc
 import (
 	"coca-ai/internal/config"
 	"coca-ai/internal/llm"
+	"coca-ai/internal/repository/cache"
+	"log/slog"
+	"time"
 )
 
+// legacyLLMProvider 旧版配置中 llm 下的全局字段 (及 QWEN_* 环境变量) 所描述的 Provider
+const legacyLLMProvider = "qwen"
+
 // InitLLMClient 初始化 LLM 客户端
-func InitLLMClient() llm.ChatClient {
+// 根据 llm.provider 从 Provider 注册表中选择主后端，llm.fallbacks 中的 Provider 依次作为备用后端，
+// 统一包装为带重试、故障转移、熔断与指标记录的客户端；开启回复缓存时再包装一层缓存
+func InitLLMClient(responseCache *cache.ResponseCache, l *slog.Logger) llm.ChatClient {
 	cfg := config.Get()
 
-	if cfg.LLM.APIKey == "" {
-		panic("LLM API Key is not configured. Set llm.api_key in config.yaml or QWEN_API_KEY environment variable.")
+	name := cfg.LLM.Provider
+	if name == "" {
+		name = legacyLLMProvider
+	}
+	backends := []llm.Backend{{Name: name, Client: newLLMProvider(&cfg.LLM, name)}}
+	for _, fb := range cfg.LLM.Fallbacks {
+		client := newLLMProvider(&cfg.LLM, fb.Provider)
+		model := fb.Model
+		if model == "" {
+			if lister, ok := client.(llm.ModelLister); ok {
+				model = lister.DefaultModel()
+			}
+		}
+		backends = append(backends, llm.Backend{Name: fb.Provider, Client: client, Model: model})
 	}
 
-	client, err := llm.NewQwenClient(&llm.QwenConfig{
-		APIKey:  cfg.LLM.APIKey,
-		BaseURL: cfg.LLM.BaseURL,
-		Model:   cfg.LLM.Model,
-	})
+	var client llm.ChatClient = llm.NewFailoverClient(backends, llmRetryPolicy(cfg.LLM.Retry), llmBreakerPolicy(cfg.LLM.CircuitBreaker), l)
+	// 指标只统计实际发往 Provider 的调用，缓存命中不计入
+	client = llm.NewInstrumentedClient(client)
+	if cacheCfg := cfg.LLM.ResponseCache; cacheCfg.Enabled {
+		client = llm.NewCachingClient(client, responseCache, llmResponseCacheConfig(cacheCfg), l)
+	}
+	return client
+}
+
+// llmResponseCacheConfig 回复缓存配置，未配置时缓存 1 小时、仅缓存单轮提问、回放间隔 10ms
+func llmResponseCacheConfig(cfg config.LLMResponseCacheConfig) llm.ResponseCacheConfig {
+	c := llm.ResponseCacheConfig{
+		TTL:            time.Hour,
+		MaxMessages:    1,
+		ReplayInterval: 10 * time.Millisecond,
+	}
+	if cfg.TTLSeconds > 0 {
+		c.TTL = time.Duration(cfg.TTLSeconds) * time.Second
+	}
+	if cfg.MaxMessages > 0 {
+		c.MaxMessages = cfg.MaxMessages
+	}
+	if cfg.ReplayIntervalMS != 0 {
+		c.ReplayInterval = time.Duration(max(cfg.ReplayIntervalMS, 0)) * time.Millisecond
+	}
+	return c
+}
+
+// newLLMProvider 创建指定名称的 Provider 客户端
+func newLLMProvider(cfg *config.LLMConfig, name string) llm.ChatClient {
+	driver, providerCfg := resolveLLMProvider(cfg, name)
+	client, err := llm.NewProvider(driver, providerCfg)
 	if err != nil {
 		panic("Failed to create LLM client: " + err.Error())
 	}
-
 	return client
 }
+
+// llmRetryPolicy 重试策略，未配置时默认重试 2 次，退避 500ms 起、最长 4s
+func llmRetryPolicy(cfg config.LLMRetryConfig) llm.RetryPolicy {
+	policy := llm.RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 4 * time.Second,
+	}
+	if cfg.MaxRetries != 0 {
+		policy.MaxRetries = max(cfg.MaxRetries, 0)
+	}
+	if cfg.BackoffMS > 0 {
+		policy.Backoff = time.Duration(cfg.BackoffMS) * time.Millisecond
+	}
+	if cfg.MaxBackoffMS > 0 {
+		policy.MaxBackoff = time.Duration(cfg.MaxBackoffMS) * time.Millisecond
+	}
+	return policy
+}
+
+// llmBreakerPolicy 熔断策略，未配置时连续失败 5 次熔断 30s
+func llmBreakerPolicy(cfg config.LLMCircuitBreakerConfig) llm.BreakerPolicy {
+	policy := llm.BreakerPolicy{
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+	if cfg.FailureThreshold != 0 {
+		policy.FailureThreshold = max(cfg.FailureThreshold, 0)
+	}
+	if cfg.CooldownSeconds > 0 {
+		policy.Cooldown = time.Duration(cfg.CooldownSeconds) * time.Second
+	}
+	return policy
+}
+
+// resolveLLMProvider 解析 Provider 配置
+// 仅旧版 Provider (qwen) 未配置的字段回落到 llm 下的全局字段 (兼容旧配置与环境变量覆盖)；
+// 其他 Provider 未配置的字段由驱动使用各自的默认值
+func resolveLLMProvider(cfg *config.LLMConfig, name string) (string, llm.ProviderConfig) {
+	p := cfg.Providers[name]
+
+	driver := p.Type
+	if driver == "" {
+		driver = name
+	}
+
+	pc := llm.ProviderConfig{
+		Name:    name,
+		BaseURL: p.BaseURL,
+		APIKey:  p.APIKey,
+		Model:   p.Model,
+		Models:  p.Models,
+	}
+	if name != legacyLLMProvider {
+		return driver, pc
+	}
+	if pc.BaseURL == "" {
+		pc.BaseURL = cfg.BaseURL
+	}
+	if pc.APIKey == "" {
+		pc.APIKey = cfg.APIKey
+	}
+	if pc.Model == "" {
+		pc.Model = cfg.Model
+	}
+	return driver, pc
+}
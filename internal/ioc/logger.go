@@ -0,0 +1,23 @@
+package ioc
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/pkg/logger"
+	"log/slog"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitLogger 初始化结构化日志
+// 同时设为 slog 与标准库 log 的默认输出，未注入 Logger 的代码 (第三方库等) 也统一格式
+func InitLogger() *slog.Logger {
+	cfg := config.Get().Logger
+	format := cfg.Format
+	if format == "" && os.Getenv(gin.EnvGinMode) == gin.ReleaseMode {
+		format = "json"
+	}
+	l := logger.New(os.Stdout, logger.Options{Level: cfg.Level, Format: format})
+	slog.SetDefault(l)
+	return l
+}
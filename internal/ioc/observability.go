@@ -2,12 +2,16 @@ package ioc
 
 import (
 	"coca-ai/internal/config"
-	"log"
+	"coca-ai/internal/handler/middleware"
+	"coca-ai/pkg/logger"
+	"context"
+	"log/slog"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -15,37 +19,62 @@ import (
 )
 
 // InitPrometheus 初始化 Prometheus 监控中间件
+// 业务指标定义见 internal/metrics
 func InitPrometheus(server *gin.Engine) {
+	// 记录 HTTP 请求耗时
+	server.Use(middleware.Metrics())
 	// 暴露 metrics 接口
 	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
-	log.Println("[Observability] Prometheus metrics exposed at /metrics")
+	slog.Info("prometheus metrics exposed at /metrics", "component", "observability")
 }
 
-// InitJaeger 初始化 Jaeger 链路追踪
-func InitJaeger() {
-	cfg := config.Get()
-	if cfg.Jaeger.AgentHost == "" {
-		log.Println("[Observability] Jaeger not configured, skipping")
-		return
+// InitTracing 初始化 HTTP 链路追踪中间件
+// 每个请求创建一个 Span，并将链路上下文写入 Request.Context，供下游 Redis、MySQL、LLM 与 Kafka 调用接续
+func InitTracing(server *gin.Engine) {
+	server.Use(otelgin.Middleware(tracingServiceName(config.Get().Jaeger),
+		otelgin.WithGinFilter(func(c *gin.Context) bool {
+			return c.FullPath() != "/metrics"
+		}),
+	))
+}
+
+// InitJaeger 初始化链路追踪，通过 OTLP gRPC 上报到 Jaeger，返回停机时调用的关闭函数
+// 未配置 Endpoint 时不启用，全局 TracerProvider 保持 noop
+func InitJaeger() TracerShutdown {
+	cfg := config.Get().Jaeger
+	if cfg.Endpoint == "" {
+		slog.Info("tracing not configured, skipping", "component", "observability")
+		return noopTracerShutdown
 	}
 
-	// 创建 Jaeger Exporter
-	exp, err := jaeger.New(jaeger.WithAgentEndpoint(
-		jaeger.WithAgentHost(cfg.Jaeger.AgentHost),
-		jaeger.WithAgentPort(cfg.Jaeger.AgentPort),
-	))
+	// 创建 OTLP Exporter
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if !cfg.TLS {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exp, err := otlptracegrpc.New(context.Background(), opts...)
 	if err != nil {
-		log.Printf("[Observability] Failed to create Jaeger exporter: %v", err)
-		return
+		slog.Error("create otlp exporter failed", logger.Error(err), "component", "observability")
+		return noopTracerShutdown
 	}
 
-	// 创建 TraceProvider
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	environment := cfg.Environment
+	if environment == "" {
+		environment = "production"
+	}
+
+	// 创建 TraceProvider，跟随上游的采样决定
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
 		sdktrace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("coca-backend"),
-			semconv.DeploymentEnvironmentKey.String("production"),
+			semconv.ServiceNameKey.String(tracingServiceName(cfg)),
+			semconv.DeploymentEnvironmentKey.String(environment),
 		)),
 	)
 
@@ -56,6 +85,18 @@ func InitJaeger() {
 		propagation.Baggage{},
 	))
 
-	log.Printf("[Observability] Jaeger tracing enabled (Host: %s:%s)",
-		cfg.Jaeger.AgentHost, cfg.Jaeger.AgentPort)
+	slog.Info("tracing enabled", "component", "observability", "endpoint", cfg.Endpoint, "sample_ratio", ratio)
+	return tp.Shutdown
+}
+
+// TracerShutdown 上报缓冲中的 Span 并关闭 TracerProvider
+type TracerShutdown func(ctx context.Context) error
+
+func noopTracerShutdown(context.Context) error { return nil }
+
+func tracingServiceName(cfg config.JaegerConfig) string {
+	if cfg.ServiceName != "" {
+		return cfg.ServiceName
+	}
+	return "coca-backend"
 }
@@ -0,0 +1,40 @@
+package ioc
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/llm"
+	"coca-ai/internal/rag"
+	"coca-ai/internal/repository/dao"
+)
+
+// InitEmbedder 初始化向量化模型
+func InitEmbedder() llm.Embedder {
+	cfg := config.Get()
+	embedder, err := llm.NewEmbedder(llm.EmbedderConfig{
+		Provider:   cfg.LLM.Embedding.Provider,
+		BaseURL:    cfg.LLM.Embedding.BaseURL,
+		APIKey:     cfg.LLM.Embedding.APIKey,
+		Model:      cfg.LLM.Embedding.Model,
+		Dimensions: cfg.LLM.Embedding.Dimensions,
+	})
+	if err != nil {
+		panic("Failed to create embedder: " + err.Error())
+	}
+	return embedder
+}
+
+// InitVectorStore 初始化向量存储
+func InitVectorStore(chunkDAO *dao.DocumentChunkDAO) rag.VectorStore {
+	switch config.Get().RAG.VectorStore {
+	case "memory":
+		return rag.NewMemoryStore()
+	default:
+		return rag.NewMySQLStore(chunkDAO)
+	}
+}
+
+// InitChunker 初始化文本切片器
+func InitChunker() *rag.Chunker {
+	cfg := config.Get()
+	return rag.NewChunker(cfg.RAG.ChunkSize, cfg.RAG.ChunkOverlap)
+}
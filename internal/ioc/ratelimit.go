@@ -0,0 +1,18 @@
+package ioc
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/pkg/ratelimit"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InitRateLimiter 初始化限流器
+// Redis.FailOpen 开启时 Redis 故障退化为单机内存限流
+func InitRateLimiter(cmd redis.Cmdable) ratelimit.Limiter {
+	limiter := ratelimit.NewRedisLimiter(cmd, "ratelimit:")
+	if config.Get().Redis.FailOpen {
+		return ratelimit.NewFallbackLimiter(limiter, ratelimit.NewLocalLimiter())
+	}
+	return limiter
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -38,12 +39,16 @@ func InitRedis() redis.Cmdable {
 		Addr:         addr,
 		Password:     password,
 		DB:           db,
-		PoolSize:     orDefaultInt(cfg.Redis.PoolSize, 50), // 设置连接池大小
-		MinIdleConns: orDefaultInt(cfg.Redis.MinIdleConns, 10), // 设置最小空闲连接数
-		DialTimeout:  orDefaultDuration(cfg.Redis.DialTimeoutMS, 3000), // 设置拨号超时时间
-		ReadTimeout:  orDefaultDuration(cfg.Redis.ReadTimeoutMS, 3000), // 设置读取超时时间
+		PoolSize:     orDefaultInt(cfg.Redis.PoolSize, 50),              // 设置连接池大小
+		MinIdleConns: orDefaultInt(cfg.Redis.MinIdleConns, 10),          // 设置最小空闲连接数
+		DialTimeout:  orDefaultDuration(cfg.Redis.DialTimeoutMS, 3000),  // 设置拨号超时时间
+		ReadTimeout:  orDefaultDuration(cfg.Redis.ReadTimeoutMS, 3000),  // 设置读取超时时间
 		WriteTimeout: orDefaultDuration(cfg.Redis.WriteTimeoutMS, 3000), // 设置写入超时时间
 	})
+	// 为每条命令创建 Span
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		panic(err)
+	}
 	return client
 }
 
@@ -0,0 +1,16 @@
+package ioc
+
+import "coca-ai/internal/tool"
+
+// InitToolRegistry 初始化工具注册表并注册内置工具
+func InitToolRegistry() *tool.Registry {
+	registry := tool.NewRegistry()
+	for _, t := range []tool.Tool{
+		tool.CurrentTime(),
+	} {
+		if err := registry.Register(t); err != nil {
+			panic("Failed to register tool: " + err.Error())
+		}
+	}
+	return registry
+}
@@ -1,32 +1,53 @@
 package ioc
 
 import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/domain"
 	"coca-ai/internal/handler"
 	"coca-ai/internal/handler/middleware"
+	"log/slog"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func InitWebServer(pingHandler *handler.PingHandler, userHandler *handler.UserHandler, chatHandler *handler.ChatHandler, jwtMiddleware *middleware.LoginJWTMiddleware) *gin.Engine {
-	server := gin.Default()
+func InitWebServer(pingHandler *handler.PingHandler, adminHandler *handler.AdminHandler, userHandler *handler.UserHandler, chatHandler *handler.ChatHandler, wsHandler *handler.WSHandler, openAIHandler *handler.OpenAIHandler, documentHandler *handler.DocumentHandler, apiKeyHandler *handler.APIKeyHandler, usageHandler *handler.UsageHandler, jwtMiddleware *middleware.LoginJWTMiddleware, apiKeyMiddleware *middleware.APIKeyMiddleware, rateLimitMiddleware *middleware.RateLimitMiddleware, l *slog.Logger) *gin.Engine {
+	server := gin.New()
+	// 限流与日志按客户端 IP 区分，只接受可信代理转发的 X-Forwarded-For
+	if err := server.SetTrustedProxies(config.Get().Server.TrustedProxies); err != nil {
+		panic("Failed to set trusted proxies: " + err.Error())
+	}
 
 	// 初始化 Prometheus 监控
 	InitPrometheus(server)
+	// 初始化链路追踪
+	InitTracing(server)
+	// 请求 ID 与访问日志
+	server.Use(middleware.RequestID(), middleware.AccessLog(l), gin.Recovery())
 
 	// CORS Configuration
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // For dev allow all
 		AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Last-Event-ID", "X-Session-ID", "X-Request-ID", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Generation-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Request-ID", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	pingHandler.RegisterRoutes(server)
-	userHandler.RegisterRoutes(server, jwtMiddleware)
-	chatHandler.RegisterRoutes(server, jwtMiddleware.Check())
+	userHandler.RegisterRoutes(server, jwtMiddleware, rateLimitMiddleware)
+	apiKeyHandler.RegisterRoutes(server, jwtMiddleware.Check())
+	// 以下接口同时接受 JWT 与个人 API Key (按权限范围校验)
+	chatHandler.RegisterRoutes(server, apiKeyMiddleware.Check(domain.APIKeyScopeChat), rateLimitMiddleware.Send())
+	wsHandler.RegisterRoutes(server, apiKeyMiddleware.Check(domain.APIKeyScopeChat))
+	openAIHandler.RegisterRoutes(server, apiKeyMiddleware.Check(domain.APIKeyScopeCompletions), rateLimitMiddleware.Send())
+	documentHandler.RegisterRoutes(server, apiKeyMiddleware.Check(domain.APIKeyScopeDocuments))
+	usageHandler.RegisterRoutes(server, apiKeyMiddleware.Check(""))
+	// 管理接口仅在配置了访问令牌时开放
+	if token := config.Get().Server.AdminToken; token != "" {
+		adminHandler.RegisterRoutes(server, middleware.AdminAuth(token))
+	}
 	return server
 }
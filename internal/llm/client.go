@@ -1,11 +1,48 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // Message 表示一条对话消息
 type Message struct {
-	Role    string // "user", "assistant", "system"
-	Content string
+	Role       string // "user", "assistant", "system", "tool"
+	Content    string
+	ToolCalls  []ToolCall // assistant 消息中模型请求的工具调用
+	ToolCallID string     // tool 消息对应的工具调用 ID
+	// FinishReason 模型结束生成的原因 (stop, length, tool_calls 等)，仅回复消息有值
+	FinishReason string
+	// Usage 生成该回复的 token 用量，仅回复消息有值
+	Usage Usage
+	// Provider / Model 实际生成该回复的 Provider 与模型 (经 FailoverClient 调用时填充)
+	Provider string
+	Model    string
+}
+
+// Usage 一次模型调用的 token 用量
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Total 总 token 数
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON 格式的参数
+}
+
+// ToolDefinition 提供给模型的工具描述
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
 }
 
 // StreamCallback 流式响应回调函数
@@ -18,13 +55,67 @@ type StreamCallback func(delta string) error
 type ChatClient interface {
 	// Chat 普通对话 (非流式)
 	// 返回完整的 AI 回复内容
-	Chat(ctx context.Context, messages []Message) (string, error)
+	Chat(ctx context.Context, messages []Message, opts ...Option) (string, error)
 
 	// StreamChat 流式对话
-	// 通过 callback 逐步返回生成的内容
-	StreamChat(ctx context.Context, messages []Message, callback StreamCallback) error
+	// 通过 callback 逐步返回生成的内容，结束后返回完整的 assistant 消息 (含工具调用)
+	StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error)
 
 	// Summarize 生成摘要
 	// 将历史消息压缩成简短摘要
 	Summarize(ctx context.Context, messages []Message) (string, error)
 }
+
+// Options 单次调用的生成参数，零值表示使用 Provider 默认值
+type Options struct {
+	Model       string
+	Temperature *float32
+	TopP        *float32
+	MaxTokens   *int
+	Stop        []string
+	Tools       []ToolDefinition
+}
+
+// Option 调用参数选项
+type Option func(o *Options)
+
+// WithModel 指定模型
+func WithModel(model string) Option {
+	return func(o *Options) { o.Model = model }
+}
+
+// WithTemperature 指定采样温度
+func WithTemperature(temperature float32) Option {
+	return func(o *Options) { o.Temperature = &temperature }
+}
+
+// WithTopP 指定 top_p
+func WithTopP(topP float32) Option {
+	return func(o *Options) { o.TopP = &topP }
+}
+
+// WithMaxTokens 指定最大生成 token 数
+func WithMaxTokens(maxTokens int) Option {
+	return func(o *Options) { o.MaxTokens = &maxTokens }
+}
+
+// WithStop 指定停止序列
+func WithStop(stop []string) Option {
+	return func(o *Options) { o.Stop = stop }
+}
+
+// WithTools 指定可供模型调用的工具
+func WithTools(tools []ToolDefinition) Option {
+	return func(o *Options) { o.Tools = tools }
+}
+
+// ApplyOptions 合并调用参数
+func ApplyOptions(opts []Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
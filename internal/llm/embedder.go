@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder 文本向量化接口
+type Embedder interface {
+	// Embed 批量将文本转换为向量，返回顺序与输入一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Dimensions 向量维度
+	Dimensions() int
+}
+
+// EmbedderConfig Embedder 配置
+type EmbedderConfig struct {
+	Provider   string // hash (离线), openai (OpenAI 兼容接口，如通义 text-embedding-v3)
+	BaseURL    string
+	APIKey     string
+	Model      string
+	Dimensions int
+}
+
+// NewEmbedder 根据配置创建 Embedder
+func NewEmbedder(cfg EmbedderConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "", "hash":
+		return NewHashEmbedder(cfg.Dimensions), nil
+	case "openai":
+		return NewOpenAIEmbedder(cfg)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+	}
+}
+
+// ==================== HashEmbedder ====================
+
+// HashEmbedder 基于特征哈希的离线 Embedder
+// 英文按单词、中文按相邻字符二元组做哈希，无需任何外部服务，适合开发与测试
+type HashEmbedder struct {
+	dims int
+}
+
+// NewHashEmbedder 创建 HashEmbedder
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = 256
+	}
+	return &HashEmbedder{dims: dims}
+}
+
+// Dimensions 向量维度
+func (e *HashEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed 批量向量化
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	for i, text := range texts {
+		result[i] = e.embed(text)
+	}
+	return result, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vec := make([]float32, e.dims)
+	for _, term := range hashTerms(text) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(term))
+		sum := h.Sum32()
+		sign := float32(1)
+		if sum&(1<<31) != 0 {
+			sign = -1
+		}
+		vec[int(sum%uint32(e.dims))] += sign
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= scale
+		}
+	}
+	return vec
+}
+
+// hashTerms 切分特征词：拉丁字母/数字按单词，CJK 字符按二元组
+func hashTerms(text string) []string {
+	var terms []string
+	var word strings.Builder
+	var prevHan rune
+	flush := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if prevHan != 0 {
+				terms = append(terms, string([]rune{prevHan, r}))
+			} else {
+				terms = append(terms, string(r))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return terms
+}
+
+// ==================== OpenAIEmbedder ====================
+
+// embeddingBatchSize 单次请求的最大文本数 (DashScope 限制为 10)
+const embeddingBatchSize = 10
+
+// OpenAIEmbedder OpenAI 兼容 /embeddings 接口的 Embedder
+type OpenAIEmbedder struct {
+	cfg    EmbedderConfig
+	client *http.Client
+}
+
+// NewOpenAIEmbedder 创建 OpenAIEmbedder
+func NewOpenAIEmbedder(cfg EmbedderConfig) (*OpenAIEmbedder, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("embedding provider requires an api key")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
+	}
+	if cfg.Model == "" {
+		cfg.Model = "text-embedding-v3"
+	}
+	if cfg.Dimensions <= 0 {
+		cfg.Dimensions = 1024
+	}
+	return &OpenAIEmbedder{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Dimensions 向量维度
+func (e *OpenAIEmbedder) Dimensions() int {
+	return e.cfg.Dimensions
+}
+
+// Embed 批量向量化
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		vectors, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, vectors...)
+	}
+	return result, nil
+}
+
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":      e.cfg.Model,
+		"input":      texts,
+		"dimensions": e.cfg.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(e.cfg.BaseURL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: status %d", resp.StatusCode)
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embedding response failed: %w", err)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response size mismatch: want %d, got %d", len(texts), len(out.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response index out of range: %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
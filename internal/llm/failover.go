@@ -0,0 +1,284 @@
+package llm
+
+import (
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrProviderUnavailable 所有 Provider 均处于熔断状态
+var ErrProviderUnavailable = errors.New("llm provider unavailable")
+
+// Backend 故障转移链中的一个后端
+type Backend struct {
+	Name   string // Provider 名称，同名后端共用一个熔断器
+	Client ChatClient
+	// Model 使用该后端时强制使用的模型，为空时沿用调用方指定的模型 (仅主后端应为空)
+	Model string
+}
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	MaxRetries int           // 单个后端的最大重试次数 (不含首次调用)
+	Backoff    time.Duration // 首次重试前的等待时间，之后按指数增长
+	MaxBackoff time.Duration // 单次等待的上限
+}
+
+// BreakerPolicy 熔断策略
+type BreakerPolicy struct {
+	FailureThreshold int           // 连续失败多少次后熔断，0 表示不熔断
+	Cooldown         time.Duration // 熔断持续时间，到期后放行一次试探请求
+}
+
+// FailoverClient 带重试、故障转移与熔断的 ChatClient
+// 按顺序尝试各后端：暂时性错误 (超时、网络错误、429、5xx) 在输出第一个 token 前按退避重试，
+// 重试耗尽或后端已熔断时切换到下一个后端；已开始输出后出错直接返回，避免内容重复
+type FailoverClient struct {
+	backends []Backend
+	retry    RetryPolicy
+	breakers map[string]*breaker
+	l        *slog.Logger
+}
+
+// NewFailoverClient 创建 FailoverClient，backends[0] 为主后端
+func NewFailoverClient(backends []Backend, retry RetryPolicy, breakerPolicy BreakerPolicy, l *slog.Logger) *FailoverClient {
+	l = l.With("component", "llm_failover")
+	breakers := make(map[string]*breaker, len(backends))
+	for _, b := range backends {
+		if _, ok := breakers[b.Name]; !ok {
+			breakers[b.Name] = &breaker{name: b.Name, policy: breakerPolicy, l: l}
+		}
+	}
+	return &FailoverClient{backends: backends, retry: retry, breakers: breakers, l: l}
+}
+
+// Models 返回主后端的可用模型
+func (c *FailoverClient) Models() []string {
+	if lister, ok := c.backends[0].Client.(ModelLister); ok {
+		return lister.Models()
+	}
+	return nil
+}
+
+// DefaultModel 返回主后端的默认模型
+func (c *FailoverClient) DefaultModel() string {
+	if lister, ok := c.backends[0].Client.(ModelLister); ok {
+		return lister.DefaultModel()
+	}
+	return ""
+}
+
+// Chat 普通对话 (非流式)
+func (c *FailoverClient) Chat(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	var content string
+	err := c.do(ctx, opts, func(b Backend, opts []Option) (bool, error) {
+		var err error
+		content, err = b.Client.Chat(ctx, messages, opts...)
+		return false, err
+	})
+	return content, err
+}
+
+// StreamChat 流式对话，返回的消息记录实际提供服务的 Provider 与模型
+func (c *FailoverClient) StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error) {
+	var reply *Message
+	err := c.do(ctx, opts, func(b Backend, opts []Option) (bool, error) {
+		started := false
+		var err error
+		reply, err = b.Client.StreamChat(ctx, messages, func(delta string) error {
+			started = true
+			return callback(delta)
+		}, opts...)
+		if err != nil {
+			return started, err
+		}
+		reply.Provider = b.Name
+		reply.Model = c.modelOf(b, opts)
+		return false, nil
+	})
+	return reply, err
+}
+
+// Summarize 生成摘要
+func (c *FailoverClient) Summarize(ctx context.Context, messages []Message) (string, error) {
+	var summary string
+	err := c.do(ctx, nil, func(b Backend, _ []Option) (bool, error) {
+		var err error
+		summary, err = b.Client.Summarize(ctx, messages)
+		return false, err
+	})
+	return summary, err
+}
+
+// do 按故障转移顺序执行 call
+// call 返回 started=true 表示已向调用方输出内容，此时出错不再重试
+func (c *FailoverClient) do(ctx context.Context, opts []Option, call func(b Backend, opts []Option) (started bool, err error)) error {
+	var lastErr error
+	for i, b := range c.backends {
+		br := c.breakers[b.Name]
+		if !br.allow() {
+			continue
+		}
+		if i > 0 {
+			c.l.WarnContext(ctx, "fail over to next provider", logger.Error(lastErr), "provider", b.Name, "model", b.Model)
+		}
+
+		backendOpts := opts
+		if b.Model != "" {
+			backendOpts = append(append([]Option{}, opts...), WithModel(b.Model))
+		}
+		for attempt := 0; ; attempt++ {
+			started, err := call(b, backendOpts)
+			if err == nil {
+				br.success()
+				return nil
+			}
+			// 调用方取消或非暂时性错误 (如参数错误) 不重试，也不计入熔断
+			if ctx.Err() != nil || !IsTransient(err) {
+				br.release()
+				return err
+			}
+			br.failure()
+			lastErr = err
+			if started {
+				return err
+			}
+			if attempt >= c.retry.MaxRetries || !br.allow() {
+				break
+			}
+			if err := sleepCtx(ctx, c.backoff(attempt)); err != nil {
+				return err
+			}
+		}
+	}
+	if lastErr == nil {
+		return ErrProviderUnavailable
+	}
+	return fmt.Errorf("all llm providers failed: %w", lastErr)
+}
+
+// modelOf 返回后端实际使用的模型
+func (c *FailoverClient) modelOf(b Backend, opts []Option) string {
+	if model := ApplyOptions(opts).Model; model != "" {
+		return model
+	}
+	if lister, ok := b.Client.(ModelLister); ok {
+		return lister.DefaultModel()
+	}
+	return ""
+}
+
+// backoff 第 attempt 次重试前的等待时间 (指数退避 + 抖动)
+func (c *FailoverClient) backoff(attempt int) time.Duration {
+	d := c.retry.Backoff << attempt
+	if c.retry.MaxBackoff > 0 && (d > c.retry.MaxBackoff || d <= 0) {
+		d = c.retry.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// 在 [d/2, d) 之间随机，避免多个实例同时重试
+	return d/2 + rand.N(d/2+1)
+}
+
+// IsTransient 判断错误是否为可重试的暂时性错误
+func IsTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	switch code := statusCodeOf(err); {
+	case code == 408 || code == 429:
+		return true
+	case code >= 500:
+		return true
+	}
+	return false
+}
+
+// sleepCtx 等待 d，ctx 结束时提前返回
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// breaker 单个 Provider 的熔断器
+// closed: 正常放行；连续失败达到阈值后 open，冷却期内拒绝请求；
+// 冷却期结束后 half-open，只放行一个试探请求，成功则恢复，失败则重新熔断
+type breaker struct {
+	name   string
+	policy BreakerPolicy
+	l      *slog.Logger
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // half-open 状态下试探请求是否在进行中
+}
+
+// allow 是否放行请求
+func (b *breaker) allow() bool {
+	if b.policy.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.policy.FailureThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// success 记录一次成功，恢复为 closed
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// failure 记录一次暂时性失败，达到阈值时熔断
+func (b *breaker) failure() {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.policy.FailureThreshold {
+		if b.failures == b.policy.FailureThreshold {
+			b.l.Warn("provider circuit opened", "provider", b.name, "failures", b.failures)
+		}
+		b.openUntil = time.Now().Add(b.policy.Cooldown)
+	}
+}
+
+// release 结束试探请求但不改变状态 (请求因非暂时性原因失败)
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var (
+	errTransient = fmt.Errorf("upstream: %w", context.DeadlineExceeded)
+	errBadInput  = errors.New("invalid request")
+
+	discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+)
+
+// scriptedClient 依次返回 errs 中的错误，用尽后成功
+type scriptedClient struct {
+	errs    []error
+	started bool // 出错前是否已输出内容
+	calls   int
+}
+
+func (c *scriptedClient) next() error {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return c.errs[c.calls-1]
+	}
+	return nil
+}
+
+func (c *scriptedClient) Chat(context.Context, []Message, ...Option) (string, error) {
+	if err := c.next(); err != nil {
+		return "", err
+	}
+	return "ok", nil
+}
+
+func (c *scriptedClient) StreamChat(_ context.Context, _ []Message, callback StreamCallback, _ ...Option) (*Message, error) {
+	err := c.next()
+	if err == nil || c.started {
+		if cbErr := callback("ok"); cbErr != nil {
+			return nil, cbErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Role: "assistant", Content: "ok"}, nil
+}
+
+func (c *scriptedClient) Summarize(context.Context, []Message) (string, error) {
+	return "", c.next()
+}
+
+func newTestFailoverClient(breakerPolicy BreakerPolicy, backends ...Backend) *FailoverClient {
+	return NewFailoverClient(backends, RetryPolicy{MaxRetries: 1}, breakerPolicy, discardLogger)
+}
+
+func newTestBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{name: "primary", policy: BreakerPolicy{FailureThreshold: threshold, Cooldown: cooldown}, l: discardLogger}
+}
+
+func TestFailoverClient_StreamChat(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       *scriptedClient
+		secondary     *scriptedClient
+		wantErr       error
+		wantProvider  string
+		wantModel     string
+		wantPrimary   int
+		wantSecondary int
+	}{
+		{
+			name:         "retries transient error",
+			primary:      &scriptedClient{errs: []error{errTransient}},
+			secondary:    &scriptedClient{},
+			wantProvider: "primary",
+			wantPrimary:  2,
+		},
+		{
+			name:          "fails over after retries",
+			primary:       &scriptedClient{errs: []error{errTransient, errTransient}},
+			secondary:     &scriptedClient{},
+			wantProvider:  "secondary",
+			wantModel:     "backup-model",
+			wantPrimary:   2,
+			wantSecondary: 1,
+		},
+		{
+			name:        "does not retry non-transient error",
+			primary:     &scriptedClient{errs: []error{errBadInput}},
+			secondary:   &scriptedClient{},
+			wantErr:     errBadInput,
+			wantPrimary: 1,
+		},
+		{
+			name:        "does not retry after output started",
+			primary:     &scriptedClient{errs: []error{errTransient}, started: true},
+			secondary:   &scriptedClient{},
+			wantErr:     errTransient,
+			wantPrimary: 1,
+		},
+		{
+			name:          "all providers failed",
+			primary:       &scriptedClient{errs: []error{errTransient, errTransient}},
+			secondary:     &scriptedClient{errs: []error{errTransient, errTransient}},
+			wantErr:       errTransient,
+			wantPrimary:   2,
+			wantSecondary: 2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestFailoverClient(BreakerPolicy{},
+				Backend{Name: "primary", Client: tc.primary},
+				Backend{Name: "secondary", Client: tc.secondary, Model: "backup-model"},
+			)
+			var output string
+			reply, err := c.StreamChat(context.Background(), nil, func(delta string) error {
+				output += delta
+				return nil
+			}, WithModel("main-model"))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("StreamChat() error = %v, want %v", err, tc.wantErr)
+			}
+			if tc.primary.calls != tc.wantPrimary || tc.secondary.calls != tc.wantSecondary {
+				t.Fatalf("calls = %d/%d, want %d/%d", tc.primary.calls, tc.secondary.calls, tc.wantPrimary, tc.wantSecondary)
+			}
+			if err != nil {
+				return
+			}
+			wantModel := tc.wantModel
+			if wantModel == "" {
+				wantModel = "main-model"
+			}
+			if reply.Provider != tc.wantProvider || reply.Model != wantModel {
+				t.Fatalf("reply served by %s/%s, want %s/%s", reply.Provider, reply.Model, tc.wantProvider, wantModel)
+			}
+			if output != "ok" {
+				t.Fatalf("output = %q, want %q", output, "ok")
+			}
+		})
+	}
+}
+
+func TestFailoverClient_SkipsOpenCircuit(t *testing.T) {
+	primary := &scriptedClient{errs: []error{errTransient, errTransient, errTransient, errTransient}}
+	secondary := &scriptedClient{}
+	c := newTestFailoverClient(BreakerPolicy{FailureThreshold: 2, Cooldown: time.Minute},
+		Backend{Name: "primary", Client: primary},
+		Backend{Name: "secondary", Client: secondary, Model: "backup-model"},
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Chat(context.Background(), nil); err != nil {
+			t.Fatalf("Chat() #%d error = %v", i, err)
+		}
+	}
+	// 首次请求两次失败后熔断，第二次请求直接跳过主后端
+	if primary.calls != 2 || secondary.calls != 2 {
+		t.Fatalf("calls = %d/%d, want 2/2", primary.calls, secondary.calls)
+	}
+}
+
+func TestFailoverClient_AllCircuitsOpen(t *testing.T) {
+	c := newTestFailoverClient(BreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute},
+		Backend{Name: "primary", Client: &scriptedClient{errs: []error{errTransient}}},
+	)
+	if _, err := c.Chat(context.Background(), nil); !errors.Is(err, errTransient) {
+		t.Fatalf("first Chat() error = %v, want %v", err, errTransient)
+	}
+	if _, err := c.Chat(context.Background(), nil); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("second Chat() error = %v, want %v", err, ErrProviderUnavailable)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		b := newTestBreaker(2, time.Minute)
+		b.failure()
+		if !b.allow() {
+			t.Fatal("breaker opened before threshold")
+		}
+		b.failure()
+		if b.allow() {
+			t.Fatal("breaker allowed request during cooldown")
+		}
+	})
+
+	t.Run("success resets failures", func(t *testing.T) {
+		b := newTestBreaker(2, time.Minute)
+		b.failure()
+		b.success()
+		b.failure()
+		if !b.allow() {
+			t.Fatal("breaker opened although failures were not consecutive")
+		}
+	})
+
+	t.Run("half-open allows a single probe", func(t *testing.T) {
+		b := newTestBreaker(1, time.Millisecond)
+		b.failure()
+		time.Sleep(5 * time.Millisecond)
+		if !b.allow() {
+			t.Fatal("breaker rejected probe after cooldown")
+		}
+		if b.allow() {
+			t.Fatal("breaker allowed a second concurrent probe")
+		}
+		b.success()
+		if !b.allow() || !b.allow() {
+			t.Fatal("breaker not closed after successful probe")
+		}
+	})
+
+	t.Run("failed probe reopens", func(t *testing.T) {
+		b := newTestBreaker(1, time.Millisecond)
+		b.failure()
+		time.Sleep(5 * time.Millisecond)
+		b.allow()
+		b.policy.Cooldown = time.Minute
+		b.failure()
+		if b.allow() {
+			t.Fatal("breaker allowed request after failed probe")
+		}
+	})
+
+	t.Run("released probe can be retried", func(t *testing.T) {
+		b := newTestBreaker(1, time.Millisecond)
+		b.failure()
+		time.Sleep(5 * time.Millisecond)
+		b.allow()
+		b.release()
+		if !b.allow() {
+			t.Fatal("breaker rejected probe after release")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		b := newTestBreaker(0, time.Minute)
+		for i := 0; i < 10; i++ {
+			b.failure()
+		}
+		if !b.allow() {
+			t.Fatal("disabled breaker rejected request")
+		}
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: errTransient, want: true},
+		{err: errBadInput, want: false},
+		{err: context.Canceled, want: false},
+	}
+	for _, tc := range tests {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FakeClient 确定性的假 LLM 客户端
+// 回显最后一条用户消息，用于本地开发与测试，不依赖任何外部服务
+// 提供工具时，用户消息 "/tool <name> <json>" 会触发一次对应的工具调用
+type FakeClient struct {
+	model  string
+	models []string
+}
+
+// NewFakeClient 创建 FakeClient
+func NewFakeClient(model string, models []string) *FakeClient {
+	if model == "" {
+		model = "fake-echo"
+	}
+	return &FakeClient{model: model, models: models}
+}
+
+// Models 返回可用模型列表
+func (c *FakeClient) Models() []string {
+	if len(c.models) > 0 {
+		return c.models
+	}
+	return []string{c.model}
+}
+
+// DefaultModel 返回默认模型
+func (c *FakeClient) DefaultModel() string {
+	return c.model
+}
+
+// Chat 普通对话 (非流式)
+func (c *FakeClient) Chat(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	reply, _ := c.reply(messages, ApplyOptions(opts))
+	return reply, nil
+}
+
+// StreamChat 流式对话，按空白切分逐段回调
+func (c *FakeClient) StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error) {
+	o := ApplyOptions(opts)
+	model := o.Model
+	if model == "" {
+		model = c.model
+	}
+	if call, ok := c.toolCall(messages, o); ok {
+		return &Message{Role: "assistant", ToolCalls: []ToolCall{call}, FinishReason: "tool_calls",
+			Usage: EstimateUsage(model, messages, call.Name+call.Arguments)}, nil
+	}
+
+	reply, finishReason := c.reply(messages, o)
+	for _, delta := range strings.SplitAfter(reply, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := callback(delta); err != nil {
+			return nil, err
+		}
+	}
+	return &Message{Role: "assistant", Content: reply, FinishReason: finishReason,
+		Usage: EstimateUsage(model, messages, reply)}, nil
+}
+
+// Summarize 生成摘要
+func (c *FakeClient) Summarize(ctx context.Context, messages []Message) (string, error) {
+	return fmt.Sprintf("[%s] summary of %d messages", c.model, len(messages)), nil
+}
+
+func (c *FakeClient) reply(messages []Message, o *Options) (string, string) {
+	model := c.model
+	if o.Model != "" {
+		model = o.Model
+	}
+	reply := fmt.Sprintf("[%s] hello", model)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "tool" {
+			reply = fmt.Sprintf("[%s] tool result: %s", model, messages[i].Content)
+			break
+		}
+		if messages[i].Role == "user" {
+			reply = fmt.Sprintf("[%s] %s", model, messages[i].Content)
+			break
+		}
+	}
+	// 按 max_tokens 截断 (以空白分词近似 token)
+	if o.MaxTokens != nil && *o.MaxTokens > 0 {
+		words := strings.Fields(reply)
+		if len(words) > *o.MaxTokens {
+			return strings.Join(words[:*o.MaxTokens], " "), "length"
+		}
+	}
+	return reply, "stop"
+}
+
+// toolCall 解析 "/tool <name> <json>" 指令
+func (c *FakeClient) toolCall(messages []Message, o *Options) (ToolCall, bool) {
+	if len(o.Tools) == 0 || len(messages) == 0 {
+		return ToolCall{}, false
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "user" || !strings.HasPrefix(last.Content, "/tool ") {
+		return ToolCall{}, false
+	}
+	name, args, _ := strings.Cut(strings.TrimPrefix(last.Content, "/tool "), " ")
+	if args == "" {
+		args = "{}"
+	}
+	for _, t := range o.Tools {
+		if t.Name == name {
+			return ToolCall{ID: fmt.Sprintf("call_%d", len(messages)), Name: name, Arguments: args}, true
+		}
+	}
+	return ToolCall{}, false
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"coca-ai/internal/metrics"
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "coca-ai/internal/llm"
+
+// InstrumentedClient 记录模型调用指标与链路的 ChatClient
+// 包括首 token 时间、总生成时间与 token 用量，按实际使用的模型区分；每次调用创建一个 Span
+type InstrumentedClient struct {
+	ChatClient
+}
+
+// NewInstrumentedClient 创建 InstrumentedClient
+func NewInstrumentedClient(client ChatClient) *InstrumentedClient {
+	return &InstrumentedClient{ChatClient: client}
+}
+
+// Models 返回被包装客户端的可用模型
+func (c *InstrumentedClient) Models() []string {
+	if lister, ok := c.ChatClient.(ModelLister); ok {
+		return lister.Models()
+	}
+	return nil
+}
+
+// DefaultModel 返回被包装客户端的默认模型
+func (c *InstrumentedClient) DefaultModel() string {
+	if lister, ok := c.ChatClient.(ModelLister); ok {
+		return lister.DefaultModel()
+	}
+	return ""
+}
+
+// Chat 普通对话 (非流式)
+func (c *InstrumentedClient) Chat(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	ctx, span := c.startSpan(ctx, "llm.chat", ApplyOptions(opts).Model, len(messages))
+	content, err := c.ChatClient.Chat(ctx, messages, opts...)
+	endSpan(span, err)
+	return content, err
+}
+
+// Summarize 生成摘要
+func (c *InstrumentedClient) Summarize(ctx context.Context, messages []Message) (string, error) {
+	ctx, span := c.startSpan(ctx, "llm.summarize", "", len(messages))
+	summary, err := c.ChatClient.Summarize(ctx, messages)
+	endSpan(span, err)
+	return summary, err
+}
+
+// StreamChat 流式对话，记录首 token 时间、总耗时与 token 用量
+func (c *InstrumentedClient) StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error) {
+	ctx, span := c.startSpan(ctx, "llm.stream_chat", ApplyOptions(opts).Model, len(messages))
+	start := time.Now()
+	var firstToken time.Duration
+	reply, err := c.ChatClient.StreamChat(ctx, messages, func(delta string) error {
+		if firstToken == 0 {
+			firstToken = time.Since(start)
+			span.AddEvent("first_token")
+		}
+		return callback(delta)
+	}, opts...)
+
+	model := ApplyOptions(opts).Model
+	if reply != nil && reply.Model != "" {
+		model = reply.Model
+	}
+	if model == "" {
+		model = c.DefaultModel()
+	}
+
+	status := metrics.Status(err)
+	if err != nil && ctx.Err() != nil {
+		status = "cancelled"
+	}
+	metrics.LLMGenerationDuration.WithLabelValues(model, status).Observe(time.Since(start).Seconds())
+	if firstToken > 0 {
+		metrics.LLMTimeToFirstToken.WithLabelValues(model).Observe(firstToken.Seconds())
+	}
+	if err == nil {
+		metrics.LLMTokens.WithLabelValues(model, "prompt").Add(float64(reply.Usage.PromptTokens))
+		metrics.LLMTokens.WithLabelValues(model, "completion").Add(float64(reply.Usage.CompletionTokens))
+		span.SetAttributes(
+			attribute.String("llm.provider", reply.Provider),
+			attribute.String("llm.response.model", model),
+			attribute.String("llm.finish_reason", reply.FinishReason),
+			attribute.Int("llm.usage.prompt_tokens", reply.Usage.PromptTokens),
+			attribute.Int("llm.usage.completion_tokens", reply.Usage.CompletionTokens),
+		)
+	}
+	endSpan(span, err)
+	return reply, err
+}
+
+// startSpan 开始一次模型调用的 Span
+func (c *InstrumentedClient) startSpan(ctx context.Context, name string, model string, messages int) (context.Context, trace.Span) {
+	if model == "" {
+		model = c.DefaultModel()
+	}
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("llm.request.model", model),
+			attribute.Int("llm.request.messages", messages),
+		),
+	)
+}
+
+// endSpan 结束 Span，出错时记录错误
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
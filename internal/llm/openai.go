@@ -0,0 +1,265 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	goopenai "github.com/meguminnnnnnnnn/go-openai"
+)
+
+// OpenAIClient OpenAI 兼容协议客户端 (通过 Eino 框架)
+// 通义千问、DeepSeek、Ollama 等均提供 OpenAI 兼容接口，共用该实现
+type OpenAIClient struct {
+	chatModel *openai.ChatModel
+	model     string
+	models    []string
+}
+
+// OpenAIConfig OpenAI 兼容客户端配置
+type OpenAIConfig struct {
+	APIKey  string   // API Key
+	BaseURL string   // API 端点
+	Model   string   // 默认模型名称，如 "qwen-plus", "gpt-4o-mini"
+	Models  []string // 可用模型列表
+}
+
+// NewOpenAIClient 创建 OpenAI 兼容客户端
+func NewOpenAIClient(cfg *OpenAIConfig) (*OpenAIClient, error) {
+	chatModel, err := openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
+		BaseURL: cfg.BaseURL,
+		APIKey:  cfg.APIKey,
+		Model:   cfg.Model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openai client: %w", err)
+	}
+
+	return &OpenAIClient{
+		chatModel: chatModel,
+		model:     cfg.Model,
+		models:    cfg.Models,
+	}, nil
+}
+
+// Models 返回可用模型列表 (未配置时仅包含默认模型)
+func (c *OpenAIClient) Models() []string {
+	if len(c.models) > 0 {
+		return c.models
+	}
+	return []string{c.model}
+}
+
+// DefaultModel 返回默认模型
+func (c *OpenAIClient) DefaultModel() string {
+	return c.model
+}
+
+// Chat 普通对话 (非流式)
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	einoMessages := c.convertMessages(messages)
+
+	resp, err := c.chatModel.Generate(ctx, einoMessages, c.convertOptions(opts)...)
+	if err != nil {
+		return "", fmt.Errorf("chat failed: %w", err)
+	}
+
+	if resp == nil || resp.Content == "" {
+		return "", fmt.Errorf("empty response from LLM")
+	}
+
+	return resp.Content, nil
+}
+
+// StreamChat 流式对话
+func (c *OpenAIClient) StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error) {
+	einoMessages := c.convertMessages(messages)
+
+	stream, err := c.chatModel.Stream(ctx, einoMessages, c.convertOptions(opts)...)
+	if err != nil {
+		return nil, fmt.Errorf("stream chat failed: %w", err)
+	}
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stream recv failed: %w", err)
+		}
+		if chunk == nil {
+			continue
+		}
+		chunks = append(chunks, chunk)
+
+		if chunk.Content != "" {
+			if err := callback(chunk.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 合并流式分片 (工具调用参数会分多个 chunk 返回，用量在最后一个 chunk 中返回)
+	full, err := schema.ConcatMessages(chunks)
+	if err != nil {
+		return nil, fmt.Errorf("concat stream chunks failed: %w", err)
+	}
+	result := c.toMessage(full)
+	if result.Usage.Total() == 0 {
+		// 部分兼容服务不支持在流式响应中返回用量，按估算值记录
+		model := ApplyOptions(opts).Model
+		if model == "" {
+			model = c.model
+		}
+		result.Usage = EstimateUsage(model, messages, result.Content)
+	}
+	return result, nil
+}
+
+// Summarize 生成摘要
+// system 消息视为此前的摘要，用于增量更新
+func (c *OpenAIClient) Summarize(ctx context.Context, messages []Message) (string, error) {
+	// 构建摘要请求
+	var content strings.Builder
+	content.WriteString("请将以下对话内容总结为简短的摘要（不超过200字），如有此前的摘要请将其与新对话合并：\n\n")
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			content.WriteString(fmt.Sprintf("此前的摘要: %s\n", msg.Content))
+		case "user":
+			content.WriteString(fmt.Sprintf("用户: %s\n", msg.Content))
+		case "assistant":
+			content.WriteString(fmt.Sprintf("助手: %s\n", msg.Content))
+		}
+	}
+
+	summaryMessages := []Message{
+		{Role: "system", Content: "你是一个专业的摘要助手，请用简洁的语言总结对话的主要内容。"},
+		{Role: "user", Content: content.String()},
+	}
+
+	return c.Chat(ctx, summaryMessages)
+}
+
+// convertMessages 将内部 Message 转换为 Eino schema.Message
+func (c *OpenAIClient) convertMessages(messages []Message) []*schema.Message {
+	result := make([]*schema.Message, len(messages))
+	for i, msg := range messages {
+		var role schema.RoleType
+		switch msg.Role {
+		case "user":
+			role = schema.User
+		case "assistant":
+			role = schema.Assistant
+		case "system":
+			role = schema.System
+		case "tool":
+			role = schema.Tool
+		default:
+			role = schema.User
+		}
+		result[i] = &schema.Message{
+			Role:       role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			result[i].ToolCalls = append(result[i].ToolCalls, schema.ToolCall{
+				ID:   call.ID,
+				Type: "function",
+				Function: schema.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+	}
+	return result
+}
+
+// toMessage 将 Eino schema.Message 转换为内部 Message
+func (c *OpenAIClient) toMessage(msg *schema.Message) *Message {
+	result := &Message{
+		Role:    "assistant",
+		Content: msg.Content,
+	}
+	if msg.ResponseMeta != nil {
+		result.FinishReason = msg.ResponseMeta.FinishReason
+		if usage := msg.ResponseMeta.Usage; usage != nil {
+			result.Usage = Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens}
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
+
+// convertTools 将工具定义转换为 Eino schema.ToolInfo
+func (c *OpenAIClient) convertTools(tools []ToolDefinition) []*schema.ToolInfo {
+	result := make([]*schema.ToolInfo, 0, len(tools))
+	for _, t := range tools {
+		info := &schema.ToolInfo{Name: t.Name, Desc: t.Description}
+		if len(t.Parameters) > 0 {
+			var params jsonschema.Schema
+			if err := json.Unmarshal(t.Parameters, &params); err == nil {
+				info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(&params)
+			}
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// convertOptions 将调用参数转换为 Eino model.Option
+func (c *OpenAIClient) convertOptions(opts []Option) []model.Option {
+	o := ApplyOptions(opts)
+	var result []model.Option
+	if o.Model != "" {
+		result = append(result, model.WithModel(o.Model))
+	}
+	if o.Temperature != nil {
+		result = append(result, model.WithTemperature(*o.Temperature))
+	}
+	if o.TopP != nil {
+		result = append(result, model.WithTopP(*o.TopP))
+	}
+	if o.MaxTokens != nil {
+		result = append(result, model.WithMaxTokens(*o.MaxTokens))
+	}
+	if len(o.Stop) > 0 {
+		result = append(result, model.WithStop(o.Stop))
+	}
+	if len(o.Tools) > 0 {
+		result = append(result, model.WithTools(c.convertTools(o.Tools)))
+	}
+	return result
+}
+
+// statusCodeOf 提取 OpenAI 兼容接口错误的 HTTP 状态码，非 HTTP 错误返回 0
+func statusCodeOf(err error) int {
+	var apiErr *goopenai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *goopenai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProviderConfig Provider 通用配置
+type ProviderConfig struct {
+	Name    string   // Provider 名称 (配置中的 key)
+	BaseURL string   // API 端点，为空时使用驱动默认值
+	APIKey  string   // API Key
+	Model   string   // 默认模型，为空时使用驱动默认值
+	Models  []string // 可用模型列表
+}
+
+// ProviderFactory 根据配置创建 ChatClient
+type ProviderFactory func(cfg ProviderConfig) (ChatClient, error)
+
+// ModelLister 可列出可用模型的客户端
+type ModelLister interface {
+	Models() []string
+	// DefaultModel 未指定模型时使用的模型
+	DefaultModel() string
+}
+
+// Registry Provider 注册表
+// 以驱动类型为 key 注册工厂函数，新增后端只需注册即可，无需改动 ioc 装配代码
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]ProviderFactory
+}
+
+// NewRegistry 创建空的 Provider 注册表
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]ProviderFactory)}
+}
+
+// Register 注册 Provider 驱动，同名驱动会被覆盖
+func (r *Registry) Register(driver string, factory ProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[driver] = factory
+}
+
+// New 使用指定驱动创建 ChatClient
+func (r *Registry) New(driver string, cfg ProviderConfig) (ChatClient, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[driver]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown llm provider %q, registered: %v", driver, r.Drivers())
+	}
+	return factory(cfg)
+}
+
+// Drivers 返回已注册的驱动名称 (有序)
+func (r *Registry) Drivers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	drivers := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		drivers = append(drivers, name)
+	}
+	sort.Strings(drivers)
+	return drivers
+}
+
+// defaultRegistry 内置 Provider 注册表
+var defaultRegistry = NewRegistry()
+
+// Register 向默认注册表注册 Provider 驱动
+func Register(driver string, factory ProviderFactory) {
+	defaultRegistry.Register(driver, factory)
+}
+
+// NewProvider 使用默认注册表创建 ChatClient
+func NewProvider(driver string, cfg ProviderConfig) (ChatClient, error) {
+	return defaultRegistry.New(driver, cfg)
+}
+
+func init() {
+	Register("qwen", openAICompatible("https://dashscope.aliyuncs.com/compatible-mode/v1", "qwen-plus", true))
+	Register("openai", openAICompatible("https://api.openai.com/v1", "gpt-4o-mini", true))
+	Register("deepseek", openAICompatible("https://api.deepseek.com/v1", "deepseek-chat", true))
+	// Ollama 本地部署无需鉴权
+	Register("ollama", openAICompatible("http://localhost:11434/v1", "qwen2.5", false))
+	Register("fake", func(cfg ProviderConfig) (ChatClient, error) {
+		return NewFakeClient(cfg.Model, cfg.Models), nil
+	})
+}
+
+// openAICompatible 返回 OpenAI 兼容协议 Provider 的工厂函数
+func openAICompatible(defaultBaseURL, defaultModel string, requireKey bool) ProviderFactory {
+	return func(cfg ProviderConfig) (ChatClient, error) {
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = defaultBaseURL
+		}
+		if cfg.Model == "" {
+			cfg.Model = defaultModel
+		}
+		if cfg.APIKey == "" {
+			if requireKey {
+				return nil, fmt.Errorf("llm provider %q requires an api key", cfg.Name)
+			}
+			// OpenAI SDK 要求非空 Key
+			cfg.APIKey = "none"
+		}
+		return NewOpenAIClient(&OpenAIConfig{
+			APIKey:  cfg.APIKey,
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+			Models:  cfg.Models,
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ProviderCache 命中缓存时回复的 Provider 名称
+const ProviderCache = "cache"
+
+// ResponseStore 回复缓存的存储
+type ResponseStore interface {
+	// Get 获取缓存值，未命中时返回空字符串与 nil
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+}
+
+// ResponseCacheConfig 回复缓存配置
+type ResponseCacheConfig struct {
+	TTL time.Duration
+	// MaxMessages 参与缓存的对话最多包含的非 system 消息数 (即 key 中的最近 N 条消息)
+	// 更长的对话依赖更早的上下文，不走缓存
+	MaxMessages int
+	// ChunkRunes 回放时每个分片的字符数
+	ChunkRunes int
+	// ReplayInterval 回放时分片之间的间隔，模拟流式输出
+	ReplayInterval time.Duration
+}
+
+// CachingClient 带回复缓存的 ChatClient
+// 以 (模型, 生成参数, 工具, system 提示词, 最近 N 条消息) 归一化后的哈希为 key，
+// 命中时将缓存的回复按分片回放给 callback；工具调用与未正常结束的回复不缓存
+type CachingClient struct {
+	ChatClient
+	store ResponseStore
+	cfg   ResponseCacheConfig
+	l     *slog.Logger
+}
+
+// NewCachingClient 创建 CachingClient
+func NewCachingClient(client ChatClient, store ResponseStore, cfg ResponseCacheConfig, l *slog.Logger) *CachingClient {
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = 1
+	}
+	if cfg.ChunkRunes <= 0 {
+		cfg.ChunkRunes = 8
+	}
+	return &CachingClient{ChatClient: client, store: store, cfg: cfg, l: l.With("component", "llm_response_cache")}
+}
+
+// Models 返回被包装客户端的可用模型
+func (c *CachingClient) Models() []string {
+	if lister, ok := c.ChatClient.(ModelLister); ok {
+		return lister.Models()
+	}
+	return nil
+}
+
+// DefaultModel 返回被包装客户端的默认模型
+func (c *CachingClient) DefaultModel() string {
+	if lister, ok := c.ChatClient.(ModelLister); ok {
+		return lister.DefaultModel()
+	}
+	return ""
+}
+
+// cachedResponse 缓存中保存的回复
+type cachedResponse struct {
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Model        string `json:"model,omitempty"`
+}
+
+// StreamChat 流式对话，命中缓存时回放缓存的回复
+func (c *CachingClient) StreamChat(ctx context.Context, messages []Message, callback StreamCallback, opts ...Option) (*Message, error) {
+	key, ok := c.cacheKey(messages, opts)
+	if !ok {
+		metrics.LLMResponseCacheRequests.WithLabelValues("bypass").Inc()
+		return c.ChatClient.StreamChat(ctx, messages, callback, opts...)
+	}
+
+	if cached, ok := c.lookup(ctx, key); ok {
+		metrics.LLMResponseCacheRequests.WithLabelValues("hit").Inc()
+		if err := c.replay(ctx, cached.Content, callback); err != nil {
+			return nil, err
+		}
+		// 命中缓存不消耗 token，用量记为 0
+		return &Message{
+			Role:         "assistant",
+			Content:      cached.Content,
+			FinishReason: cached.FinishReason,
+			Provider:     ProviderCache,
+			Model:        cached.Model,
+		}, nil
+	}
+	metrics.LLMResponseCacheRequests.WithLabelValues("miss").Inc()
+
+	reply, err := c.ChatClient.StreamChat(ctx, messages, callback, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.ToolCalls) == 0 && reply.Content != "" && (reply.FinishReason == "" || reply.FinishReason == "stop") {
+		if err := c.store.Set(context.WithoutCancel(ctx), key, c.encode(reply), c.cfg.TTL); err != nil {
+			c.l.WarnContext(ctx, "cache response failed", logger.Error(err))
+		}
+	}
+	return reply, nil
+}
+
+// lookup 查询缓存，读取失败按未命中处理
+func (c *CachingClient) lookup(ctx context.Context, key string) (*cachedResponse, bool) {
+	value, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.l.WarnContext(ctx, "get cached response failed", logger.Error(err))
+		return nil, false
+	}
+	if value == "" {
+		return nil, false
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal([]byte(value), &cached); err != nil {
+		return nil, false
+	}
+	return &cached, true
+}
+
+// encode 编码待缓存的回复
+func (c *CachingClient) encode(reply *Message) string {
+	data, _ := json.Marshal(cachedResponse{
+		Content:      reply.Content,
+		FinishReason: reply.FinishReason,
+		Model:        reply.Model,
+	})
+	return string(data)
+}
+
+// replay 按分片回放缓存的回复
+func (c *CachingClient) replay(ctx context.Context, content string, callback StreamCallback) error {
+	for len(content) > 0 {
+		n, size := 0, 0
+		for size < len(content) && n < c.cfg.ChunkRunes {
+			_, w := utf8.DecodeRuneInString(content[size:])
+			size += w
+			n++
+		}
+		if err := callback(content[:size]); err != nil {
+			return err
+		}
+		content = content[size:]
+		if len(content) > 0 {
+			if err := sleepCtx(ctx, c.cfg.ReplayInterval); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cacheKey 计算缓存 key，对话不满足缓存条件时返回 false
+// 包含工具调用或工具结果的对话、超过 MaxMessages 条非 system 消息的对话不缓存
+func (c *CachingClient) cacheKey(messages []Message, opts []Option) (string, bool) {
+	var system, dialog []string
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, normalizeText(m.Content))
+		case "user", "assistant":
+			if len(m.ToolCalls) > 0 {
+				return "", false
+			}
+			dialog = append(dialog, m.Role+":"+normalizeText(m.Content))
+		default:
+			return "", false
+		}
+	}
+	if len(dialog) == 0 || len(dialog) > c.cfg.MaxMessages {
+		return "", false
+	}
+
+	o := ApplyOptions(opts)
+	model := o.Model
+	if model == "" {
+		model = c.DefaultModel()
+	}
+	toolNames := make([]string, len(o.Tools))
+	for i, t := range o.Tools {
+		toolNames[i] = t.Name
+	}
+
+	data, _ := json.Marshal(struct {
+		Model       string
+		Temperature *float32
+		TopP        *float32
+		MaxTokens   *int
+		Stop        []string
+		Tools       []string
+		System      []string
+		Dialog      []string
+	}{model, o.Temperature, o.TopP, o.MaxTokens, o.Stop, toolNames, system, dialog})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// normalizeText 归一化文本：忽略大小写与多余空白
+func normalizeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,228 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MessageOverheadTokens 每条消息的格式开销 (role 标记、分隔符等)
+const MessageOverheadTokens = 4
+
+// truncateMarker 截断文本时插入的提示
+const truncateMarker = "\n…(内容过长，已省略)…\n"
+
+// Tokenizer 文本 token 计数器
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// TokenizerFactory 根据模型名称创建 Tokenizer
+type TokenizerFactory func(model string) Tokenizer
+
+// EstimateTokenizer 基于字符类别的 token 估算器
+// 不依赖模型词表：CJK 字符按 CJKTokens 计，其余连续非空白字符按 CharsPerToken 个字符计 1 个 token
+// 估算值偏保守，用于上下文预算控制而非计费
+type EstimateTokenizer struct {
+	CJKTokens     float64 // 每个 CJK 字符的 token 数
+	CharsPerToken float64 // 拉丁字符每 token 平均字符数
+}
+
+// Count 估算文本的 token 数
+func (t EstimateTokenizer) Count(text string) int {
+	var cjk, other int
+	var tokens float64
+	flush := func() {
+		if other > 0 {
+			tokens += float64(other) / t.CharsPerToken
+			// 每个单词至少 1 个 token
+			if float64(other) < t.CharsPerToken {
+				tokens += 1 - float64(other)/t.CharsPerToken
+			}
+			other = 0
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flush()
+			cjk++
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			flush()
+			tokens++
+		default:
+			other++
+		}
+	}
+	flush()
+	tokens += float64(cjk) * t.CJKTokens
+	return int(tokens + 0.999)
+}
+
+// tokenizerRegistry 按模型名前缀注册的 Tokenizer
+type tokenizerRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]TokenizerFactory
+}
+
+var tokenizers = &tokenizerRegistry{
+	factories: map[string]TokenizerFactory{
+		// 通义千问 / DeepSeek 词表对中文更友好
+		"qwen":     estimate(0.7, 3.5),
+		"deepseek": estimate(0.7, 3.5),
+		"gpt-4o":   estimate(0.8, 4),
+		"gpt":      estimate(1.2, 4),
+	},
+}
+
+func estimate(cjk, chars float64) TokenizerFactory {
+	return func(string) Tokenizer {
+		return EstimateTokenizer{CJKTokens: cjk, CharsPerToken: chars}
+	}
+}
+
+// RegisterTokenizer 为指定模型名前缀注册 Tokenizer (如接入精确词表)
+// 匹配时取最长前缀
+func RegisterTokenizer(prefix string, factory TokenizerFactory) {
+	tokenizers.mu.Lock()
+	defer tokenizers.mu.Unlock()
+	tokenizers.factories[prefix] = factory
+}
+
+// TokenizerFor 返回模型对应的 Tokenizer，未注册的模型使用保守估算
+func TokenizerFor(model string) Tokenizer {
+	tokenizers.mu.RLock()
+	defer tokenizers.mu.RUnlock()
+
+	var best string
+	var factory TokenizerFactory
+	for prefix, f := range tokenizers.factories {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, factory = prefix, f
+		}
+	}
+	if factory == nil {
+		return EstimateTokenizer{CJKTokens: 1, CharsPerToken: 3}
+	}
+	return factory(model)
+}
+
+// contextWindows 常见模型的上下文窗口大小 (按最长前缀匹配)
+var contextWindows = map[string]int{
+	"qwen-turbo":    131072,
+	"qwen-plus":     131072,
+	"qwen-max":      32768,
+	"qwen-long":     1000000,
+	"deepseek-chat": 65536,
+	"deepseek":      65536,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"llama3":        8192,
+}
+
+// ContextWindow 返回模型的上下文窗口大小，overrides 优先 (精确匹配)
+// 未知模型返回 0，由调用方决定默认值
+func ContextWindow(model string, overrides map[string]int) int {
+	if n, ok := overrides[model]; ok && n > 0 {
+		return n
+	}
+	var best string
+	window := 0
+	for prefix, n := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, window = prefix, n
+		}
+	}
+	return window
+}
+
+// CountMessage 计算单条消息的 token 数 (含格式开销)
+func CountMessage(t Tokenizer, msg Message) int {
+	n := MessageOverheadTokens + t.Count(msg.Content)
+	for _, call := range msg.ToolCalls {
+		n += t.Count(call.Name) + t.Count(call.Arguments)
+	}
+	return n
+}
+
+// CountMessages 计算消息列表的 token 数
+func CountMessages(t Tokenizer, msgs []Message) int {
+	n := 0
+	for _, msg := range msgs {
+		n += CountMessage(t, msg)
+	}
+	return n
+}
+
+// EstimateUsage 估算一次调用的 token 用量
+// 用于 Provider 未返回用量或生成被中途取消的情况
+func EstimateUsage(model string, prompt []Message, completion string) Usage {
+	t := TokenizerFor(model)
+	return Usage{
+		PromptTokens:     CountMessages(t, prompt),
+		CompletionTokens: t.Count(completion),
+	}
+}
+
+// Truncate 将文本截断到 maxTokens 以内
+// 保留开头约 2/3 与结尾约 1/3，中间以省略提示替代，尽量保留问题与结论
+func Truncate(t Tokenizer, text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if t.Count(text) <= maxTokens {
+		return text
+	}
+	budget := maxTokens - t.Count(truncateMarker)
+	if budget <= 0 {
+		return prefixWithin(t, text, maxTokens)
+	}
+	head := prefixWithin(t, text, budget*2/3)
+	tail := suffixWithin(t, text[len(head):], budget-t.Count(head))
+	return head + truncateMarker + tail
+}
+
+// prefixWithin 返回 token 数不超过 maxTokens 的最长前缀 (按字符二分)
+func prefixWithin(t Tokenizer, text string, maxTokens int) string {
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.Count(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo])
+}
+
+// suffixWithin 返回 token 数不超过 maxTokens 的最长后缀 (按字符二分)
+func suffixWithin(t Tokenizer, text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.Count(string(runes[len(runes)-mid:])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[len(runes)-lo:])
+}
+
+func isCJK(r rune) bool {
+	return r >= utf8.RuneSelf && (unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r))
+}
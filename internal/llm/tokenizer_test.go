@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// runeTokenizer 每个字符计 1 个 token
+type runeTokenizer struct{}
+
+func (runeTokenizer) Count(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+func TestTruncate(t *testing.T) {
+	tk := runeTokenizer{}
+	text := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+	markerTokens := tk.Count(truncateMarker)
+
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		want      string
+	}{
+		{name: "fits", text: "hello", maxTokens: 5, want: "hello"},
+		{name: "no budget", text: "hello", maxTokens: 0, want: ""},
+		{name: "budget smaller than marker", text: text, maxTokens: markerTokens, want: text[:markerTokens]},
+		{
+			name:      "keeps head and tail",
+			text:      text,
+			maxTokens: markerTokens + 30,
+			want:      strings.Repeat("a", 20) + truncateMarker + strings.Repeat("b", 10),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Truncate(tk, tc.text, tc.maxTokens)
+			if got != tc.want {
+				t.Fatalf("Truncate() = %q, want %q", got, tc.want)
+			}
+			if n := tk.Count(got); n > max(tc.maxTokens, 0) {
+				t.Fatalf("Truncate() = %d tokens, want at most %d", n, tc.maxTokens)
+			}
+		})
+	}
+}
+
+func TestTruncate_EstimateTokenizer(t *testing.T) {
+	tk := TokenizerFor("qwen-plus")
+	text := strings.Repeat("上下文窗口预算控制。", 200)
+	for _, maxTokens := range []int{1, 10, 50, 300} {
+		got := Truncate(tk, text, maxTokens)
+		if n := tk.Count(got); n > maxTokens {
+			t.Errorf("Truncate(%d) = %d tokens", maxTokens, n)
+		}
+		if !strings.HasPrefix(text, strings.SplitN(got, truncateMarker, 2)[0]) {
+			t.Errorf("Truncate(%d) = %q, want a prefix of the text", maxTokens, got)
+		}
+	}
+}
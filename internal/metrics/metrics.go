@@ -0,0 +1,133 @@
+// Package metrics 定义业务 Prometheus 指标
+// 指标统一使用 coca_ 前缀，时长以秒为单位 (_seconds)，计数器以 _total 结尾，便于在 Grafana 中按前缀检索
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "coca"
+
+var (
+	// HTTPRequestDuration HTTP 请求耗时，route 为路由模板 (如 /chat/sessions/:id/messages)
+	// SSE 与 WebSocket 等长连接的耗时为整个连接的持续时间
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// LLMTimeToFirstToken 从发起模型调用到收到第一个 token 的时间
+	LLMTimeToFirstToken = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "time_to_first_token_seconds",
+		Help:      "Time from LLM request to first streamed token by model.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20},
+	}, []string{"model"})
+
+	// LLMGenerationDuration 一次模型调用的总耗时，status 为 ok、error 或 cancelled
+	LLMGenerationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "generation_duration_seconds",
+		Help:      "Total LLM generation time by model and status.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "status"})
+
+	// LLMTokens 模型调用消耗的 token 数，type 为 prompt 或 completion
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "LLM tokens consumed by model and type (prompt, completion).",
+	}, []string{"model", "type"})
+
+	// LLMResponseCacheRequests 模型回复缓存的查询次数，result 为 hit、miss 或 bypass (不满足缓存条件)
+	LLMResponseCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "response_cache_requests_total",
+		Help:      "LLM response cache lookups by result (hit, miss, bypass).",
+	}, []string{"result"})
+
+	// MessageCacheRequests 消息缓存的读取次数，op 为读取方式，result 为 hit 或 miss
+	MessageCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "message_cache",
+		Name:      "requests_total",
+		Help:      "Message cache reads by operation and result (hit, miss).",
+	}, []string{"op", "result"})
+
+	// KafkaProducerErrors Kafka 消息发送失败次数
+	KafkaProducerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "producer_errors_total",
+		Help:      "Kafka messages that failed to be produced by topic.",
+	}, []string{"topic"})
+
+	// KafkaConsumerBatchSize 批量消费模式下每批写入的消息数
+	KafkaConsumerBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_batch_size",
+		Help:      "Messages per batch handled by the batching consumer by topic.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"topic"})
+
+	// KafkaDLQWrites 写入死信队列的次数，status 为 ok 或 error
+	KafkaDLQWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dlq_writes_total",
+		Help:      "Messages written to the dead letter queue by topic and status.",
+	}, []string{"topic", "status"})
+
+	// KafkaDLQDepth 死信队列中未确认 (未重放或清理) 的消息数
+	KafkaDLQDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dlq_depth",
+		Help:      "Dead letter queue messages not yet replayed or purged by topic.",
+	}, []string{"topic"})
+
+	// OutboxEvents 消息发件箱事件数，result 为 enqueued、sent、retry、dead、lost (异步发送失败且写入发件箱失败) 或 direct (无 Kafka 直接落库)
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_total",
+		Help:      "Message outbox events by result (enqueued, sent, retry, dead, direct).",
+	}, []string{"result"})
+
+	// OutboxPending 发件箱中等待发布的事件数
+	OutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "pending",
+		Help:      "Message events waiting in the outbox to be relayed to Kafka.",
+	})
+)
+
+// RegisterConsumerLag 注册消费者 lag 指标，每次抓取时调用 lag 获取当前值
+func RegisterConsumerLag(topic, group string, lag func() int64) error {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "kafka",
+		Name:        "consumer_lag",
+		Help:        "Kafka consumer lag (messages behind the high watermark).",
+		ConstLabels: prometheus.Labels{"topic": topic, "group": group},
+	}, func() float64 { return float64(lag()) })
+	return prometheus.Register(gauge)
+}
+
+// Status 根据错误返回 ok 或 error 标签值
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
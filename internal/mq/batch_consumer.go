@@ -0,0 +1,164 @@
+package mq
+
+import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// batchItem 批次中解析成功的一条消息
+type batchItem struct {
+	ctx   context.Context
+	span  trace.Span
+	msg   kafka.Message
+	event *MessageEvent
+}
+
+// startBatch 批量消费模式
+// 按分区将消息分发给固定的 worker，每个 worker 攒满 batchSize 条或等待 batchTimeout 后整批写入并提交 offset；
+// 同一会话的消息按 SessionID 分区，始终由同一个 worker 按顺序处理
+func (c *Consumer) startBatch(ctx context.Context) error {
+	c.l.Info("starting batch consumer", "group", c.groupID,
+		"batch_size", c.batchSize, "batch_timeout", c.batchTimeout, "workers", c.workers)
+
+	queues := make([]chan kafka.Message, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, c.batchSize)
+		wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer wg.Done()
+			c.runBatchWorker(ctx, queue)
+		}(queues[i])
+	}
+	// 停止时等待各 worker 处理完已拉取的消息
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.l.Info("context cancelled, stopping batch consumer")
+				return ctx.Err()
+			}
+			c.l.Error("read message failed", logger.Error(err))
+			continue
+		}
+
+		select {
+		case queues[msg.Partition%len(queues)] <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runBatchWorker 攒批并处理，queue 关闭时处理剩余消息后退出
+func (c *Consumer) runBatchWorker(ctx context.Context, queue <-chan kafka.Message) {
+	// 已拉取的消息在停止时仍需写入并提交，处理过程不随 ctx 取消
+	ctx = context.WithoutCancel(ctx)
+
+	batch := make([]kafka.Message, 0, c.batchSize)
+	timer := time.NewTimer(c.batchTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		c.processBatch(ctx, batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case msg, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(c.batchTimeout)
+			}
+			batch = append(batch, msg)
+			if len(batch) >= c.batchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// processBatch 整批写入并提交 offset
+// 批量写入失败时退化为逐条处理，只有处理失败的消息进入死信队列
+func (c *Consumer) processBatch(ctx context.Context, msgs []kafka.Message) {
+	items := make([]batchItem, 0, len(msgs))
+	events := make([]*MessageEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		msgCtx, span := startConsumerSpan(ctx, msg, c.groupID)
+		var event MessageEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			c.l.ErrorContext(msgCtx, "unmarshal message failed", logger.Error(err),
+				"partition", msg.Partition, "offset", msg.Offset, "bytes", len(msg.Value))
+			c.sendToDLQ(msgCtx, msg, err)
+			endSpan(span, err)
+			continue
+		}
+		items = append(items, batchItem{ctx: msgCtx, span: span, msg: msg, event: &event})
+		events = append(events, &event)
+	}
+
+	if len(events) > 0 {
+		metrics.KafkaConsumerBatchSize.WithLabelValues(TopicChatMessages).Observe(float64(len(events)))
+		if err := c.handleBatchWithRetry(ctx, events); err != nil {
+			c.l.WarnContext(ctx, "handle batch failed, falling back to single messages", logger.Error(err), "count", len(events))
+			for _, item := range items {
+				err := c.handleWithRetry(item.ctx, item.event)
+				if err != nil {
+					c.l.ErrorContext(item.ctx, "handle message failed", logger.Error(err),
+						"session_id", item.event.SessionID, "message_id", item.event.ID,
+						"partition", item.msg.Partition, "offset", item.msg.Offset)
+					c.sendToDLQ(item.ctx, item.msg, err)
+				}
+				endSpan(item.span, err)
+			}
+		} else {
+			for _, item := range items {
+				endSpan(item.span, nil)
+			}
+		}
+	}
+
+	if err := c.commitMessage(ctx, msgs...); err != nil {
+		c.l.ErrorContext(ctx, "commit batch offsets failed", logger.Error(err), "count", len(msgs))
+	}
+}
+
+func (c *Consumer) handleBatchWithRetry(ctx context.Context, events []*MessageEvent) error {
+	var err error
+	for attempt := 0; attempt <= c.maxRetry; attempt++ {
+		if err = c.batchHandler(ctx, events); err == nil {
+			return nil
+		}
+		if attempt < c.maxRetry {
+			if !sleepWithContext(ctx, c.retryBackoff) {
+				return ctx.Err()
+			}
+		}
+	}
+	return err
+}
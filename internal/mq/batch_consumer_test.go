@@ -0,0 +1,232 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader 依次返回 queued 中的消息，之后阻塞到 ctx 结束；记录提交的消息
+type fakeReader struct {
+	queued chan kafka.Message
+
+	mu        sync.Mutex
+	committed []kafka.Message
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{queued: make(chan kafka.Message, len(msgs))}
+	for _, msg := range msgs {
+		r.queued <- msg
+	}
+	return r
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.queued:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Stats() kafka.ReaderStats { return kafka.ReaderStats{} }
+
+func (r *fakeReader) Close() error { return nil }
+
+func (r *fakeReader) committedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.committed)
+}
+
+// newTestConsumer 创建批量模式的 Consumer，死信写入 transport
+func newTestConsumer(reader messageReader, transport *fakeTransport) *Consumer {
+	return &Consumer{
+		reader:  reader,
+		groupID: "test-group",
+		dlqWriter: &kafka.Writer{
+			Addr:        kafka.TCP("localhost:9092"),
+			Topic:       TopicChatMessagesDLQ,
+			Transport:   transport,
+			MaxAttempts: 1,
+		},
+		maxRetry:     1,
+		retryBackoff: time.Millisecond,
+		batchSize:    3,
+		batchTimeout: 20 * time.Millisecond,
+		workers:      2,
+		l:            discardLogger,
+	}
+}
+
+// eventMessage 构造分区 partition 中第 offset 条消息，SessionID 与分区相同
+func eventMessage(t *testing.T, partition int, offset int64) kafka.Message {
+	t.Helper()
+	data, err := json.Marshal(&MessageEvent{ID: int64(partition)*100 + offset, SessionID: int64(partition), Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kafka.Message{Topic: TopicChatMessages, Partition: partition, Offset: offset, Value: data}
+}
+
+// waitFor 等待 cond 满足，超时则失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumer_StartBatch(t *testing.T) {
+	var msgs []kafka.Message
+	for offset := int64(0); offset < 4; offset++ {
+		for partition := 0; partition < 4; partition++ {
+			msgs = append(msgs, eventMessage(t, partition, offset))
+		}
+	}
+	reader := newFakeReader(msgs...)
+	c := newTestConsumer(reader, &fakeTransport{})
+
+	var mu sync.Mutex
+	var batches [][]*MessageEvent
+	c.RegisterBatchHandler(func(_ context.Context, events []*MessageEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, events)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	waitFor(t, func() bool { return reader.committedCount() == len(msgs) })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start() error = %v, want %v", err, context.Canceled)
+	}
+
+	last := make(map[int64]int64)
+	total := 0
+	for _, batch := range batches {
+		if len(batch) > c.batchSize {
+			t.Fatalf("batch of %d events exceeds batch size %d", len(batch), c.batchSize)
+		}
+		// 同一 worker 只处理固定的分区
+		worker := batch[0].SessionID % int64(c.workers)
+		for _, event := range batch {
+			if event.SessionID%int64(c.workers) != worker {
+				t.Fatalf("batch mixes partitions of different workers: %+v", batch)
+			}
+			if prev, ok := last[event.SessionID]; ok && event.ID <= prev {
+				t.Fatalf("session %d handled message %d after %d", event.SessionID, event.ID, prev)
+			}
+			last[event.SessionID] = event.ID
+			total++
+		}
+	}
+	if total != len(msgs) {
+		t.Fatalf("handled %d events, want %d", total, len(msgs))
+	}
+}
+
+func TestConsumer_ProcessBatchFallback(t *testing.T) {
+	transport := &fakeTransport{}
+	reader := newFakeReader()
+	c := newTestConsumer(reader, transport)
+
+	batchCalls := 0
+	c.RegisterBatchHandler(func(context.Context, []*MessageEvent) error {
+		batchCalls++
+		return errors.New("deadlock")
+	})
+	var handled []int64
+	c.RegisterHandler(func(_ context.Context, event *MessageEvent) error {
+		if event.ID == 1 {
+			return errors.New("invalid message")
+		}
+		handled = append(handled, event.ID)
+		return nil
+	})
+
+	malformed := kafka.Message{Topic: TopicChatMessages, Partition: 0, Offset: 3, Value: []byte("{")}
+	c.processBatch(context.Background(), []kafka.Message{eventMessage(t, 0, 1), eventMessage(t, 0, 2), malformed})
+
+	if batchCalls != c.maxRetry+1 {
+		t.Fatalf("batch handler called %d times, want %d", batchCalls, c.maxRetry+1)
+	}
+	if len(handled) != 1 || handled[0] != 2 {
+		t.Fatalf("handled = %v, want only message 2", handled)
+	}
+	// 逐条处理失败与无法解析的消息进入死信队列
+	dead := transport.written()
+	if len(dead) != 2 {
+		t.Fatalf("dlq received %d messages, want 2", len(dead))
+	}
+	for _, msg := range dead {
+		if msg.Topic != TopicChatMessagesDLQ || headerValue(msg, "dlq_error") == "" {
+			t.Fatalf("dlq message = %+v, want %s with error header", msg, TopicChatMessagesDLQ)
+		}
+	}
+	if got := reader.committedCount(); got != 3 {
+		t.Fatalf("committed %d messages, want 3", got)
+	}
+}
+
+func TestConsumer_RunBatchWorker(t *testing.T) {
+	reader := newFakeReader()
+	c := newTestConsumer(reader, &fakeTransport{})
+	c.batchSize = 10
+
+	var mu sync.Mutex
+	var sizes []int
+	c.RegisterBatchHandler(func(_ context.Context, events []*MessageEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(events))
+		return nil
+	})
+
+	queue := make(chan kafka.Message)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.runBatchWorker(context.Background(), queue)
+	}()
+
+	// 未攒满时等待 batchTimeout 后写入
+	queue <- eventMessage(t, 0, 1)
+	queue <- eventMessage(t, 0, 2)
+	waitFor(t, func() bool { return reader.committedCount() == 2 })
+
+	// 停止时写入剩余的消息
+	queue <- eventMessage(t, 0, 3)
+	close(queue)
+	<-done
+
+	if got := reader.committedCount(); got != 3 {
+		t.Fatalf("committed %d messages, want 3", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Fatalf("batch sizes = %v, want [2 1]", sizes)
+	}
+}
@@ -1,10 +1,12 @@
 package mq
 
 import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -13,17 +15,34 @@ import (
 
 // Consumer Kafka 消费者
 type Consumer struct {
-	reader        *kafka.Reader
+	reader        messageReader
+	groupID       string
 	handlers      []MessageHandler
+	batchHandler  BatchMessageHandler
 	dlqWriter     *kafka.Writer
 	maxRetry      int
 	retryBackoff  time.Duration
 	commitTimeout time.Duration
+	batchSize     int
+	batchTimeout  time.Duration
+	workers       int
+	l             *slog.Logger
+}
+
+// messageReader Consumer 使用的 kafka.Reader 方法
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
 }
 
 // MessageHandler 消息处理函数类型
 type MessageHandler func(ctx context.Context, event *MessageEvent) error
 
+// BatchMessageHandler 批量消息处理函数类型
+type BatchMessageHandler func(ctx context.Context, events []*MessageEvent) error
+
 // ConsumerConfig 消费者配置
 type ConsumerConfig struct {
 	Brokers        []string // Kafka Broker 地址列表
@@ -36,10 +55,14 @@ type ConsumerConfig struct {
 	RetryBackoffMS int
 	CommitTimeout  int // 毫秒
 	DLQTopic       string
+	// 批量消费：BatchSize 大于 1 且注册了批量处理函数时启用
+	BatchSize      int // 每批最多消息数
+	BatchTimeoutMS int // 攒批最长等待时间，默认 200
+	Workers        int // 并发处理的 worker 数，分区固定分配给 worker，默认 4
 }
 
 // NewConsumer 创建 Kafka 消费者
-func NewConsumer(cfg *ConsumerConfig) *Consumer {
+func NewConsumer(cfg *ConsumerConfig, l *slog.Logger) *Consumer {
 	if cfg.GroupID == "" {
 		cfg.GroupID = "coca-chat-consumer"
 	}
@@ -54,8 +77,8 @@ func NewConsumer(cfg *ConsumerConfig) *Consumer {
 		Brokers:        cfg.Brokers,
 		Topic:          TopicChatMessages,
 		GroupID:        cfg.GroupID,
-		MinBytes:       orDefaultInt(cfg.MinBytes, 10e3),  // 10KB
-		MaxBytes:       orDefaultInt(cfg.MaxBytes, 10e6),  // 10MB
+		MinBytes:       orDefaultInt(cfg.MinBytes, 10e3), // 10KB
+		MaxBytes:       orDefaultInt(cfg.MaxBytes, 10e6), // 10MB
 		MaxWait:        time.Duration(orDefaultInt(cfg.MaxWait, 500)) * time.Millisecond,
 		CommitInterval: 0, // 手动提交 offset
 		StartOffset:    startOffset,
@@ -66,21 +89,31 @@ func NewConsumer(cfg *ConsumerConfig) *Consumer {
 		dlqTopic = TopicChatMessagesDLQ
 	}
 	dlqWriter := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.Brokers...),
-		Topic:        dlqTopic,
-		Balancer:     &kafka.Hash{},
-		RequiredAcks: kafka.RequireAll,
-		Async:        false,
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  dlqTopic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		Async:                  false,
 		AllowAutoTopicCreation: true,
 	}
 
+	l = l.With("component", "kafka_consumer", "topic", TopicChatMessages)
+	if err := metrics.RegisterConsumerLag(TopicChatMessages, cfg.GroupID, func() int64 { return reader.Stats().Lag }); err != nil {
+		l.Warn("register consumer lag metric failed", logger.Error(err))
+	}
+
 	return &Consumer{
 		reader:        reader,
+		groupID:       cfg.GroupID,
 		handlers:      make([]MessageHandler, 0),
 		dlqWriter:     dlqWriter,
 		maxRetry:      orDefaultInt(cfg.MaxRetry, 3),
 		retryBackoff:  time.Duration(orDefaultInt(cfg.RetryBackoffMS, 200)) * time.Millisecond,
 		commitTimeout: time.Duration(orDefaultInt(cfg.CommitTimeout, 3000)) * time.Millisecond,
+		batchSize:     cfg.BatchSize,
+		batchTimeout:  time.Duration(orDefaultInt(cfg.BatchTimeoutMS, 200)) * time.Millisecond,
+		workers:       orDefaultInt(cfg.Workers, 4),
+		l:             l,
 	}
 }
 
@@ -89,17 +122,28 @@ func (c *Consumer) RegisterHandler(handler MessageHandler) {
 	c.handlers = append(c.handlers, handler)
 }
 
+// RegisterBatchHandler 注册批量消息处理函数，批量消费模式下代替逐条处理
+func (c *Consumer) RegisterBatchHandler(handler BatchMessageHandler) {
+	c.batchHandler = handler
+}
+
 // Start 启动消费者 (阻塞式)
 func (c *Consumer) Start(ctx context.Context) error {
-	log.Printf("[Kafka Consumer] Starting consumer for topic: %s", TopicChatMessages)
+	if c.batchSize > 1 && c.batchHandler != nil {
+		return c.startBatch(ctx)
+	}
+
+	c.l.Info("starting consumer", "group", c.groupID)
 	if len(c.handlers) == 0 {
-		log.Printf("[Kafka Consumer] No handlers registered, consumer will still read and commit offsets.")
+		c.l.Warn("no handlers registered, consumer will still read and commit offsets")
 	}
+	// ctx 仅用于停止拉取，已拉取的消息处理完并提交 offset 后才退出，避免停止时误入死信队列
+	procCtx := context.WithoutCancel(ctx)
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[Kafka Consumer] Context cancelled, stopping consumer")
+			c.l.Info("context cancelled, stopping consumer")
 			return ctx.Err()
 		default:
 			// 读取消息 (手动提交 Offset)
@@ -108,29 +152,37 @@ func (c *Consumer) Start(ctx context.Context) error {
 				if ctx.Err() != nil {
 					return ctx.Err()
 				}
-				log.Printf("[Kafka Consumer] Read message error: %v", err)
+				c.l.Error("read message failed", logger.Error(err))
 				continue
 			}
 
+			// 接续生产者的链路，持久化等处理均记录在该 Span 下
+			msgCtx, span := startConsumerSpan(procCtx, msg, c.groupID)
+
 			// 解析消息
 			var event MessageEvent
 			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("[Kafka Consumer] Unmarshal error: %v, value: %s", err, string(msg.Value))
-				c.sendToDLQ(ctx, msg, err)
-				c.commitMessage(ctx, msg)
+				c.l.ErrorContext(msgCtx, "unmarshal message failed", logger.Error(err),
+					"partition", msg.Partition, "offset", msg.Offset, "bytes", len(msg.Value))
+				c.sendToDLQ(msgCtx, msg, err)
+				c.commitMessage(procCtx, msg)
+				endSpan(span, err)
 				continue
 			}
 
-			if err := c.handleWithRetry(ctx, &event); err != nil {
-				log.Printf("[Kafka Consumer] Handler error: %v", err)
-				c.sendToDLQ(ctx, msg, err)
-				c.commitMessage(ctx, msg)
+			if err := c.handleWithRetry(msgCtx, &event); err != nil {
+				c.l.ErrorContext(msgCtx, "handle message failed", logger.Error(err),
+					"session_id", event.SessionID, "message_id", event.ID, "partition", msg.Partition, "offset", msg.Offset)
+				c.sendToDLQ(msgCtx, msg, err)
+				c.commitMessage(procCtx, msg)
+				endSpan(span, err)
 				continue
 			}
 
-			if err := c.commitMessage(ctx, msg); err != nil {
-				log.Printf("[Kafka Consumer] Commit error: %v", err)
+			if err := c.commitMessage(procCtx, msg); err != nil {
+				c.l.ErrorContext(msgCtx, "commit offset failed", logger.Error(err), "partition", msg.Partition, "offset", msg.Offset)
 			}
+			endSpan(span, nil)
 		}
 	}
 }
@@ -139,7 +191,7 @@ func (c *Consumer) Start(ctx context.Context) error {
 func (c *Consumer) StartAsync(ctx context.Context) {
 	go func() {
 		if err := c.Start(ctx); err != nil && ctx.Err() == nil {
-			log.Printf("[Kafka Consumer] Consumer stopped with error: %v", err)
+			c.l.Error("consumer stopped with error", logger.Error(err))
 		}
 	}()
 }
@@ -194,14 +246,14 @@ func (c *Consumer) handleWithRetry(ctx context.Context, event *MessageEvent) err
 	return lastErr
 }
 
-func (c *Consumer) commitMessage(ctx context.Context, msg kafka.Message) error {
+func (c *Consumer) commitMessage(ctx context.Context, msgs ...kafka.Message) error {
 	commitCtx := ctx
 	if c.commitTimeout > 0 {
 		var cancel context.CancelFunc
 		commitCtx, cancel = context.WithTimeout(ctx, c.commitTimeout)
 		defer cancel()
 	}
-	return c.reader.CommitMessages(commitCtx, msg)
+	return c.reader.CommitMessages(commitCtx, msgs...)
 }
 
 func (c *Consumer) sendToDLQ(ctx context.Context, msg kafka.Message, cause error) {
@@ -211,11 +263,13 @@ func (c *Consumer) sendToDLQ(ctx context.Context, msg kafka.Message, cause error
 	msgCopy := kafka.Message{
 		Key:     msg.Key,
 		Value:   msg.Value,
-		Headers: append(msg.Headers, kafka.Header{Key: "dlq_error", Value: []byte(cause.Error())}),
+		Headers: append(msg.Headers, kafka.Header{Key: headerDLQError, Value: []byte(cause.Error())}),
 		Time:    time.Now(),
 	}
-	if err := c.dlqWriter.WriteMessages(ctx, msgCopy); err != nil {
-		log.Printf("[Kafka Consumer] DLQ write failed: %v", err)
+	err := c.dlqWriter.WriteMessages(ctx, msgCopy)
+	metrics.KafkaDLQWrites.WithLabelValues(c.dlqWriter.Topic, metrics.Status(err)).Inc()
+	if err != nil {
+		c.l.ErrorContext(ctx, "write dlq failed", logger.Error(err), "partition", msg.Partition, "offset", msg.Offset)
 	}
 }
 
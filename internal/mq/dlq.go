@@ -0,0 +1,474 @@
+package mq
+
+import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// headerDLQError 死信消息中记录失败原因的消息头
+	headerDLQError = "dlq_error"
+	// headerDLQReplayedFrom 重放消息中记录来源位置的消息头 (partition:offset)
+	headerDLQReplayedFrom = "dlq_replayed_from"
+	// defaultDLQGroupID 记录死信处理进度的消费者组
+	defaultDLQGroupID = "coca-dlqctl"
+	// dlqReplayBatchSize 重放时单次写入的消息数
+	dlqReplayBatchSize = 100
+	// dlqReadTimeout 读取单条死信消息的超时时间
+	dlqReadTimeout = 10 * time.Second
+)
+
+// ErrDLQReplayNotPurged 带筛选条件的重放未同时清理
+// 处理进度只能按分区整体前移，单独重放的消息无法确认，会一直计入积压并可能被再次重放
+var ErrDLQReplayNotPurged = errors.New("filtered replay leaves replayed messages unacknowledged, replay with purge to acknowledge all dead letters")
+
+// DLQConfig 死信队列工具配置
+type DLQConfig struct {
+	Brokers     []string
+	Topic       string // 死信 Topic，默认 chat.messages.dlq
+	TargetTopic string // 重放的目标 Topic，默认 chat.messages
+	GroupID     string // 记录处理进度的消费者组，默认 coca-dlqctl
+}
+
+// DLQPosition 死信消息在 Topic 中的位置
+type DLQPosition struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// String 返回 partition:offset 格式的位置
+func (p DLQPosition) String() string {
+	return fmt.Sprintf("%d:%d", p.Partition, p.Offset)
+}
+
+// ParseDLQPosition 解析 partition:offset 格式的位置
+func ParseDLQPosition(value string) (DLQPosition, error) {
+	var pos DLQPosition
+	if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d:%d", &pos.Partition, &pos.Offset); err != nil {
+		return pos, fmt.Errorf("invalid position %q, want partition:offset", value)
+	}
+	return pos, nil
+}
+
+// DLQEntry 死信队列中的一条消息
+type DLQEntry struct {
+	DLQPosition
+	Key       string    `json:"key"`
+	SessionID int64     `json:"session_id"`
+	MessageID int64     `json:"message_id"`
+	Role      string    `json:"role,omitempty"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+	Value     string    `json:"value"`
+	Acked     bool      `json:"acked"` // 已被全部重放或清理确认
+}
+
+// DLQFilter 死信消息筛选条件，各条件同时满足才匹配，零值匹配所有未确认的消息
+type DLQFilter struct {
+	SessionID     int64
+	ErrorContains string
+	Positions     []DLQPosition
+	IncludeAcked  bool // 是否包含已确认的消息
+	Limit         int  // 最多返回条数，0 表示不限制
+}
+
+// selectsAll 是否未设置任何筛选条件
+func (f *DLQFilter) selectsAll() bool {
+	return f.SessionID == 0 && f.ErrorContains == "" && len(f.Positions) == 0 && !f.IncludeAcked && f.Limit == 0
+}
+
+func (f *DLQFilter) match(entry *DLQEntry) bool {
+	if f.SessionID != 0 && entry.SessionID != f.SessionID {
+		return false
+	}
+	if f.ErrorContains != "" && !strings.Contains(strings.ToLower(entry.Error), strings.ToLower(f.ErrorContains)) {
+		return false
+	}
+	if len(f.Positions) > 0 {
+		for _, pos := range f.Positions {
+			if pos == entry.DLQPosition {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// partitionReader 按 offset 顺序读取单个分区的消息
+type partitionReader interface {
+	SetOffset(offset int64) error
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// DLQ 死信队列的查看、重放与清理
+// Kafka 不支持删除单条消息，处理进度以消费者组 offset 记录：
+// 全部重放或清理会将 offset 提交到末尾，之前的消息视为已确认，不再计入积压
+type DLQ struct {
+	client    *kafka.Client
+	writer    *kafka.Writer
+	newReader func(partition int) partitionReader
+	topic     string
+	target    string
+	groupID   string
+	l         *slog.Logger
+}
+
+// NewDLQ 创建死信队列工具
+func NewDLQ(cfg *DLQConfig, l *slog.Logger) *DLQ {
+	topic := cfg.Topic
+	if topic == "" {
+		topic = TopicChatMessagesDLQ
+	}
+	target := cfg.TargetTopic
+	if target == "" {
+		target = TopicChatMessages
+	}
+	groupID := cfg.GroupID
+	if groupID == "" {
+		groupID = defaultDLQGroupID
+	}
+
+	return &DLQ{
+		client: &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: 10 * time.Second},
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        target,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		newReader: func(partition int) partitionReader {
+			return kafka.NewReader(kafka.ReaderConfig{
+				Brokers:   cfg.Brokers,
+				Topic:     topic,
+				Partition: partition,
+				MaxWait:   time.Second,
+			})
+		},
+		topic:   topic,
+		target:  target,
+		groupID: groupID,
+		l:       l.With("component", "dlq", "topic", topic),
+	}
+}
+
+// Topic 返回死信 Topic
+func (d *DLQ) Topic() string {
+	return d.topic
+}
+
+// partitionRange 分区中可读的 offset 范围
+type partitionRange struct {
+	partition int
+	first     int64 // 最早保留的 offset
+	last      int64 // 下一条消息的 offset
+	committed int64 // 已确认到的 offset (不含)
+}
+
+// ranges 获取各分区的 offset 范围与确认进度，Topic 不存在时返回空
+func (d *DLQ) ranges(ctx context.Context) ([]partitionRange, error) {
+	meta, err := d.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{d.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != d.topic {
+			continue
+		}
+		if t.Error != nil {
+			if errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("fetch metadata failed: %w", t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, nil
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, len(partitions)*2)
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := d.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{d.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list offsets failed: %w", err)
+	}
+	committed, err := d.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: d.groupID,
+		Topics:  map[string][]int{d.topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch committed offsets failed: %w", err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("fetch committed offsets failed: %w", committed.Error)
+	}
+	committedOf := make(map[int]int64, len(partitions))
+	for _, p := range committed.Topics[d.topic] {
+		if p.Error == nil {
+			committedOf[p.Partition] = p.CommittedOffset
+		}
+	}
+
+	result := make([]partitionRange, 0, len(partitions))
+	for _, p := range offsets.Topics[d.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("list offsets of partition %d failed: %w", p.Partition, p.Error)
+		}
+		r := partitionRange{partition: p.Partition, first: p.FirstOffset, last: p.LastOffset, committed: p.FirstOffset}
+		// 未提交过时为 -1；已过期的消息不再计入
+		if c, ok := committedOf[p.Partition]; ok && c > r.committed {
+			r.committed = min(c, r.last)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Depth 返回未确认的死信消息数
+func (d *DLQ) Depth(ctx context.Context) (int64, error) {
+	ranges, err := d.ranges(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var depth int64
+	for _, r := range ranges {
+		depth += r.last - r.committed
+	}
+	return depth, nil
+}
+
+// List 列出符合条件的死信消息，按分区与 offset 排序
+func (d *DLQ) List(ctx context.Context, filter DLQFilter) ([]DLQEntry, error) {
+	entries := make([]DLQEntry, 0)
+	_, err := d.scan(ctx, filter, func(entry DLQEntry, _ kafka.Message) (bool, error) {
+		entries = append(entries, entry)
+		return filter.Limit == 0 || len(entries) < filter.Limit, nil
+	})
+	return entries, err
+}
+
+// Replay 将符合条件的死信消息重新发送到目标 Topic，返回重放条数
+// 未设置筛选条件时重放全部未确认的消息，并将其标记为已确认；
+// 设置了筛选条件时必须同时 purge：重放后将全部死信消息 (包括未被选中的) 标记为已确认，否则返回 ErrDLQReplayNotPurged
+func (d *DLQ) Replay(ctx context.Context, filter DLQFilter, purge bool) (int, error) {
+	if !filter.selectsAll() && !purge {
+		return 0, ErrDLQReplayNotPurged
+	}
+
+	replayed := 0
+	pending := make([]kafka.Message, 0, dlqReplayBatchSize)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := d.writer.WriteMessages(ctx, pending...); err != nil {
+			return fmt.Errorf("write messages to %s failed: %w", d.target, err)
+		}
+		replayed += len(pending)
+		pending = pending[:0]
+		return nil
+	}
+
+	ranges, err := d.scan(ctx, filter, func(entry DLQEntry, msg kafka.Message) (bool, error) {
+		headers := make([]kafka.Header, 0, len(msg.Headers)+1)
+		for _, h := range msg.Headers {
+			if h.Key != headerDLQError && h.Key != headerDLQReplayedFrom {
+				headers = append(headers, h)
+			}
+		}
+		headers = append(headers, kafka.Header{Key: headerDLQReplayedFrom, Value: []byte(entry.DLQPosition.String())})
+		pending = append(pending, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+		if len(pending) >= dlqReplayBatchSize {
+			if err := flush(); err != nil {
+				return false, err
+			}
+		}
+		return filter.Limit == 0 || replayed+len(pending) < filter.Limit, nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return replayed, err
+	}
+
+	d.l.InfoContext(ctx, "replayed dlq messages", "count", replayed, "target", d.target, "purge", purge)
+	if err := d.commit(ctx, ranges); err != nil {
+		return replayed, err
+	}
+	return replayed, nil
+}
+
+// Purge 将全部死信消息标记为已确认，返回确认的条数
+func (d *DLQ) Purge(ctx context.Context) (int64, error) {
+	ranges, err := d.ranges(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	for _, r := range ranges {
+		purged += r.last - r.committed
+	}
+	if err := d.commit(ctx, ranges); err != nil {
+		return 0, err
+	}
+	d.l.InfoContext(ctx, "purged dlq messages", "count", purged)
+	return purged, nil
+}
+
+// scan 按分区顺序读取死信消息，fn 返回 false 时停止，返回扫描时的 offset 范围
+func (d *DLQ) scan(ctx context.Context, filter DLQFilter, fn func(entry DLQEntry, msg kafka.Message) (bool, error)) ([]partitionRange, error) {
+	ranges, err := d.ranges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range ranges {
+		start := r.committed
+		if filter.IncludeAcked {
+			start = r.first
+		}
+		if start >= r.last {
+			continue
+		}
+
+		more, err := d.scanPartition(ctx, r, start, &filter, fn)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+	}
+	return ranges, nil
+}
+
+func (d *DLQ) scanPartition(ctx context.Context, r partitionRange, start int64, filter *DLQFilter, fn func(entry DLQEntry, msg kafka.Message) (bool, error)) (bool, error) {
+	reader := d.newReader(r.partition)
+	defer reader.Close()
+	if err := reader.SetOffset(start); err != nil {
+		return false, fmt.Errorf("set offset failed: %w", err)
+	}
+
+	for offset := start; offset < r.last; {
+		readCtx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			return false, fmt.Errorf("read partition %d at offset %d failed: %w", r.partition, offset, err)
+		}
+		offset = msg.Offset + 1
+		if msg.Offset >= r.last {
+			break
+		}
+
+		entry := toDLQEntry(msg)
+		entry.Acked = msg.Offset < r.committed
+		if !filter.match(&entry) {
+			continue
+		}
+		more, err := fn(entry, msg)
+		if err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// commit 将各分区的确认进度提交到末尾
+func (d *DLQ) commit(ctx context.Context, ranges []partitionRange) error {
+	commits := make([]kafka.OffsetCommit, 0, len(ranges))
+	for _, r := range ranges {
+		if r.last > r.committed {
+			commits = append(commits, kafka.OffsetCommit{Partition: r.partition, Offset: r.last})
+		}
+	}
+	if len(commits) == 0 {
+		return nil
+	}
+
+	resp, err := d.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      d.groupID,
+		GenerationID: -1, // 不加入消费者组，直接提交
+		Topics:       map[string][]kafka.OffsetCommit{d.topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("commit offsets failed: %w", err)
+	}
+	for _, p := range resp.Topics[d.topic] {
+		if p.Error != nil {
+			return fmt.Errorf("commit offset of partition %d failed: %w", p.Partition, p.Error)
+		}
+	}
+	return nil
+}
+
+// StartDepthMetric 定期更新死信队列积压指标 (非阻塞)
+func (d *DLQ) StartDepthMetric(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			depth, err := d.Depth(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				d.l.Warn("fetch dlq depth failed", logger.Error(err))
+			} else {
+				metrics.KafkaDLQDepth.WithLabelValues(d.topic).Set(float64(depth))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close 关闭死信队列工具
+func (d *DLQ) Close() error {
+	return d.writer.Close()
+}
+
+// toDLQEntry 解析死信消息，无法解析的消息仅保留原始内容
+func toDLQEntry(msg kafka.Message) DLQEntry {
+	entry := DLQEntry{
+		DLQPosition: DLQPosition{Partition: msg.Partition, Offset: msg.Offset},
+		Key:         string(msg.Key),
+		Time:        msg.Time,
+		Value:       string(msg.Value),
+	}
+	for _, h := range msg.Headers {
+		if h.Key == headerDLQError {
+			entry.Error = string(h.Value)
+		}
+	}
+	var event MessageEvent
+	if err := json.Unmarshal(msg.Value, &event); err == nil {
+		entry.SessionID = event.SessionID
+		entry.MessageID = event.ID
+		entry.Role = event.Role
+	}
+	return entry
+}
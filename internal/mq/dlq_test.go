@@ -0,0 +1,221 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// newTestDLQ 创建读写 transport 的 DLQ，死信 Topic 有两个分区
+func newTestDLQ(transport *fakeTransport) *DLQ {
+	transport.partitions = map[string]int{TopicChatMessagesDLQ: 2}
+	d := NewDLQ(&DLQConfig{Brokers: []string{"localhost:9092"}}, discardLogger)
+	d.client.Transport = transport
+	d.writer.Transport = transport
+	d.newReader = transport.reader(TopicChatMessagesDLQ)
+	return d
+}
+
+// addDeadLetter 写入一条死信消息
+func addDeadLetter(t *testing.T, transport *fakeTransport, partition int, event *MessageEvent, cause string) {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.add(kafka.Message{
+		Topic:     TopicChatMessagesDLQ,
+		Partition: partition,
+		Key:       []byte("key"),
+		Value:     data,
+		Headers: []kafka.Header{
+			{Key: headerRequestID, Value: []byte("req-1")},
+			{Key: headerDLQError, Value: []byte(cause)},
+		},
+	})
+}
+
+func seedDeadLetters(t *testing.T, transport *fakeTransport) {
+	t.Helper()
+	addDeadLetter(t, transport, 0, &MessageEvent{ID: 1, SessionID: 7, Role: "user"}, "Deadlock found")
+	addDeadLetter(t, transport, 0, &MessageEvent{ID: 2, SessionID: 8, Role: "assistant"}, "data too long")
+	addDeadLetter(t, transport, 1, &MessageEvent{ID: 3, SessionID: 7, Role: "assistant"}, "deadlock found")
+}
+
+func TestDLQFilter_match(t *testing.T) {
+	entry := DLQEntry{DLQPosition: DLQPosition{Partition: 1, Offset: 5}, SessionID: 7, Error: "Deadlock found"}
+	tests := []struct {
+		name   string
+		filter DLQFilter
+		want   bool
+	}{
+		{name: "empty", filter: DLQFilter{}, want: true},
+		{name: "session", filter: DLQFilter{SessionID: 7}, want: true},
+		{name: "other session", filter: DLQFilter{SessionID: 8}, want: false},
+		{name: "error ignores case", filter: DLQFilter{ErrorContains: "DEADLOCK"}, want: true},
+		{name: "other error", filter: DLQFilter{ErrorContains: "timeout"}, want: false},
+		{name: "position", filter: DLQFilter{Positions: []DLQPosition{{Partition: 0, Offset: 5}, {Partition: 1, Offset: 5}}}, want: true},
+		{name: "other position", filter: DLQFilter{Positions: []DLQPosition{{Partition: 0, Offset: 5}}}, want: false},
+		{name: "all conditions", filter: DLQFilter{SessionID: 7, ErrorContains: "timeout"}, want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.match(&entry); got != tc.want {
+				t.Fatalf("match() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDLQPosition(t *testing.T) {
+	pos, err := ParseDLQPosition(" 2:15 ")
+	if err != nil || pos != (DLQPosition{Partition: 2, Offset: 15}) {
+		t.Fatalf("ParseDLQPosition() = %+v, %v", pos, err)
+	}
+	if pos.String() != "2:15" {
+		t.Fatalf("String() = %q, want %q", pos.String(), "2:15")
+	}
+	for _, raw := range []string{"", "2", "a:b"} {
+		if _, err := ParseDLQPosition(raw); err == nil {
+			t.Errorf("ParseDLQPosition(%q) returned no error", raw)
+		}
+	}
+}
+
+func TestDLQ_List(t *testing.T) {
+	transport := &fakeTransport{}
+	seedDeadLetters(t, transport)
+	d := newTestDLQ(transport)
+	ctx := context.Background()
+
+	entries, err := d.List(ctx, DLQFilter{ErrorContains: "deadlock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, entry := range entries {
+		ids = append(ids, entry.MessageID)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 3}) {
+		t.Fatalf("listed messages %v, want [1 3]", ids)
+	}
+	if entries[1].DLQPosition != (DLQPosition{Partition: 1, Offset: 0}) || entries[1].SessionID != 7 || entries[1].Role != "assistant" {
+		t.Fatalf("entry = %+v", entries[1])
+	}
+
+	entries, err = d.List(ctx, DLQFilter{Limit: 1})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List(limit 1) = %d entries, %v", len(entries), err)
+	}
+}
+
+func TestDLQ_ReplayAll(t *testing.T) {
+	transport := &fakeTransport{}
+	seedDeadLetters(t, transport)
+	d := newTestDLQ(transport)
+	ctx := context.Background()
+
+	if depth, err := d.Depth(ctx); err != nil || depth != 3 {
+		t.Fatalf("Depth() = %d, %v, want 3", depth, err)
+	}
+
+	replayed, err := d.Replay(ctx, DLQFilter{}, false)
+	if err != nil || replayed != 3 {
+		t.Fatalf("Replay() = %d, %v, want 3", replayed, err)
+	}
+	msgs := transport.writtenTo(TopicChatMessages)
+	if len(msgs) != 3 {
+		t.Fatalf("target received %d messages, want 3", len(msgs))
+	}
+	for _, msg := range msgs {
+		if headerValue(msg, headerDLQError) != "" {
+			t.Fatalf("replayed message keeps the dlq error header: %+v", msg.Headers)
+		}
+		if headerValue(msg, headerRequestID) != "req-1" || headerValue(msg, headerDLQReplayedFrom) == "" {
+			t.Fatalf("replayed message headers = %+v", msg.Headers)
+		}
+	}
+
+	// 全部重放后确认，不再计入积压，也不会被再次重放
+	if depth, err := d.Depth(ctx); err != nil || depth != 0 {
+		t.Fatalf("Depth() after replay = %d, %v, want 0", depth, err)
+	}
+	if replayed, err := d.Replay(ctx, DLQFilter{}, false); err != nil || replayed != 0 {
+		t.Fatalf("second Replay() = %d, %v, want 0", replayed, err)
+	}
+}
+
+func TestDLQ_ReplayFiltered(t *testing.T) {
+	transport := &fakeTransport{}
+	seedDeadLetters(t, transport)
+	d := newTestDLQ(transport)
+	ctx := context.Background()
+	filter := DLQFilter{SessionID: 7}
+
+	// 单独重放的消息无法确认，不清理时拒绝执行
+	if _, err := d.Replay(ctx, filter, false); !errors.Is(err, ErrDLQReplayNotPurged) {
+		t.Fatalf("Replay() without purge error = %v, want %v", err, ErrDLQReplayNotPurged)
+	}
+	if msgs := transport.writtenTo(TopicChatMessages); len(msgs) != 0 {
+		t.Fatalf("rejected replay wrote %d messages", len(msgs))
+	}
+
+	replayed, err := d.Replay(ctx, filter, true)
+	if err != nil || replayed != 2 {
+		t.Fatalf("Replay() with purge = %d, %v, want 2", replayed, err)
+	}
+	var ids []int64
+	for _, msg := range transport.writtenTo(TopicChatMessages) {
+		var event MessageEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, event.ID)
+	}
+	slices.Sort(ids)
+	if !reflect.DeepEqual(ids, []int64{1, 3}) {
+		t.Fatalf("replayed messages %v, want [1 3]", ids)
+	}
+	if depth, err := d.Depth(ctx); err != nil || depth != 0 {
+		t.Fatalf("Depth() after replay with purge = %d, %v, want 0", depth, err)
+	}
+}
+
+func TestDLQ_Purge(t *testing.T) {
+	transport := &fakeTransport{}
+	seedDeadLetters(t, transport)
+	d := newTestDLQ(transport)
+	ctx := context.Background()
+
+	purged, err := d.Purge(ctx)
+	if err != nil || purged != 3 {
+		t.Fatalf("Purge() = %d, %v, want 3", purged, err)
+	}
+	if depth, err := d.Depth(ctx); err != nil || depth != 0 {
+		t.Fatalf("Depth() after purge = %d, %v, want 0", depth, err)
+	}
+	if entries, err := d.List(ctx, DLQFilter{}); err != nil || len(entries) != 0 {
+		t.Fatalf("List() after purge = %d entries, %v, want none", len(entries), err)
+	}
+
+	entries, err := d.List(ctx, DLQFilter{IncludeAcked: true})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("List(include acked) = %d entries, %v, want 3", len(entries), err)
+	}
+	for _, entry := range entries {
+		if !entry.Acked {
+			t.Fatalf("entry %s not acked after purge", entry.DLQPosition)
+		}
+	}
+
+	// 确认之后写入的死信重新计入积压
+	addDeadLetter(t, transport, 1, &MessageEvent{ID: 4, SessionID: 9}, "timeout")
+	if depth, err := d.Depth(ctx); err != nil || depth != 1 {
+		t.Fatalf("Depth() after new dead letter = %d, %v, want 1", depth, err)
+	}
+}
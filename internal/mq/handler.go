@@ -2,74 +2,140 @@ package mq
 
 import (
 	"coca-ai/internal/domain"
+	"coca-ai/internal/repository"
 	"coca-ai/internal/repository/dao"
+	"coca-ai/pkg/logger"
+	"coca-ai/pkg/snowflake"
 	"context"
-	"log"
+	"log/slog"
 	"time"
 )
 
 // MessagePersistHandler 消息持久化处理器
 // 负责将 Kafka 消息写入 MySQL
 type MessagePersistHandler struct {
-	dao *dao.MessageDAO
+	dao   *dao.MessageDAO
+	idGen *snowflake.Node
+	l     *slog.Logger
 }
 
 // NewMessagePersistHandler 创建消息持久化处理器
-func NewMessagePersistHandler(dao *dao.MessageDAO) *MessagePersistHandler {
-	return &MessagePersistHandler{dao: dao}
+func NewMessagePersistHandler(dao *dao.MessageDAO, idGen *snowflake.Node, l *slog.Logger) *MessagePersistHandler {
+	return &MessagePersistHandler{dao: dao, idGen: idGen, l: l.With("component", "message_persist_handler")}
 }
 
 // Handle 处理消息事件，持久化到 MySQL
 func (h *MessagePersistHandler) Handle(ctx context.Context, event *MessageEvent) error {
-	// 转换为 DAO 实体
-	entity := &dao.Message{
-		Id:        event.ID,
-		SessionId: event.SessionID,
-		Role:      event.Role,
-		Content:   event.Content,
-		CreatedAt: event.CreatedAt,
+	entity := h.toEntity(event)
+
+	// 消息 ID 在发布前由 ChatService 分配，与 Redis 缓存中的一致；
+	// 按主键幂等写入，重复投递的事件为空操作，ID 冲突等其他错误返回给消费者重试或进入死信队列
+	inserted, err := h.dao.CreateOrIgnore(ctx, entity)
+	if err != nil {
+		h.l.ErrorContext(ctx, "persist message failed", logger.Error(err),
+			"session_id", event.SessionID, "message_id", entity.Id)
+		return err
+	}
+	if !inserted {
+		h.l.DebugContext(ctx, "message already persisted, skip duplicate event",
+			"session_id", event.SessionID, "message_id", entity.Id)
+		return nil
+	}
+	h.l.DebugContext(ctx, "persisted message", "session_id", event.SessionID, "message_id", entity.Id, "role", event.Role)
+	return nil
+}
+
+// HandleBatch 批量处理消息事件，在一个事务中幂等写入 MySQL
+func (h *MessagePersistHandler) HandleBatch(ctx context.Context, events []*MessageEvent) error {
+	entities := make([]dao.Message, len(events))
+	for i, event := range events {
+		entities[i] = *h.toEntity(event)
+	}
+	if err := h.dao.BatchCreate(ctx, entities); err != nil {
+		h.l.ErrorContext(ctx, "batch persist messages failed", logger.Error(err), "count", len(events))
+		return err
 	}
+	h.l.DebugContext(ctx, "persisted message batch", "count", len(events))
+	return nil
+}
 
-	// 如果 ID 为 0，表示新消息，需要创建
-	// 如果 ID 不为 0，表示已有消息，需要更新（或幂等处理）
+// toEntity 将 MessageEvent 转换为 DAO 实体
+// 兼容未分配 ID 的旧事件：由 ID 生成器补分配，不使用数据库自增，避免与预分配的 ID 冲突
+func (h *MessagePersistHandler) toEntity(event *MessageEvent) *dao.Message {
+	entity := eventToEntity(event)
 	if entity.Id == 0 {
-		entity.CreatedAt = time.Now().UnixMilli()
-		if err := h.dao.Create(ctx, entity); err != nil {
-			log.Printf("[MessagePersistHandler] Create message failed: %v", err)
-			return err
-		}
-		log.Printf("[MessagePersistHandler] Created message for session %d, role: %s",
-			event.SessionID, event.Role)
-	} else {
-		// 幂等处理：检查是否已存在，不存在则创建
-		// 这里简化处理，直接创建（GORM 会处理主键冲突）
-		if err := h.dao.Create(ctx, entity); err != nil {
-			// 忽略主键冲突错误，实现幂等
-			log.Printf("[MessagePersistHandler] Message may already exist: %v", err)
-		}
+		entity.Id = h.idGen.Generate()
 	}
+	return entity
+}
 
-	return nil
+// eventToEntity 将 MessageEvent 转换为 DAO 实体
+func eventToEntity(event *MessageEvent) *dao.Message {
+	return &dao.Message{
+		Id:               event.ID,
+		SessionId:        event.SessionID,
+		ParentId:         event.ParentID,
+		Role:             event.Role,
+		Content:          event.Content,
+		ToolCalls:        repository.EncodeToolCalls(EventToDomain(event).ToolCalls),
+		ToolCallId:       event.ToolCallID,
+		FinishReason:     event.FinishReason,
+		PromptTokens:     event.PromptTokens,
+		CompletionTokens: event.CompletionTokens,
+		Provider:         event.Provider,
+		Model:            event.Model,
+		CreatedAt:        event.CreatedAt,
+	}
 }
 
 // EventToDomain 将 MessageEvent 转换为 domain.Message
 func EventToDomain(event *MessageEvent) *domain.Message {
-	return &domain.Message{
-		ID:        event.ID,
-		SessionID: event.SessionID,
-		Role:      domain.MessageRole(event.Role),
-		Content:   event.Content,
-		CreatedAt: time.UnixMilli(event.CreatedAt),
+	msg := &domain.Message{
+		ID:               event.ID,
+		SessionID:        event.SessionID,
+		ParentID:         event.ParentID,
+		Role:             domain.MessageRole(event.Role),
+		Content:          event.Content,
+		ToolCallID:       event.ToolCallID,
+		FinishReason:     event.FinishReason,
+		PromptTokens:     event.PromptTokens,
+		CompletionTokens: event.CompletionTokens,
+		Provider:         event.Provider,
+		Model:            event.Model,
+		CreatedAt:        time.UnixMilli(event.CreatedAt),
+	}
+	for _, call := range event.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, domain.ToolCall{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
 	}
+	return msg
 }
 
 // DomainToEvent 将 domain.Message 转换为 MessageEvent
 func DomainToEvent(msg *domain.Message) *MessageEvent {
-	return &MessageEvent{
-		ID:        msg.ID,
-		SessionID: msg.SessionID,
-		Role:      string(msg.Role),
-		Content:   msg.Content,
-		CreatedAt: msg.CreatedAt.UnixMilli(),
+	event := &MessageEvent{
+		ID:               msg.ID,
+		SessionID:        msg.SessionID,
+		ParentID:         msg.ParentID,
+		Role:             string(msg.Role),
+		Content:          msg.Content,
+		ToolCallID:       msg.ToolCallID,
+		FinishReason:     msg.FinishReason,
+		PromptTokens:     msg.PromptTokens,
+		CompletionTokens: msg.CompletionTokens,
+		Provider:         msg.Provider,
+		Model:            msg.Model,
+		CreatedAt:        msg.CreatedAt.UnixMilli(),
+	}
+	for _, call := range msg.ToolCalls {
+		event.ToolCalls = append(event.ToolCalls, ToolCallEvent{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
 	}
+	return event
 }
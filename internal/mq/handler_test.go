@@ -0,0 +1,40 @@
+package mq
+
+import (
+	"coca-ai/internal/repository/dao"
+	"coca-ai/internal/repository/dao/daotest"
+	"coca-ai/pkg/snowflake"
+	"context"
+	"testing"
+)
+
+func TestMessagePersistHandler_LegacyEventGetsGeneratedID(t *testing.T) {
+	messageDAO := dao.NewMessageDAO(daotest.NewDB(t, &dao.Message{}))
+	idGen, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewMessagePersistHandler(messageDAO, idGen, discardLogger)
+	ctx := context.Background()
+
+	if err := h.Handle(ctx, &MessageEvent{SessionID: 7, Role: "user", Content: "legacy"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.HandleBatch(ctx, []*MessageEvent{{SessionID: 7, Role: "assistant", Content: "legacy reply"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := messageDAO.FindBySessionID(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("persisted %d messages, want 2", len(messages))
+	}
+	// 补分配的 ID 来自生成器而不是数据库自增
+	for _, msg := range messages {
+		if msg.Id <= int64(len(messages)) {
+			t.Fatalf("message id %d looks auto-incremented, want a generated id", msg.Id)
+		}
+	}
+}
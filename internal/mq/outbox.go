@@ -0,0 +1,282 @@
+package mq
+
+import (
+	"coca-ai/internal/metrics"
+	"coca-ai/internal/repository/dao"
+	"coca-ai/pkg/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// outboxMaxBackoff 发件箱重试的最大间隔
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxCleanupInterval 清理已发布事件的间隔
+	outboxCleanupInterval = time.Hour
+)
+
+// MessagePublisher 发布消息事件，保证消息最终落库
+// 有 Kafka 时发送到 Kafka，发送失败写入 MySQL 发件箱 (message_outbox) 由 OutboxRelay 重新发布；
+// 无 Kafka 时 (开发环境) 直接写入 MySQL
+type MessagePublisher struct {
+	producer *Producer
+	outbox   *dao.OutboxDAO
+	persist  *MessagePersistHandler
+	l        *slog.Logger
+}
+
+// NewMessagePublisher 创建 MessagePublisher，producer 为 nil 时直接落库
+func NewMessagePublisher(producer *Producer, outbox *dao.OutboxDAO, persist *MessagePersistHandler, l *slog.Logger) *MessagePublisher {
+	p := &MessagePublisher{
+		producer: producer,
+		outbox:   outbox,
+		persist:  persist,
+		l:        l.With("component", "message_publisher"),
+	}
+	if producer != nil {
+		// 异步发送时 Publish 无法得知发送结果，失败的消息由回调写入发件箱
+		producer.onAsyncFailure(p.saveFailed)
+	}
+	return p
+}
+
+// Publish 发布消息事件
+// 只有 Kafka 与发件箱均写入失败 (或直接落库失败) 时返回错误
+func (p *MessagePublisher) Publish(ctx context.Context, event *MessageEvent) error {
+	// 请求结束不应导致消息丢失
+	ctx = context.WithoutCancel(ctx)
+
+	if p.producer == nil {
+		metrics.OutboxEvents.WithLabelValues("direct").Inc()
+		return p.persist.Handle(ctx, event)
+	}
+
+	sendErr := p.producer.SendMessage(ctx, event)
+	if sendErr == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event failed: %w", err)
+	}
+	var msg kafka.Message
+	injectTraceContext(ctx, &msg)
+	if err := p.outbox.Create(ctx, &dao.OutboxEvent{
+		Topic:      TopicChatMessages,
+		MessageKey: fmt.Sprintf("%d", event.SessionID),
+		Payload:    string(data),
+		RequestId:  logger.RequestID(ctx),
+		Headers:    encodeHeaders(msg.Headers),
+	}); err != nil {
+		return fmt.Errorf("send message failed: %w, write outbox failed: %w", sendErr, err)
+	}
+	metrics.OutboxEvents.WithLabelValues("enqueued").Inc()
+	p.l.WarnContext(ctx, "send message to kafka failed, saved to outbox", logger.Error(sendErr),
+		"session_id", event.SessionID, "message_id", event.ID)
+	return nil
+}
+
+// saveFailed 将异步发送失败的消息事件写入发件箱，消息头随事件保存，重新发布时接续原链路
+func (p *MessagePublisher) saveFailed(messages []kafka.Message, sendErr error) {
+	ctx := context.Background()
+	for _, msg := range messages {
+		if msg.Topic != TopicChatMessages {
+			continue
+		}
+		if err := p.outbox.Create(ctx, &dao.OutboxEvent{
+			Topic:      msg.Topic,
+			MessageKey: string(msg.Key),
+			Payload:    string(msg.Value),
+			RequestId:  headerCarrier{headers: &msg.Headers}.Get(headerRequestID),
+			Headers:    encodeHeaders(msg.Headers),
+		}); err != nil {
+			metrics.OutboxEvents.WithLabelValues("lost").Inc()
+			p.l.Error("write failed message to outbox failed", logger.Error(err),
+				"send_error", sendErr.Error(), "key", string(msg.Key))
+			continue
+		}
+		metrics.OutboxEvents.WithLabelValues("enqueued").Inc()
+	}
+}
+
+// OutboxConfig 发件箱转发配置
+type OutboxConfig struct {
+	IntervalMS     int // 扫描间隔，默认 1000
+	BatchSize      int // 单次最多发布条数，默认 100
+	MaxAttempts    int // 最大发布次数，默认 20
+	RetentionHours int // 已发布事件的保留时间，默认 72
+}
+
+// OutboxRelay 将发件箱中的事件重新发布到 Kafka
+// 发布失败按指数退避重试，超过最大次数标记为 dead；
+// 多实例同时转发可能重复发布，消费端按消息 ID 幂等落库
+type OutboxRelay struct {
+	producer    *Producer
+	dao         *dao.OutboxDAO
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	retention   time.Duration
+	l           *slog.Logger
+}
+
+// NewOutboxRelay 创建 OutboxRelay
+func NewOutboxRelay(producer *Producer, outboxDAO *dao.OutboxDAO, cfg *OutboxConfig, l *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		producer:    producer,
+		dao:         outboxDAO,
+		interval:    time.Duration(orDefaultInt(cfg.IntervalMS, 1000)) * time.Millisecond,
+		batchSize:   orDefaultInt(cfg.BatchSize, 100),
+		maxAttempts: orDefaultInt(cfg.MaxAttempts, 20),
+		retention:   time.Duration(orDefaultInt(cfg.RetentionHours, 72)) * time.Hour,
+		l:           l.With("component", "outbox_relay"),
+	}
+}
+
+// Start 启动转发 (阻塞式)
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	r.l.Info("starting outbox relay", "interval", r.interval)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.l.Info("context cancelled, stopping outbox relay")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// 整批发布成功时说明可能还有积压，继续发布下一批
+		for {
+			sent, err := r.relayOnce(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				r.l.Error("relay outbox failed", logger.Error(err))
+				break
+			}
+			if sent < r.batchSize {
+				break
+			}
+		}
+
+		if pending, err := r.dao.CountPending(ctx); err == nil {
+			metrics.OutboxPending.Set(float64(pending))
+		}
+
+		if time.Since(lastCleanup) >= outboxCleanupInterval {
+			lastCleanup = time.Now()
+			deleted, err := r.dao.DeleteSentBefore(ctx, time.Now().Add(-r.retention).UnixMilli())
+			if err != nil {
+				r.l.Error("cleanup outbox failed", logger.Error(err))
+			} else if deleted > 0 {
+				r.l.Info("cleaned up sent outbox events", "count", deleted)
+			}
+		}
+	}
+}
+
+// StartAsync 异步启动转发 (非阻塞)
+func (r *OutboxRelay) StartAsync(ctx context.Context) {
+	go func() {
+		if err := r.Start(ctx); err != nil && ctx.Err() == nil {
+			r.l.Error("outbox relay stopped with error", logger.Error(err))
+		}
+	}()
+}
+
+// relayOnce 发布一批到期事件，返回成功发布的条数
+// 遇到发布失败即停止本批，避免 Kafka 不可用时逐条等待超时
+func (r *OutboxRelay) relayOnce(ctx context.Context) (int, error) {
+	events, err := r.dao.FindDue(ctx, time.Now().UnixMilli(), r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("find due outbox events failed: %w", err)
+	}
+
+	sent := make([]int64, 0, len(events))
+	for _, event := range events {
+		eventCtx := ctx
+		if event.RequestId != "" {
+			eventCtx = logger.WithRequestID(ctx, event.RequestId)
+		}
+		eventCtx = extractTraceContext(eventCtx, decodeHeaders(event.Headers))
+		if err := r.producer.send(eventCtx, event.Topic, event.MessageKey, []byte(event.Payload)); err != nil {
+			r.markFailed(eventCtx, event, err)
+			break
+		}
+		sent = append(sent, event.Id)
+	}
+
+	if err := r.dao.MarkSent(ctx, sent); err != nil {
+		// 未标记的事件会被再次发布，消费端幂等
+		return 0, fmt.Errorf("mark outbox events sent failed: %w", err)
+	}
+	metrics.OutboxEvents.WithLabelValues("sent").Add(float64(len(sent)))
+	return len(sent), nil
+}
+
+// encodeHeaders 将消息头编码为 JSON，无消息头时返回空串
+func encodeHeaders(headers []kafka.Header) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	values := make(map[string]string, len(headers))
+	for _, h := range headers {
+		values[h.Key] = string(h.Value)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeHeaders 解码 encodeHeaders 保存的消息头，内容无效时忽略
+func decodeHeaders(data string) []kafka.Header {
+	if data == "" {
+		return nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return nil
+	}
+	headers := make([]kafka.Header, 0, len(values))
+	for key, value := range values {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return headers
+}
+
+// markFailed 记录发布失败，计算下次重试时间
+func (r *OutboxRelay) markFailed(ctx context.Context, event dao.OutboxEvent, cause error) {
+	attempts := event.Attempts + 1
+	dead := attempts >= r.maxAttempts
+
+	backoff := r.interval << min(attempts, 16)
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	if err := r.dao.MarkFailed(ctx, event.Id, attempts, time.Now().Add(backoff).UnixMilli(), cause.Error(), dead); err != nil {
+		r.l.ErrorContext(ctx, "mark outbox event failed", logger.Error(err), "outbox_id", event.Id)
+		return
+	}
+
+	if dead {
+		metrics.OutboxEvents.WithLabelValues("dead").Inc()
+		r.l.ErrorContext(ctx, "outbox event exceeded max attempts", logger.Error(cause),
+			"outbox_id", event.Id, "topic", event.Topic, "attempts", attempts)
+		return
+	}
+	metrics.OutboxEvents.WithLabelValues("retry").Inc()
+	r.l.WarnContext(ctx, "relay outbox event failed", logger.Error(cause),
+		"outbox_id", event.Id, "topic", event.Topic, "attempts", attempts, "retry_in", backoff)
+}
@@ -0,0 +1,172 @@
+package mq
+
+import (
+	"coca-ai/internal/repository/dao"
+	"coca-ai/internal/repository/dao/daotest"
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestProducer 创建写入 fakeTransport 的同步 Producer，发送失败时不重试
+func newTestProducer(transport *fakeTransport) *Producer {
+	p := NewProducer(&ProducerConfig{Brokers: []string{"localhost:9092"}}, discardLogger)
+	p.writer.Transport = transport
+	p.writer.MaxAttempts = 1
+	return p
+}
+
+func newTestOutbox(t *testing.T) *dao.OutboxDAO {
+	return dao.NewOutboxDAO(daotest.NewDB(t, &dao.OutboxEvent{}))
+}
+
+func TestMessagePublisher_PublishSavesToOutboxOnFailure(t *testing.T) {
+	transport := &fakeTransport{err: errors.New("broker down")}
+	outbox := newTestOutbox(t)
+	publisher := NewMessagePublisher(newTestProducer(transport), outbox, nil, discardLogger)
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	if err := publisher.Publish(ctx, &MessageEvent{ID: 1001, SessionID: 7, Role: "user", Content: "hello"}); err != nil {
+		t.Fatalf("Publish() error = %v, want saved to outbox", err)
+	}
+
+	events, err := outbox.FindDue(context.Background(), time.Now().UnixMilli(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("outbox holds %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Topic != TopicChatMessages || event.MessageKey != "7" || event.RequestId != "req-1" {
+		t.Fatalf("outbox event = %+v", event)
+	}
+}
+
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	transport := &fakeTransport{}
+	outbox := newTestOutbox(t)
+	relay := NewOutboxRelay(newTestProducer(transport), outbox, &OutboxConfig{}, discardLogger)
+	ctx := context.Background()
+
+	for _, key := range []string{"1", "2"} {
+		if err := outbox.Create(ctx, &dao.OutboxEvent{Topic: TopicChatMessages, MessageKey: key, Payload: `{"id":1}`, RequestId: "req-" + key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent, err := relay.relayOnce(ctx)
+	if err != nil || sent != 2 {
+		t.Fatalf("relayOnce() = %d, %v, want 2 sent", sent, err)
+	}
+	written := transport.written()
+	if len(written) != 2 {
+		t.Fatalf("kafka received %d messages, want 2", len(written))
+	}
+	if got := headerValue(written[0], headerRequestID); got != "req-1" {
+		t.Fatalf("request id header = %q, want %q", got, "req-1")
+	}
+	if pending, _ := outbox.CountPending(ctx); pending != 0 {
+		t.Fatalf("pending = %d after relay, want 0", pending)
+	}
+
+	// 已发布的事件不会再次发布
+	if sent, err := relay.relayOnce(ctx); err != nil || sent != 0 {
+		t.Fatalf("second relayOnce() = %d, %v, want nothing sent", sent, err)
+	}
+}
+
+func TestMessagePublisher_SaveFailedKeepsHeaders(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator()) })
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	transport := &fakeTransport{}
+	producer := newTestProducer(transport)
+	outbox := newTestOutbox(t)
+	publisher := NewMessagePublisher(producer, outbox, nil, discardLogger)
+	ctx := context.Background()
+
+	publisher.saveFailed([]kafka.Message{{
+		Topic: TopicChatMessages,
+		Key:   []byte("7"),
+		Value: []byte(`{"id":1001}`),
+		Headers: []kafka.Header{
+			{Key: "traceparent", Value: []byte("00-" + traceID + "-00f067aa0ba902b7-01")},
+			{Key: headerRequestID, Value: []byte("req-9")},
+		},
+	}}, errors.New("broker down"))
+
+	events, err := outbox.FindDue(ctx, time.Now().UnixMilli(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].RequestId != "req-9" || events[0].Headers == "" {
+		t.Fatalf("outbox events = %+v, want request id and headers saved", events)
+	}
+
+	// 重新发布时恢复请求 ID 与链路上下文
+	relay := NewOutboxRelay(producer, outbox, &OutboxConfig{}, discardLogger)
+	if sent, err := relay.relayOnce(ctx); err != nil || sent != 1 {
+		t.Fatalf("relayOnce() = %d, %v, want 1 sent", sent, err)
+	}
+	written := transport.written()
+	if len(written) != 1 {
+		t.Fatalf("kafka received %d messages, want 1", len(written))
+	}
+	if got := headerValue(written[0], headerRequestID); got != "req-9" {
+		t.Fatalf("request id header = %q, want %q", got, "req-9")
+	}
+	if got := headerValue(written[0], "traceparent"); !strings.Contains(got, traceID) {
+		t.Fatalf("traceparent header = %q, want trace %s", got, traceID)
+	}
+}
+
+func TestOutboxRelay_RetryAndDead(t *testing.T) {
+	transport := &fakeTransport{err: errors.New("broker down")}
+	outbox := newTestOutbox(t)
+	relay := NewOutboxRelay(newTestProducer(transport), outbox, &OutboxConfig{MaxAttempts: 2}, discardLogger)
+	ctx := context.Background()
+
+	for _, key := range []string{"1", "2"} {
+		if err := outbox.Create(ctx, &dao.OutboxEvent{Topic: TopicChatMessages, MessageKey: key, Payload: "{}"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 首条发布失败即停止本批，并推迟重试
+	if sent, err := relay.relayOnce(ctx); err != nil || sent != 0 {
+		t.Fatalf("relayOnce() = %d, %v, want nothing sent", sent, err)
+	}
+	events, err := outbox.FindDue(ctx, time.Now().Add(time.Hour).UnixMilli(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Attempts != 1 || events[1].Attempts != 0 {
+		t.Fatalf("events = %+v, want only the first attempted", events)
+	}
+	if events[0].NextAttemptAt <= time.Now().UnixMilli() || events[0].LastError == "" {
+		t.Fatalf("failed event = %+v, want retry scheduled with last error", events[0])
+	}
+
+	// 达到最大次数后标记为 dead，不再重试
+	relay.markFailed(ctx, events[0], errors.New("broker down"))
+	events, err = outbox.FindDue(ctx, time.Now().Add(time.Hour).UnixMilli(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].MessageKey != "2" {
+		t.Fatalf("pending events = %+v, want only the untried event", events)
+	}
+}
@@ -1,9 +1,13 @@
 package mq
 
 import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -14,20 +18,51 @@ const (
 	TopicChatMessages = "chat.messages"
 	// TopicChatMessagesDLQ 聊天消息死信 Topic
 	TopicChatMessagesDLQ = "chat.messages.dlq"
+	// TopicChatSummaries 会话摘要生成任务 Topic
+	TopicChatSummaries = "chat.summaries"
 )
 
 // MessageEvent Kafka 消息事件结构
 type MessageEvent struct {
-	ID        int64  `json:"id"`
-	SessionID int64  `json:"session_id"`
-	Role      string `json:"role"`
-	Content   string `json:"content"`
+	ID           int64           `json:"id"`
+	SessionID    int64           `json:"session_id"`
+	ParentID     int64           `json:"parent_id,omitempty"`
+	Role         string          `json:"role"`
+	Content      string          `json:"content"`
+	ToolCalls    []ToolCallEvent `json:"tool_calls,omitempty"`
+	ToolCallID   string          `json:"tool_call_id,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	// 生成该消息消耗的 token 数
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	// 实际生成该消息的 Provider 与模型
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
 	CreatedAt int64  `json:"created_at"` // Unix 毫秒
 }
 
+// SummaryEvent 摘要生成任务
+// 要求将会话摘要前移至覆盖 UntilMessageID (含) 之前的所有消息
+type SummaryEvent struct {
+	SessionID      int64  `json:"session_id"`
+	UntilMessageID int64  `json:"until_message_id"`
+	Model          string `json:"model,omitempty"` // 用于估算 token 的模型
+}
+
+// ToolCallEvent 消息事件中的工具调用
+type ToolCallEvent struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
 // Producer Kafka 生产者
 type Producer struct {
 	writer *kafka.Writer
+	// syncWriter 同步写入，用于需要确认发送结果的场景 (发件箱转发)；未开启异步时与 writer 相同
+	syncWriter *kafka.Writer
+	// asyncFailed 异步发送失败时的回调，由 MessagePublisher 注册，将失败的消息写入发件箱
+	asyncFailed func(messages []kafka.Message, err error)
 }
 
 // ProducerConfig 生产者配置
@@ -43,36 +78,69 @@ type ProducerConfig struct {
 }
 
 // NewProducer 创建 Kafka 生产者
-func NewProducer(cfg *ProducerConfig) *Producer {
-	writer := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.Brokers...),
-		Topic:        TopicChatMessages,
-		Balancer:     &kafka.Hash{}, // 分区器，Hash 策略
-		BatchSize:    orDefaultInt(cfg.BatchSize, 100), // 批量大小
-		BatchTimeout: time.Duration(orDefaultInt(cfg.BatchTimeout, 10)) * time.Millisecond, // 批量超时时间
-		RequiredAcks: parseRequiredAcks(cfg.RequiredAcks), 
-		Async:        cfg.Async, // 是否异步
-		Compression:  parseCompression(cfg.Compression), // 压缩方式
-		WriteTimeout: time.Duration(orDefaultInt(cfg.WriteTimeout, 10000)) * time.Millisecond, // 写入超时时间
-		MaxAttempts:  orDefaultInt(cfg.MaxAttempts, 10), // 最大重试次数
-	}
-
-	return &Producer{writer: writer}
+// Writer 不绑定 Topic，由每条消息指定
+func NewProducer(cfg *ProducerConfig, l *slog.Logger) *Producer {
+	p := &Producer{writer: newWriter(cfg, cfg.Async)}
+	p.syncWriter = p.writer
+	if cfg.Async {
+		p.syncWriter = newWriter(cfg, false)
+		// 异步模式下 WriteMessages 不返回发送错误，在回调中统计并交给发件箱
+		p.writer.Completion = func(messages []kafka.Message, err error) {
+			if err == nil {
+				return
+			}
+			for _, msg := range messages {
+				metrics.KafkaProducerErrors.WithLabelValues(msg.Topic).Inc()
+			}
+			l.Error("async write messages failed", logger.Error(err), "component", "kafka_producer", "count", len(messages))
+			if p.asyncFailed != nil {
+				p.asyncFailed(messages, err)
+			}
+		}
+	}
+	return p
+}
+
+// onAsyncFailure 注册异步发送失败的回调，需在发送消息前调用
+func (p *Producer) onAsyncFailure(fn func(messages []kafka.Message, err error)) {
+	p.asyncFailed = fn
+}
+
+// newWriter 按配置创建 Writer，async 指定是否异步写入
+func newWriter(cfg *ProducerConfig, async bool) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Balancer:               &kafka.Hash{},                                                        // 分区器，Hash 策略
+		BatchSize:              orDefaultInt(cfg.BatchSize, 100),                                     // 批量大小
+		BatchTimeout:           time.Duration(orDefaultInt(cfg.BatchTimeout, 10)) * time.Millisecond, // 批量超时时间
+		RequiredAcks:           parseRequiredAcks(cfg.RequiredAcks),
+		Async:                  async,                                                                   // 是否异步
+		Compression:            parseCompression(cfg.Compression),                                       // 压缩方式
+		WriteTimeout:           time.Duration(orDefaultInt(cfg.WriteTimeout, 10000)) * time.Millisecond, // 写入超时时间
+		MaxAttempts:            orDefaultInt(cfg.MaxAttempts, 10),                                       // 最大重试次数
+		AllowAutoTopicCreation: true,
+	}
 }
 
-// SendMessage 发送消息事件到 Kafka
-func (p *Producer) SendMessage(ctx context.Context, event *MessageEvent) error {
+// SendMessage 发送消息事件到 Kafka，链路上下文随消息头传递
+func (p *Producer) SendMessage(ctx context.Context, event *MessageEvent) (err error) {
+	ctx, span := startProducerSpan(ctx, TopicChatMessages)
+	defer func() { endSpan(span, err) }()
+
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("marshal event failed: %w", err)
 	}
 
 	msg := kafka.Message{
+		Topic: TopicChatMessages,
 		Key:   []byte(fmt.Sprintf("%d", event.SessionID)), // 按 SessionID 分区
 		Value: data,
 	}
+	injectTraceContext(ctx, &msg)
 
 	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		metrics.KafkaProducerErrors.WithLabelValues(TopicChatMessages).Inc()
 		return fmt.Errorf("write message failed: %w", err)
 	}
 
@@ -80,7 +148,10 @@ func (p *Producer) SendMessage(ctx context.Context, event *MessageEvent) error {
 }
 
 // SendMessages 批量发送消息事件
-func (p *Producer) SendMessages(ctx context.Context, events []*MessageEvent) error {
+func (p *Producer) SendMessages(ctx context.Context, events []*MessageEvent) (err error) {
+	ctx, span := startProducerSpan(ctx, TopicChatMessages)
+	defer func() { endSpan(span, err) }()
+
 	messages := make([]kafka.Message, len(events))
 	for i, event := range events {
 		data, err := json.Marshal(event)
@@ -88,26 +159,78 @@ func (p *Producer) SendMessages(ctx context.Context, events []*MessageEvent) err
 			return fmt.Errorf("marshal event failed: %w", err)
 		}
 		messages[i] = kafka.Message{
+			Topic: TopicChatMessages,
 			Key:   []byte(fmt.Sprintf("%d", event.SessionID)),
 			Value: data,
 		}
+		injectTraceContext(ctx, &messages[i])
 	}
 
 	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		metrics.KafkaProducerErrors.WithLabelValues(TopicChatMessages).Add(float64(len(messages)))
 		return fmt.Errorf("write messages failed: %w", err)
 	}
 
 	return nil
 }
 
-// Close 关闭生产者
-func (p *Producer) Close() error {
-	if p.writer != nil {
-		return p.writer.Close()
+// SendSummaryTask 发送摘要生成任务
+func (p *Producer) SendSummaryTask(ctx context.Context, event *SummaryEvent) (err error) {
+	ctx, span := startProducerSpan(ctx, TopicChatSummaries)
+	defer func() { endSpan(span, err) }()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event failed: %w", err)
 	}
+
+	msg := kafka.Message{
+		Topic: TopicChatSummaries,
+		Key:   []byte(fmt.Sprintf("%d", event.SessionID)), // 同一会话的任务串行处理
+		Value: data,
+	}
+	injectTraceContext(ctx, &msg)
+
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		metrics.KafkaProducerErrors.WithLabelValues(TopicChatSummaries).Inc()
+		return fmt.Errorf("write summary task failed: %w", err)
+	}
+
+	return nil
+}
+
+// send 同步发送已编码的消息，用于重新发布发件箱中的事件
+func (p *Producer) send(ctx context.Context, topic string, key string, value []byte) (err error) {
+	ctx, span := startProducerSpan(ctx, topic)
+	defer func() { endSpan(span, err) }()
+
+	msg := kafka.Message{
+		Topic: topic,
+		Key:   []byte(key),
+		Value: value,
+	}
+	injectTraceContext(ctx, &msg)
+
+	if err := p.syncWriter.WriteMessages(ctx, msg); err != nil {
+		metrics.KafkaProducerErrors.WithLabelValues(topic).Inc()
+		return fmt.Errorf("write message failed: %w", err)
+	}
+
 	return nil
 }
 
+// Close 关闭生产者，异步模式下等待缓冲中的消息发送完成
+func (p *Producer) Close() error {
+	if p.writer == nil {
+		return nil
+	}
+	err := p.writer.Close()
+	if p.syncWriter != p.writer {
+		err = errors.Join(err, p.syncWriter.Close())
+	}
+	return err
+}
+
 func parseRequiredAcks(value string) kafka.RequiredAcks {
 	switch value {
 	case "all", "ALL", "require_all":
@@ -0,0 +1,122 @@
+package mq
+
+import (
+	"coca-ai/internal/metrics"
+	"coca-ai/pkg/logger"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// SummaryHandler 摘要任务处理函数类型
+type SummaryHandler func(ctx context.Context, event *SummaryEvent) error
+
+// SummaryConsumer 摘要任务消费者
+// 摘要可随时重新生成，处理失败只记录日志并提交 offset，不进入死信队列
+type SummaryConsumer struct {
+	reader        *kafka.Reader
+	groupID       string
+	handler       SummaryHandler
+	commitTimeout time.Duration
+	l             *slog.Logger
+}
+
+// NewSummaryConsumer 创建摘要任务消费者，消费者组为 {GroupID}-summary
+func NewSummaryConsumer(cfg *ConsumerConfig, l *slog.Logger) *SummaryConsumer {
+	groupID := cfg.GroupID
+	if groupID == "" {
+		groupID = "coca-chat-consumer"
+	}
+
+	startOffset := kafka.LastOffset
+	switch strings.ToLower(cfg.StartOffset) {
+	case "earliest", "first":
+		startOffset = kafka.FirstOffset
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        cfg.Brokers,
+		Topic:          TopicChatSummaries,
+		GroupID:        groupID + "-summary",
+		MinBytes:       1,
+		MaxBytes:       orDefaultInt(cfg.MaxBytes, 10e6),
+		MaxWait:        time.Duration(orDefaultInt(cfg.MaxWait, 500)) * time.Millisecond,
+		CommitInterval: 0, // 手动提交 offset
+		StartOffset:    startOffset,
+	})
+
+	l = l.With("component", "kafka_summary_consumer", "topic", TopicChatSummaries)
+	if err := metrics.RegisterConsumerLag(TopicChatSummaries, groupID+"-summary", func() int64 { return reader.Stats().Lag }); err != nil {
+		l.Warn("register consumer lag metric failed", logger.Error(err))
+	}
+
+	return &SummaryConsumer{
+		reader:        reader,
+		groupID:       groupID + "-summary",
+		commitTimeout: time.Duration(orDefaultInt(cfg.CommitTimeout, 3000)) * time.Millisecond,
+		l:             l,
+	}
+}
+
+// RegisterHandler 注册摘要任务处理函数
+func (c *SummaryConsumer) RegisterHandler(handler SummaryHandler) {
+	c.handler = handler
+}
+
+// Start 启动消费者 (阻塞式)
+func (c *SummaryConsumer) Start(ctx context.Context) error {
+	c.l.Info("starting consumer", "group", c.groupID)
+	// ctx 仅用于停止拉取，已拉取的任务处理完并提交 offset 后才退出
+	procCtx := context.WithoutCancel(ctx)
+
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.l.Error("read message failed", logger.Error(err))
+			continue
+		}
+
+		msgCtx, span := startConsumerSpan(procCtx, msg, c.groupID)
+		var event SummaryEvent
+		err = json.Unmarshal(msg.Value, &event)
+		if err != nil {
+			c.l.ErrorContext(msgCtx, "unmarshal message failed", logger.Error(err),
+				"partition", msg.Partition, "offset", msg.Offset, "bytes", len(msg.Value))
+		} else if c.handler != nil {
+			if err = c.handler(msgCtx, &event); err != nil {
+				c.l.ErrorContext(msgCtx, "handle summary task failed", logger.Error(err), "session_id", event.SessionID)
+			}
+		}
+		endSpan(span, err)
+
+		commitCtx, cancel := context.WithTimeout(procCtx, c.commitTimeout)
+		if err := c.reader.CommitMessages(commitCtx, msg); err != nil {
+			c.l.ErrorContext(msgCtx, "commit offset failed", logger.Error(err), "partition", msg.Partition, "offset", msg.Offset)
+		}
+		cancel()
+	}
+}
+
+// StartAsync 异步启动消费者 (非阻塞)
+func (c *SummaryConsumer) StartAsync(ctx context.Context) {
+	go func() {
+		if err := c.Start(ctx); err != nil && ctx.Err() == nil {
+			c.l.Error("consumer stopped with error", logger.Error(err))
+		}
+	}()
+}
+
+// Close 关闭消费者
+func (c *SummaryConsumer) Close() error {
+	if c.reader != nil {
+		return c.reader.Close()
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package mq
+
+import (
+	"coca-ai/pkg/logger"
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "coca-ai/internal/mq"
+
+// headerRequestID 携带请求 ID 的消息头
+const headerRequestID = "request_id"
+
+// headerCarrier 基于 Kafka 消息头的 TextMapCarrier
+// 生产者将链路上下文 (traceparent 等) 写入消息头，消费者从中恢复，使落库等异步处理接续同一条链路
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+// Get 返回指定 key 的消息头
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set 设置消息头，已存在时覆盖
+func (c headerCarrier) Set(key string, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys 返回所有消息头的 key
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// startProducerSpan 开始发送消息的 Span
+func startProducerSpan(ctx context.Context, topic string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(topic),
+		),
+	)
+}
+
+// injectTraceContext 将 ctx 中的链路上下文与请求 ID 写入消息头
+func injectTraceContext(ctx context.Context, msg *kafka.Message) {
+	carrier := headerCarrier{headers: &msg.Headers}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if id := logger.RequestID(ctx); id != "" {
+		carrier.Set(headerRequestID, id)
+	}
+}
+
+// extractTraceContext 从消息头恢复链路上下文与请求 ID
+func extractTraceContext(ctx context.Context, headers []kafka.Header) context.Context {
+	carrier := headerCarrier{headers: &headers}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	if id := carrier.Get(headerRequestID); id != "" {
+		ctx = logger.WithRequestID(ctx, id)
+	}
+	return ctx
+}
+
+// startConsumerSpan 从消息头恢复链路上下文与请求 ID，开始处理消息的 Span
+func startConsumerSpan(ctx context.Context, msg kafka.Message, groupID string) (context.Context, trace.Span) {
+	ctx = extractTraceContext(ctx, msg.Headers)
+	return otel.Tracer(tracerName).Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaConsumerGroup(groupID),
+			semconv.MessagingKafkaSourcePartition(msg.Partition),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+		),
+	)
+}
+
+// endSpan 结束 Span，出错时记录错误
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,233 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/listoffsets"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/offsetcommit"
+	"github.com/segmentio/kafka-go/protocol/offsetfetch"
+	"github.com/segmentio/kafka-go/protocol/produce"
+)
+
+// fakeTransport 内存中的 Kafka 传输层，记录写入的消息与消费者组提交的 offset
+// Topic 默认只有一个分区，可通过 partitions 指定
+type fakeTransport struct {
+	mu         sync.Mutex
+	err        error // 非空时所有请求返回该错误
+	partitions map[string]int
+	messages   []kafka.Message
+	committed  map[string]int64 // group/topic/partition -> offset
+}
+
+// written 返回已写入的消息
+func (f *fakeTransport) written() []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafka.Message(nil), f.messages...)
+}
+
+// writtenTo 返回写入 topic 的消息
+func (f *fakeTransport) writtenTo(topic string) []kafka.Message {
+	var msgs []kafka.Message
+	for _, msg := range f.written() {
+		if msg.Topic == topic {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// add 直接写入一条消息，按分区分配 offset
+func (f *fakeTransport) add(msg kafka.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.append(msg)
+}
+
+func (f *fakeTransport) append(msg kafka.Message) {
+	msg.Offset = f.endOffset(msg.Topic, msg.Partition)
+	f.messages = append(f.messages, msg)
+}
+
+func (f *fakeTransport) endOffset(topic string, partition int) int64 {
+	var n int64
+	for _, msg := range f.messages {
+		if msg.Topic == topic && msg.Partition == partition {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeTransport) partitionsOf(topic string) int {
+	if n := f.partitions[topic]; n > 0 {
+		return n
+	}
+	return 1
+}
+
+func committedKey(group, topic string, partition int) string {
+	return fmt.Sprintf("%s/%s/%d", group, topic, partition)
+}
+
+// reader 返回读取 topic 单个分区的 partitionReader
+func (f *fakeTransport) reader(topic string) func(partition int) partitionReader {
+	return func(partition int) partitionReader {
+		var msgs []kafka.Message
+		for _, msg := range f.written() {
+			if msg.Topic == topic && msg.Partition == partition {
+				msgs = append(msgs, msg)
+			}
+		}
+		return &fakePartitionReader{msgs: msgs}
+	}
+}
+
+func (f *fakeTransport) RoundTrip(_ context.Context, _ net.Addr, req protocol.Message) (protocol.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	switch req := req.(type) {
+	case *metadata.Request:
+		resp := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}}}
+		for _, topic := range req.TopicNames {
+			respTopic := metadata.ResponseTopic{Name: topic}
+			for p := 0; p < f.partitionsOf(topic); p++ {
+				respTopic.Partitions = append(respTopic.Partitions, metadata.ResponsePartition{PartitionIndex: int32(p), LeaderID: 1})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	case *produce.Request:
+		resp := &produce.Response{}
+		for _, topic := range req.Topics {
+			respTopic := produce.ResponseTopic{Topic: topic.Topic}
+			for _, p := range topic.Partitions {
+				if err := f.appendRecords(topic.Topic, int(p.Partition), p.RecordSet.Records); err != nil {
+					return nil, err
+				}
+				respTopic.Partitions = append(respTopic.Partitions, produce.ResponsePartition{Partition: p.Partition})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	case *listoffsets.Request:
+		resp := &listoffsets.Response{}
+		for _, topic := range req.Topics {
+			respTopic := listoffsets.ResponseTopic{Topic: topic.Topic}
+			for _, p := range topic.Partitions {
+				offset := int64(0)
+				if p.Timestamp == kafka.LastOffset {
+					offset = f.endOffset(topic.Topic, int(p.Partition))
+				}
+				respTopic.Partitions = append(respTopic.Partitions, listoffsets.ResponsePartition{
+					Partition: p.Partition, Timestamp: p.Timestamp, Offset: offset,
+				})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	case *offsetfetch.Request:
+		resp := &offsetfetch.Response{}
+		for _, topic := range req.Topics {
+			respTopic := offsetfetch.ResponseTopic{Name: topic.Name}
+			for _, p := range topic.PartitionIndexes {
+				offset, ok := f.committed[committedKey(req.GroupID, topic.Name, int(p))]
+				if !ok {
+					offset = -1
+				}
+				respTopic.Partitions = append(respTopic.Partitions, offsetfetch.ResponsePartition{PartitionIndex: p, CommittedOffset: offset})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	case *offsetcommit.Request:
+		if f.committed == nil {
+			f.committed = make(map[string]int64)
+		}
+		resp := &offsetcommit.Response{}
+		for _, topic := range req.Topics {
+			respTopic := offsetcommit.ResponseTopic{Name: topic.Name}
+			for _, p := range topic.Partitions {
+				f.committed[committedKey(req.GroupID, topic.Name, int(p.PartitionIndex))] = p.CommittedOffset
+				respTopic.Partitions = append(respTopic.Partitions, offsetcommit.ResponsePartition{PartitionIndex: p.PartitionIndex})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("fake transport: unexpected request %T", req)
+}
+
+func (f *fakeTransport) appendRecords(topic string, partition int, records protocol.RecordReader) error {
+	for {
+		record, err := records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := protocol.ReadAll(record.Key)
+		if err != nil {
+			return err
+		}
+		value, err := protocol.ReadAll(record.Value)
+		if err != nil {
+			return err
+		}
+		f.append(kafka.Message{
+			Topic:     topic,
+			Partition: partition,
+			Key:       key,
+			Value:     value,
+			Headers:   append([]kafka.Header(nil), record.Headers...),
+		})
+	}
+}
+
+// fakePartitionReader 读取单个分区的消息快照，读完后阻塞到 ctx 结束
+type fakePartitionReader struct {
+	msgs []kafka.Message
+	pos  int
+}
+
+func (r *fakePartitionReader) SetOffset(offset int64) error {
+	r.pos = 0
+	for r.pos < len(r.msgs) && r.msgs[r.pos].Offset < offset {
+		r.pos++
+	}
+	return nil
+}
+
+func (r *fakePartitionReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	if r.pos >= len(r.msgs) {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	r.pos++
+	return r.msgs[r.pos-1], nil
+}
+
+func (r *fakePartitionReader) Close() error { return nil }
+
+// headerValue 返回消息头的值
+func headerValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package rag
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunker 文本切片器
+// 按段落 (空行、Markdown 标题) 切分，再将相邻段落合并到不超过 size 个字符；
+// 超长段落按句子或硬切分，相邻切片之间保留 overlap 个字符的重叠
+type Chunker struct {
+	size    int
+	overlap int
+}
+
+// NewChunker 创建 Chunker
+func NewChunker(size, overlap int) *Chunker {
+	if size <= 0 {
+		size = 500
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = size / 10
+	}
+	return &Chunker{size: size, overlap: overlap}
+}
+
+// Split 将文本切分为切片
+func (c *Chunker) Split(text string) []string {
+	var chunks []string
+	var current strings.Builder
+	// carried current 开头从上一个切片带来的重叠部分长度
+	carried := 0
+
+	flush := func() {
+		chunk := strings.TrimSpace(current.String())
+		current.Reset()
+		carried = 0
+		if chunk == "" {
+			return
+		}
+		chunks = append(chunks, chunk)
+		// 新切片以上一个切片的末尾开头，保持上下文连续
+		if c.overlap > 0 {
+			current.WriteString(tailRunes(chunk, c.overlap))
+			current.WriteString("\n")
+			carried = current.Len()
+		}
+	}
+	fits := func(piece string) bool {
+		return utf8.RuneCountInString(current.String())+utf8.RuneCountInString(piece) <= c.size
+	}
+
+	for _, para := range splitParagraphs(text) {
+		for _, piece := range c.splitLong(para) {
+			// 句子切分留下的空白不单独占用切片
+			piece = strings.TrimSpace(piece)
+			if piece == "" {
+				continue
+			}
+			if !fits(piece) {
+				// 只有重叠部分时不单独成片
+				if current.Len() > carried {
+					flush()
+				}
+				// 重叠部分与切片放不下时放弃重叠
+				if !fits(piece) {
+					current.Reset()
+					carried = 0
+				}
+			}
+			current.WriteString(piece)
+			current.WriteString("\n\n")
+		}
+	}
+
+	// 最后一个切片若只包含重叠部分则丢弃
+	if current.Len() > carried {
+		if last := strings.TrimSpace(current.String()); last != "" {
+			chunks = append(chunks, last)
+		}
+	}
+	return chunks
+}
+
+// splitLong 将放不进一个切片的段落按句子切分，单句仍超长时硬切分
+// 切片以重叠部分和一个换行开头，每段的长度上限扣除这部分
+func (c *Chunker) splitLong(para string) []string {
+	limit := c.size
+	if c.overlap > 0 {
+		limit = max(c.size-c.overlap-1, 1)
+	}
+	if utf8.RuneCountInString(para) <= limit {
+		return []string{para}
+	}
+
+	var pieces []string
+	var current []rune
+	for _, sentence := range splitSentences(para) {
+		runes := []rune(sentence)
+		if len(current)+len(runes) > limit && len(current) > 0 {
+			pieces = append(pieces, string(current))
+			current = nil
+		}
+		for len(runes) > limit {
+			pieces = append(pieces, string(runes[:limit]))
+			runes = runes[limit:]
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, string(current))
+	}
+	return pieces
+}
+
+// splitParagraphs 按空行和 Markdown 标题切分段落
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var paras []string
+	var current []string
+	flush := func() {
+		if p := strings.TrimSpace(strings.Join(current, "\n")); p != "" {
+			paras = append(paras, p)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+		}
+		current = append(current, line)
+	}
+	flush()
+	return paras
+}
+
+// splitSentences 按中英文句末标点切分句子 (保留标点)
+func splitSentences(text string) []string {
+	var sentences []string
+	var current strings.Builder
+	for _, r := range text {
+		current.WriteRune(r)
+		switch r {
+		case '。', '！', '？', '；', '.', '!', '?', ';', '\n':
+			sentences = append(sentences, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		sentences = append(sentences, current.String())
+	}
+	return sentences
+}
+
+func tailRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
@@ -0,0 +1,131 @@
+package rag
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunker_Split(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		overlap int
+		text    string
+		want    []string
+	}{
+		{
+			name: "empty text",
+			size: 10,
+			text: " \n\n ",
+		},
+		{
+			name: "short text",
+			size: 100,
+			text: "hello world",
+			want: []string{"hello world"},
+		},
+		{
+			name: "packs adjacent paragraphs",
+			size: 20,
+			text: "aaaa\n\nbbbb\n\ncccccccccccccc",
+			want: []string{"aaaa\n\nbbbb", "cccccccccccccc"},
+		},
+		{
+			name: "heading starts a paragraph",
+			size: 100,
+			text: "intro\n# Title\nbody",
+			want: []string{"intro\n\n# Title\nbody"},
+		},
+		{
+			name: "splits long paragraph by sentence",
+			size: 10,
+			text: "一二三四。五六七八。九十",
+			want: []string{"一二三四。五六七八。", "九十"},
+		},
+		{
+			name: "hard cuts sentence without punctuation",
+			size: 5,
+			text: "abcdefghijkl",
+			want: []string{"abcde", "fghij", "kl"},
+		},
+		{
+			name:    "next chunk starts with overlap",
+			size:    12,
+			overlap: 3,
+			text:    "aaaaaa\n\nbbbbbb",
+			want:    []string{"aaaaaa", "aaa\nbbbbbb"},
+		},
+		{
+			name:    "hard cuts leave room for overlap",
+			size:    10,
+			overlap: 2,
+			text:    strings.Repeat("a", 20),
+			want:    []string{"aaaaaaa", "aa\naaaaaaa", "aa\naaaaaa"},
+		},
+		{
+			name:    "sentences leave room for overlap",
+			size:    14,
+			overlap: 3,
+			text:    "one two. six ten. red.",
+			want:    []string{"one two.", "wo.\nsix ten.", "en.\nred."},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewChunker(tc.size, tc.overlap).Split(tc.text)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Split() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestChunker_SplitBounds 切片不超过 size，且不会产生只含重叠部分的切片
+func TestChunker_SplitBounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []rune("abc  .。\n")
+	for i := 0; i < 2000; i++ {
+		size := 4 + rng.Intn(30)
+		overlap := rng.Intn(size/2 + 1)
+		text := make([]rune, rng.Intn(200))
+		for j := range text {
+			text[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+
+		chunks := NewChunker(size, overlap).Split(string(text))
+		for j, chunk := range chunks {
+			if n := utf8.RuneCountInString(chunk); n > size {
+				t.Fatalf("Split(%q) size %d overlap %d: chunk %q has %d runes", string(text), size, overlap, chunk, n)
+			}
+			if j > 0 && chunk == tailRunes(chunks[j-1], overlap) {
+				t.Fatalf("Split(%q) size %d overlap %d: chunk %q only repeats the overlap", string(text), size, overlap, chunk)
+			}
+		}
+	}
+}
+
+func TestNewChunker_Defaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		overlap     int
+		wantSize    int
+		wantOverlap int
+	}{
+		{name: "default size", size: 0, overlap: 10, wantSize: 500, wantOverlap: 10},
+		{name: "negative overlap", size: 100, overlap: -1, wantSize: 100, wantOverlap: 10},
+		{name: "overlap not smaller than size", size: 100, overlap: 100, wantSize: 100, wantOverlap: 10},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewChunker(tc.size, tc.overlap)
+			if c.size != tc.wantSize || c.overlap != tc.wantOverlap {
+				t.Fatalf("NewChunker(%d, %d) = {size: %d, overlap: %d}, want {size: %d, overlap: %d}",
+					tc.size, tc.overlap, c.size, c.overlap, tc.wantSize, tc.wantOverlap)
+			}
+		})
+	}
+}
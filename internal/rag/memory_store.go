@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"coca-ai/internal/domain"
+	"context"
+	"sync"
+)
+
+// MemoryStore 进程内向量存储
+// 数据不持久化、不跨实例共享，适用于本地开发与测试
+type MemoryStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	chunks map[int64][]domain.DocumentChunk // userID -> chunks
+}
+
+// NewMemoryStore 创建 MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{chunks: make(map[int64][]domain.DocumentChunk)}
+}
+
+// Upsert 写入切片
+func (s *MemoryStore) Upsert(ctx context.Context, chunks []domain.DocumentChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, chunk := range chunks {
+		if chunk.ID == 0 {
+			s.nextID++
+			chunk.ID = s.nextID
+		}
+		s.chunks[chunk.UserID] = append(s.chunks[chunk.UserID], chunk)
+	}
+	return nil
+}
+
+// Search 检索相似切片
+func (s *MemoryStore) Search(ctx context.Context, userID int64, vector []float32, topK int) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return topKResults(s.chunks[userID], vector, topK), nil
+}
+
+// DeleteByDocument 删除文档的所有切片
+func (s *MemoryStore) DeleteByDocument(ctx context.Context, documentID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, chunks := range s.chunks {
+		kept := chunks[:0]
+		for _, chunk := range chunks {
+			if chunk.DocumentID != documentID {
+				kept = append(kept, chunk)
+			}
+		}
+		s.chunks[userID] = kept
+	}
+	return nil
+}
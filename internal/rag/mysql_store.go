@@ -0,0 +1,80 @@
+package rag
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/dao"
+	"context"
+	"encoding/binary"
+	"math"
+)
+
+// MySQLStore 基于 MySQL 的向量存储
+// 向量以二进制存储在 document_chunks 表中，检索时加载用户的全部切片在内存中计算相似度；
+// 无需额外的向量数据库，适合单用户文档量较小 (数千切片) 的场景
+type MySQLStore struct {
+	dao *dao.DocumentChunkDAO
+}
+
+// NewMySQLStore 创建 MySQLStore
+func NewMySQLStore(dao *dao.DocumentChunkDAO) *MySQLStore {
+	return &MySQLStore{dao: dao}
+}
+
+// Upsert 写入切片
+func (s *MySQLStore) Upsert(ctx context.Context, chunks []domain.DocumentChunk) error {
+	entities := make([]dao.DocumentChunk, len(chunks))
+	for i, chunk := range chunks {
+		entities[i] = dao.DocumentChunk{
+			Id:         chunk.ID,
+			DocumentId: chunk.DocumentID,
+			UserId:     chunk.UserID,
+			ChunkIndex: chunk.Index,
+			Content:    chunk.Content,
+			Embedding:  encodeVector(chunk.Embedding),
+		}
+	}
+	return s.dao.BatchCreate(ctx, entities)
+}
+
+// Search 检索相似切片
+func (s *MySQLStore) Search(ctx context.Context, userID int64, vector []float32, topK int) ([]SearchResult, error) {
+	entities, err := s.dao.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]domain.DocumentChunk, len(entities))
+	for i, entity := range entities {
+		chunks[i] = domain.DocumentChunk{
+			ID:         entity.Id,
+			DocumentID: entity.DocumentId,
+			UserID:     entity.UserId,
+			Index:      entity.ChunkIndex,
+			Content:    entity.Content,
+			Embedding:  decodeVector(entity.Embedding),
+		}
+	}
+	return topKResults(chunks, vector, topK), nil
+}
+
+// DeleteByDocument 删除文档的所有切片
+func (s *MySQLStore) DeleteByDocument(ctx context.Context, documentID int64) error {
+	return s.dao.DeleteByDocumentID(ctx, documentID)
+}
+
+// encodeVector 将向量编码为 float32 小端序字节
+func encodeVector(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeVector 解码 float32 小端序字节
+func decodeVector(buf []byte) []float32 {
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vec
+}
@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"coca-ai/internal/domain"
+	"context"
+	"math"
+	"sort"
+)
+
+// SearchResult 向量检索结果
+type SearchResult struct {
+	Chunk domain.DocumentChunk
+	Score float32 // 余弦相似度
+}
+
+// VectorStore 向量存储接口
+// 实现需按用户隔离数据，Search 只返回该用户的切片
+type VectorStore interface {
+	// Upsert 写入切片及其向量
+	Upsert(ctx context.Context, chunks []domain.DocumentChunk) error
+	// Search 检索与 vector 最相似的 topK 个切片 (按相似度倒序)
+	Search(ctx context.Context, userID int64, vector []float32, topK int) ([]SearchResult, error)
+	// DeleteByDocument 删除文档的所有切片
+	DeleteByDocument(ctx context.Context, documentID int64) error
+}
+
+// cosine 计算余弦相似度
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+}
+
+// topKResults 暴力计算相似度并取前 topK
+func topKResults(chunks []domain.DocumentChunk, vector []float32, topK int) []SearchResult {
+	results := make([]SearchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		results = append(results, SearchResult{Chunk: chunk, Score: cosine(vector, chunk.Embedding)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
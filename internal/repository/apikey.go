@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/dao"
+	"context"
+	"strings"
+	"time"
+)
+
+var ErrAPIKeyNotFound = dao.ErrRecordNotFound
+
+// APIKeyRepository API Key 仓储层
+type APIKeyRepository struct {
+	dao *dao.APIKeyDAO
+}
+
+// NewAPIKeyRepository 创建 APIKeyRepository 实例
+func NewAPIKeyRepository(dao *dao.APIKeyDAO) *APIKeyRepository {
+	return &APIKeyRepository{dao: dao}
+}
+
+// Create 保存 API Key，hash 为明文的哈希
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey, hash string) error {
+	entity := &dao.APIKey{
+		UserId:    key.UserID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(key.Scopes, ","),
+		ExpiresAt: toMilli(key.ExpiresAt),
+	}
+	if err := r.dao.Create(ctx, entity); err != nil {
+		return err
+	}
+	key.ID = entity.Id
+	key.CreatedAt = time.UnixMilli(entity.CreatedAt)
+	return nil
+}
+
+// FindByHash 根据明文哈希查找 API Key
+func (r *APIKeyRepository) FindByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	entity, err := r.dao.FindByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(entity), nil
+}
+
+// FindByUserID 查找用户的所有 API Key
+func (r *APIKeyRepository) FindByUserID(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	entities, err := r.dao.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]domain.APIKey, len(entities))
+	for i := range entities {
+		keys[i] = *r.toDomain(&entities[i])
+	}
+	return keys, nil
+}
+
+// CountActiveByUserID 统计用户未吊销的 API Key 数量
+func (r *APIKeyRepository) CountActiveByUserID(ctx context.Context, userID int64) (int64, error) {
+	return r.dao.CountActiveByUserID(ctx, userID)
+}
+
+// UpdateName 重命名 API Key
+func (r *APIKeyRepository) UpdateName(ctx context.Context, id int64, userID int64, name string) error {
+	return r.dao.UpdateName(ctx, id, userID, name)
+}
+
+// Revoke 吊销 API Key
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int64, userID int64) error {
+	return r.dao.Revoke(ctx, id, userID)
+}
+
+// TouchLastUsed 更新最后使用时间
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	return r.dao.UpdateLastUsed(ctx, id, at.UnixMilli())
+}
+
+func (r *APIKeyRepository) toDomain(entity *dao.APIKey) *domain.APIKey {
+	key := &domain.APIKey{
+		ID:         entity.Id,
+		UserID:     entity.UserId,
+		Name:       entity.Name,
+		Prefix:     entity.Prefix,
+		ExpiresAt:  fromMilli(entity.ExpiresAt),
+		LastUsedAt: fromMilli(entity.LastUsedAt),
+		RevokedAt:  fromMilli(entity.RevokedAt),
+		CreatedAt:  time.UnixMilli(entity.CreatedAt),
+	}
+	if entity.Scopes != "" {
+		key.Scopes = strings.Split(entity.Scopes, ",")
+	}
+	return key
+}
+
+// toMilli 零值时间存为 0
+func toMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// fromMilli 0 还原为零值时间
+func fromMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// 生成事件流 Key: chat:generation:{generation_id}:stream
+	generationStreamKeyPrefix = "chat:generation:%d:stream"
+	// 生成元信息 Key: chat:generation:{generation_id}:meta
+	generationMetaKeyPrefix = "chat:generation:%d:meta"
+	// 生成事件保留时间，生成期间定期续期，结束后从最后一次写入起算
+	generationTTL = 10 * time.Minute
+	// 单个生成最多保留的事件数
+	generationMaxEvents = 20000
+	// 发送请求幂等 Key: chat:idempotency:{user_id}:{idempotency_key}
+	idempotencyKeyPrefix = "chat:idempotency:%d:%s"
+	// 幂等 Key 保留时间，与事件流一致：Key 有效期间首次请求的生成总能重放
+	// 生成期间定期与结束时由 StreamService 续期，事件流过期后 Key 随之过期
+	idempotencyTTL = generationTTL
+)
+
+// GenerationMeta 生成的归属信息
+type GenerationMeta struct {
+	UserID    int64
+	SessionID int64
+}
+
+// GenerationEvent 生成事件流中的一条事件
+type GenerationEvent struct {
+	ID    string // Redis Stream 条目 ID，作为 SSE 的 id 字段
+	Event string // 事件类型
+	Data  string // 事件内容 (JSON)
+}
+
+// IdempotencyRecord 幂等 Key 对应的请求
+type IdempotencyRecord struct {
+	Fingerprint  string `json:"fingerprint"`
+	GenerationID int64  `json:"generation_id"`
+}
+
+// GenerationCache 基于 Redis Stream 缓冲生成过程中的流式事件，支持断线后重放
+type GenerationCache struct {
+	client redis.Cmdable
+}
+
+// NewGenerationCache 创建 GenerationCache 实例
+func NewGenerationCache(client redis.Cmdable) *GenerationCache {
+	return &GenerationCache{client: client}
+}
+
+// Create 登记一次生成的归属信息
+func (c *GenerationCache) Create(ctx context.Context, generationID int64, meta GenerationMeta) error {
+	key := fmt.Sprintf(generationMetaKeyPrefix, generationID)
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", meta.UserID, "session_id", meta.SessionID)
+	pipe.Expire(ctx, key, generationTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetMeta 获取生成的归属信息，不存在或已过期时返回 ErrCacheMiss
+func (c *GenerationCache) GetMeta(ctx context.Context, generationID int64) (*GenerationMeta, error) {
+	values, err := c.client.HGetAll(ctx, fmt.Sprintf(generationMetaKeyPrefix, generationID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis HGETALL failed: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, ErrCacheMiss
+	}
+	userID, _ := strconv.ParseInt(values["user_id"], 10, 64)
+	sessionID, _ := strconv.ParseInt(values["session_id"], 10, 64)
+	return &GenerationMeta{UserID: userID, SessionID: sessionID}, nil
+}
+
+// Append 追加一条事件，返回事件 ID
+func (c *GenerationCache) Append(ctx context.Context, generationID int64, event string, data string) (string, error) {
+	streamKey := fmt.Sprintf(generationStreamKeyPrefix, generationID)
+	pipe := c.client.TxPipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		MaxLen: generationMaxEvents,
+		Approx: true,
+		Values: map[string]interface{}{"event": event, "data": data},
+	})
+	pipe.Expire(ctx, streamKey, generationTTL)
+	pipe.Expire(ctx, fmt.Sprintf(generationMetaKeyPrefix, generationID), generationTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("redis XADD failed: %w", err)
+	}
+	return add.Val(), nil
+}
+
+// Touch 续期事件流与归属信息
+func (c *GenerationCache) Touch(ctx context.Context, generationID int64) error {
+	pipe := c.client.TxPipeline()
+	pipe.Expire(ctx, fmt.Sprintf(generationStreamKeyPrefix, generationID), generationTTL)
+	pipe.Expire(ctx, fmt.Sprintf(generationMetaKeyPrefix, generationID), generationTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis EXPIRE failed: %w", err)
+	}
+	return nil
+}
+
+// Read 读取 afterID 之后的事件，没有新事件时最多阻塞 block
+// afterID 为 "0" 时从头读取；超时仍无新事件返回空列表
+func (c *GenerationCache) Read(ctx context.Context, generationID int64, afterID string, block time.Duration) ([]GenerationEvent, error) {
+	streams, err := c.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{fmt.Sprintf(generationStreamKeyPrefix, generationID), afterID},
+		Count:   100,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis XREAD failed: %w", err)
+	}
+
+	var events []GenerationEvent
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			event, _ := msg.Values["event"].(string)
+			data, _ := msg.Values["data"].(string)
+			events = append(events, GenerationEvent{ID: msg.ID, Event: event, Data: data})
+		}
+	}
+	return events, nil
+}
+
+// ReserveIdempotencyKey 占用幂等 Key，Key 已被占用时返回已有记录与 false
+func (c *GenerationCache) ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string) (*IdempotencyRecord, bool, error) {
+	redisKey := fmt.Sprintf(idempotencyKeyPrefix, userID, key)
+	data, _ := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	ok, err := c.client.SetNX(ctx, redisKey, data, idempotencyTTL).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("redis SETNX failed: %w", err)
+	}
+	if ok {
+		return nil, true, nil
+	}
+
+	value, err := c.client.Get(ctx, redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		// 恰好过期，按处理中返回，由客户端稍后重试
+		return &IdempotencyRecord{Fingerprint: fingerprint}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis GET failed: %w", err)
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, false, fmt.Errorf("unmarshal idempotency record failed: %w", err)
+	}
+	return &record, false, nil
+}
+
+// BindIdempotencyKey 记录幂等 Key 启动的生成
+func (c *GenerationCache) BindIdempotencyKey(ctx context.Context, userID int64, key string, record IdempotencyRecord) error {
+	data, _ := json.Marshal(record)
+	return c.client.SetArgs(ctx, fmt.Sprintf(idempotencyKeyPrefix, userID, key), data, redis.SetArgs{
+		Mode: "XX",
+		TTL:  idempotencyTTL,
+	}).Err()
+}
+
+// TouchIdempotencyKey 续期幂等 Key
+func (c *GenerationCache) TouchIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	return c.client.Expire(ctx, fmt.Sprintf(idempotencyKeyPrefix, userID, key), idempotencyTTL).Err()
+}
+
+// ReleaseIdempotencyKey 释放幂等 Key
+func (c *GenerationCache) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	return c.client.Del(ctx, fmt.Sprintf(idempotencyKeyPrefix, userID, key)).Err()
+}
@@ -18,13 +18,30 @@ const (
 
 // CachedMessage Redis 中缓存的消息结构
 type CachedMessage struct {
-	ID        int64  `json:"id"`
-	SessionID int64  `json:"session_id"`
-	Role      string `json:"role"`
-	Content   string `json:"content"`
+	ID           int64            `json:"id"`
+	SessionID    int64            `json:"session_id"`
+	ParentID     int64            `json:"parent_id,omitempty"`
+	Role         string           `json:"role"`
+	Content      string           `json:"content"`
+	ToolCalls    []CachedToolCall `json:"tool_calls,omitempty"`
+	ToolCallID   string           `json:"tool_call_id,omitempty"`
+	FinishReason string           `json:"finish_reason,omitempty"`
+	// 生成该消息消耗的 token 数
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	// 实际生成该消息的 Provider 与模型
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
 	CreatedAt int64  `json:"created_at"` // Unix 毫秒
 }
 
+// CachedToolCall Redis 中缓存的工具调用
+type CachedToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
 // MessageCache 消息缓存操作封装
 type MessageCache struct {
 	client redis.Cmdable
@@ -36,6 +53,11 @@ func NewMessageCache(client redis.Cmdable, maxLen int64) *MessageCache {
 	return &MessageCache{client: client, maxLen: maxLen}
 }
 
+// Truncated 判断读取到 n 条消息的缓存是否可能已按上限截断
+func (c *MessageCache) Truncated(n int) bool {
+	return c.maxLen > 0 && int64(n) >= c.maxLen
+}
+
 // ==================== 写入操作 ====================
 
 // Append 追加一条消息到会话缓存 (RPUSH)
@@ -167,6 +189,6 @@ func (c *MessageCache) parseMessages(data []string) ([]*CachedMessage, error) {
 
 // Refresh 刷新缓存过期时间
 func (c *MessageCache) Refresh(ctx context.Context, sessionID int64) error {
-	key := c.buildKey(sessionID) 
+	key := c.buildKey(sessionID)
 	return c.client.Expire(ctx, key, messageTTL).Err()
 }
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 节点号租约 Key: snowflake:node:{node_id}，值为持有实例的标识
+const nodeLeaseKeyPrefix = "snowflake:node:%d"
+
+// renewNodeScript 续期自己持有的租约；租约已过期时重新占用，被其他实例占用时返回 0
+var renewNodeScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if not owner then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseNodeScript 仅释放自己持有的租约
+var releaseNodeScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// NodeCache 基于 Redis 租约分配 ID 生成节点号，保证同时运行的实例节点号不重复
+type NodeCache struct {
+	client redis.Cmdable
+}
+
+// NewNodeCache 创建 NodeCache 实例
+func NewNodeCache(client redis.Cmdable) *NodeCache {
+	return &NodeCache{client: client}
+}
+
+// Acquire 为 owner 占用节点号，有效期 ttl；已被占用时返回 false
+func (c *NodeCache) Acquire(ctx context.Context, nodeID int64, owner string, ttl time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, fmt.Sprintf(nodeLeaseKeyPrefix, nodeID), owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis SETNX failed: %w", err)
+	}
+	return ok, nil
+}
+
+// Renew 续期 owner 持有的节点号；节点号已被其他实例占用时返回 false
+func (c *NodeCache) Renew(ctx context.Context, nodeID int64, owner string, ttl time.Duration) (bool, error) {
+	n, err := renewNodeScript.Run(ctx, c.client, []string{fmt.Sprintf(nodeLeaseKeyPrefix, nodeID)},
+		owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("renew node lease failed: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Release 释放 owner 持有的节点号
+func (c *NodeCache) Release(ctx context.Context, nodeID int64, owner string) error {
+	if err := releaseNodeScript.Run(ctx, c.client, []string{fmt.Sprintf(nodeLeaseKeyPrefix, nodeID)}, owner).Err(); err != nil {
+		return fmt.Errorf("release node lease failed: %w", err)
+	}
+	return nil
+}
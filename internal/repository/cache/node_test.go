@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNodeCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewNodeCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+
+	if ok, err := c.Acquire(ctx, 3, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("Acquire() = %v, %v, want acquired", ok, err)
+	}
+	// 其他实例不能占用、续期或释放已被持有的节点号
+	if ok, err := c.Acquire(ctx, 3, "b", time.Minute); err != nil || ok {
+		t.Fatalf("second Acquire() = %v, %v, want rejected", ok, err)
+	}
+	if ok, err := c.Renew(ctx, 3, "b", time.Minute); err != nil || ok {
+		t.Fatalf("Renew() by other owner = %v, %v, want rejected", ok, err)
+	}
+	if err := c.Release(ctx, 3, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Renew(ctx, 3, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("Renew() = %v, %v, want renewed", ok, err)
+	}
+
+	// 租约过期后续期会重新占用
+	mr.FastForward(2 * time.Minute)
+	if ok, err := c.Renew(ctx, 3, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("Renew() after expiry = %v, %v, want reacquired", ok, err)
+	}
+
+	if err := c.Release(ctx, 3, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Acquire(ctx, 3, "b", time.Minute); err != nil || !ok {
+		t.Fatalf("Acquire() after release = %v, %v, want acquired", ok, err)
+	}
+}
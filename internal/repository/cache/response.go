@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 模型回复缓存 Key: llm:response:{hash}
+const responseKeyPrefix = "llm:response:%s"
+
+// ResponseCache 缓存模型回复，供重复提问直接复用
+type ResponseCache struct {
+	client redis.Cmdable
+}
+
+// NewResponseCache 创建 ResponseCache 实例
+func NewResponseCache(client redis.Cmdable) *ResponseCache {
+	return &ResponseCache{client: client}
+}
+
+// Get 获取缓存的回复，未命中时返回空字符串与 nil
+func (c *ResponseCache) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.client.Get(ctx, fmt.Sprintf(responseKeyPrefix, key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis GET failed: %w", err)
+	}
+	return value, nil
+}
+
+// Set 缓存回复
+func (c *ResponseCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return c.client.Set(ctx, fmt.Sprintf(responseKeyPrefix, key), value, ttl).Err()
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// 摘要缓存 Key 前缀: chat:session:{session_id}:summary
+	summaryKeyPrefix = "chat:session:%d:summary"
+	// 摘要缓存过期时间: 24 小时
+	summaryTTL = 24 * time.Hour
+)
+
+// ErrCacheMiss 缓存未命中
+var ErrCacheMiss = errors.New("cache miss")
+
+// CachedSummary Redis 中缓存的摘要结构
+type CachedSummary struct {
+	SessionID        int64  `json:"session_id"`
+	Content          string `json:"content"`
+	CoveredMessageID int64  `json:"covered_message_id"`
+	CoveredCount     int    `json:"covered_count"`
+	UpdatedAt        int64  `json:"updated_at"` // Unix 毫秒
+}
+
+// SummaryCache 会话摘要缓存
+type SummaryCache struct {
+	client redis.Cmdable
+}
+
+// NewSummaryCache 创建 SummaryCache 实例
+func NewSummaryCache(client redis.Cmdable) *SummaryCache {
+	return &SummaryCache{client: client}
+}
+
+// Get 获取摘要，不存在时返回 ErrCacheMiss
+func (c *SummaryCache) Get(ctx context.Context, sessionID int64) (*CachedSummary, error) {
+	data, err := c.client.Get(ctx, c.buildKey(sessionID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis GET failed: %w", err)
+	}
+
+	var summary CachedSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("unmarshal summary failed: %w", err)
+	}
+	return &summary, nil
+}
+
+// Set 写入摘要
+func (c *SummaryCache) Set(ctx context.Context, summary *CachedSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("marshal summary failed: %w", err)
+	}
+	return c.client.Set(ctx, c.buildKey(summary.SessionID), data, summaryTTL).Err()
+}
+
+// Delete 删除摘要缓存
+func (c *SummaryCache) Delete(ctx context.Context, sessionID int64) error {
+	return c.client.Del(ctx, c.buildKey(sessionID)).Err()
+}
+
+// buildKey 构建 Redis Key
+func (c *SummaryCache) buildKey(sessionID int64) string {
+	return fmt.Sprintf(summaryKeyPrefix, sessionID)
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey 数据库实体 (对应 api_keys 表)
+type APIKey struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	UserId     int64  `gorm:"index;not null"`
+	Name       string `gorm:"type:varchar(64);not null"`
+	Prefix     string `gorm:"type:varchar(16);not null"`          // 明文前缀，仅用于展示
+	KeyHash    string `gorm:"type:char(64);uniqueIndex;not null"` // 明文的 SHA-256
+	Scopes     string `gorm:"type:varchar(255);default:''"`       // 逗号分隔
+	ExpiresAt  int64  `gorm:"default:0"`                          // 0 表示永不过期
+	LastUsedAt int64  `gorm:"default:0"`
+	RevokedAt  int64  `gorm:"default:0"` // 0 表示未吊销
+	CreatedAt  int64  `gorm:"autoCreateTime:milli"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// APIKeyDAO API Key 数据访问对象
+type APIKeyDAO struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyDAO 创建 APIKeyDAO 实例
+func NewAPIKeyDAO(db *gorm.DB) *APIKeyDAO {
+	return &APIKeyDAO{db: db}
+}
+
+// Create 创建 API Key
+func (d *APIKeyDAO) Create(ctx context.Context, key *APIKey) error {
+	key.CreatedAt = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Create(key).Error
+}
+
+// FindByHash 根据哈希查找 API Key
+func (d *APIKeyDAO) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	var key APIKey
+	err := d.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	return &key, err
+}
+
+// FindByUserID 查找用户的所有 API Key (含已吊销)，按创建时间倒序
+func (d *APIKeyDAO) FindByUserID(ctx context.Context, userID int64) ([]APIKey, error) {
+	var keys []APIKey
+	err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// CountActiveByUserID 统计用户未吊销的 API Key 数量
+func (d *APIKeyDAO) CountActiveByUserID(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).
+		Model(&APIKey{}).
+		Where("user_id = ? AND revoked_at = 0", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// UpdateName 重命名用户的 API Key，不存在时返回 ErrRecordNotFound
+func (d *APIKeyDAO) UpdateName(ctx context.Context, id int64, userID int64, name string) error {
+	res := d.db.WithContext(ctx).
+		Model(&APIKey{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("name", name)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Revoke 吊销用户的 API Key，不存在或已吊销时返回 ErrRecordNotFound
+func (d *APIKeyDAO) Revoke(ctx context.Context, id int64, userID int64) error {
+	res := d.db.WithContext(ctx).
+		Model(&APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at = 0", id, userID).
+		Update("revoked_at", time.Now().UnixMilli())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateLastUsed 更新最后使用时间
+func (d *APIKeyDAO) UpdateLastUsed(ctx context.Context, id int64, lastUsedAt int64) error {
+	return d.db.WithContext(ctx).
+		Model(&APIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", lastUsedAt).Error
+}
@@ -0,0 +1,119 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Document 数据库实体 (对应 documents 表)
+type Document struct {
+	Id          int64  `gorm:"primaryKey,autoIncrement"`
+	UserId      int64  `gorm:"index;not null"`
+	Title       string `gorm:"type:varchar(255);not null"`
+	ContentType string `gorm:"type:varchar(64);default:''"`
+	Size        int
+	ChunkCount  int
+	CreatedAt   int64 `gorm:"autoCreateTime:milli"`
+}
+
+// TableName 指定表名
+func (Document) TableName() string {
+	return "documents"
+}
+
+// DocumentChunk 数据库实体 (对应 document_chunks 表)
+type DocumentChunk struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	DocumentId int64  `gorm:"index;not null"`
+	UserId     int64  `gorm:"index;not null"`
+	ChunkIndex int    `gorm:"not null"`
+	Content    string `gorm:"type:text;not null"`
+	Embedding  []byte `gorm:"type:mediumblob"` // float32 小端序编码
+	CreatedAt  int64  `gorm:"autoCreateTime:milli"`
+}
+
+// TableName 指定表名
+func (DocumentChunk) TableName() string {
+	return "document_chunks"
+}
+
+// DocumentDAO 文档数据访问对象
+type DocumentDAO struct {
+	db *gorm.DB
+}
+
+// NewDocumentDAO 创建 DocumentDAO 实例
+func NewDocumentDAO(db *gorm.DB) *DocumentDAO {
+	return &DocumentDAO{db: db}
+}
+
+// Create 创建文档
+func (d *DocumentDAO) Create(ctx context.Context, doc *Document) error {
+	doc.CreatedAt = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Create(doc).Error
+}
+
+// FindByID 根据 ID 查找文档
+func (d *DocumentDAO) FindByID(ctx context.Context, id int64) (*Document, error) {
+	var doc Document
+	err := d.db.WithContext(ctx).Where("id = ?", id).First(&doc).Error
+	return &doc, err
+}
+
+// FindByIDs 批量查找文档
+func (d *DocumentDAO) FindByIDs(ctx context.Context, ids []int64) ([]Document, error) {
+	var docs []Document
+	err := d.db.WithContext(ctx).Where("id IN ?", ids).Find(&docs).Error
+	return docs, err
+}
+
+// FindByUserID 查找用户的所有文档，按创建时间倒序
+func (d *DocumentDAO) FindByUserID(ctx context.Context, userID int64) ([]Document, error) {
+	var docs []Document
+	err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&docs).Error
+	return docs, err
+}
+
+// Delete 删除文档
+func (d *DocumentDAO) Delete(ctx context.Context, id int64) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&Document{}).Error
+}
+
+// DocumentChunkDAO 文档切片数据访问对象
+type DocumentChunkDAO struct {
+	db *gorm.DB
+}
+
+// NewDocumentChunkDAO 创建 DocumentChunkDAO 实例
+func NewDocumentChunkDAO(db *gorm.DB) *DocumentChunkDAO {
+	return &DocumentChunkDAO{db: db}
+}
+
+// BatchCreate 批量创建切片
+func (d *DocumentChunkDAO) BatchCreate(ctx context.Context, chunks []DocumentChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range chunks {
+		chunks[i].CreatedAt = now
+	}
+	return d.db.WithContext(ctx).CreateInBatches(&chunks, 100).Error
+}
+
+// FindByUserID 查找用户的所有切片
+func (d *DocumentChunkDAO) FindByUserID(ctx context.Context, userID int64) ([]DocumentChunk, error) {
+	var chunks []DocumentChunk
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Find(&chunks).Error
+	return chunks, err
+}
+
+// DeleteByDocumentID 删除文档的所有切片
+func (d *DocumentChunkDAO) DeleteByDocumentID(ctx context.Context, documentID int64) error {
+	return d.db.WithContext(ctx).Where("document_id = ?", documentID).Delete(&DocumentChunk{}).Error
+}
@@ -2,22 +2,41 @@ package dao
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// messageBatchSize 批量写入时单条 INSERT 语句的最大行数
+const messageBatchSize = 500
+
+// ErrMessageIDConflict 消息 ID 已被会话、角色或内容不同的另一条消息占用
+var ErrMessageIDConflict = errors.New("消息 ID 冲突")
+
 // Message 数据库实体 (对应 messages 表)
 type Message struct {
-	Id        int64  `gorm:"primaryKey,autoIncrement"`
-	SessionId int64  `gorm:"index;not null"`
-	Role      string `gorm:"type:varchar(20);not null"` // user, assistant, system
-	Content   string `gorm:"type:text;not null"`
+	Id           int64  `gorm:"primaryKey,autoIncrement"`
+	SessionId    int64  `gorm:"index;not null"`
+	ParentId     int64  `gorm:"index;default:0"`           // 父消息 ID，0 表示根消息
+	Role         string `gorm:"type:varchar(20);not null"` // user, assistant, system, tool_call, tool
+	Content      string `gorm:"type:text;not null"`
+	ToolCalls    string `gorm:"type:text"`                   // 工具调用列表 (JSON)
+	ToolCallId   string `gorm:"type:varchar(64);default:''"` // 工具结果对应的调用 ID
+	FinishReason string `gorm:"type:varchar(32);default:''"` // AI 回复的结束原因
+	// 生成该消息消耗的 token 数
+	PromptTokens     int `gorm:"default:0"`
+	CompletionTokens int `gorm:"default:0"`
+	// 实际生成该消息的 Provider 与模型
+	Provider  string `gorm:"type:varchar(32);default:''"`
+	Model     string `gorm:"type:varchar(64);default:''"`
 	CreatedAt int64  `gorm:"autoCreateTime:milli"`
 }
 
 // TableName 指定表名
-func (Message) TableName() string { 
+func (Message) TableName() string {
 	return "messages"
 }
 
@@ -31,15 +50,77 @@ func NewMessageDAO(db *gorm.DB) *MessageDAO {
 	return &MessageDAO{db: db}
 }
 
-// Create 创建新消息
+// Create 创建新消息，未指定创建时间时使用当前时间
 func (d *MessageDAO) Create(ctx context.Context, message *Message) error {
-	message.CreatedAt = time.Now().UnixMilli()
+	if message.CreatedAt == 0 {
+		message.CreatedAt = time.Now().UnixMilli()
+	}
 	return d.db.WithContext(ctx).Create(message).Error
 }
 
-// BatchCreate 批量创建消息
+// CreateOrIgnore 按主键幂等写入消息：主键已存在时不做任何修改
+// 用于消费可能重复投递的消息事件，返回是否实际插入；
+// 已有消息与 message 的会话、角色或内容不同时返回 ErrMessageIDConflict
+func (d *MessageDAO) CreateOrIgnore(ctx context.Context, message *Message) (bool, error) {
+	if message.CreatedAt == 0 {
+		message.CreatedAt = time.Now().UnixMilli()
+	}
+	res := d.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoNothing: true}).
+		Create(message)
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.RowsAffected > 0, res.Error
+	}
+	return false, checkDuplicates(d.db.WithContext(ctx), []Message{*message})
+}
+
+// BatchCreate 在一个事务中批量写入消息，主键已存在的消息不做修改 (幂等)
+// 任一消息与已有消息 ID 相同但会话、角色或内容不同时整批回滚，返回 ErrMessageIDConflict
 func (d *MessageDAO) BatchCreate(ctx context.Context, messages []Message) error {
-	return d.db.WithContext(ctx).Create(&messages).Error
+	if len(messages) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range messages {
+		if messages[i].CreatedAt == 0 {
+			messages[i].CreatedAt = now
+		}
+	}
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoNothing: true}).
+			CreateInBatches(&messages, messageBatchSize).Error; err != nil {
+			return err
+		}
+		return checkDuplicates(tx, messages)
+	})
+}
+
+// checkDuplicates 确认 messages 与库中同 ID 的消息是同一条 (重复投递)，而不是 ID 冲突的不同消息
+func checkDuplicates(db *gorm.DB, messages []Message) error {
+	ids := make([]int64, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].Id
+	}
+	var stored []Message
+	if err := db.Select("id", "session_id", "role", "content").Where("id IN ?", ids).Find(&stored).Error; err != nil {
+		return err
+	}
+	byID := make(map[int64]*Message, len(stored))
+	for i := range stored {
+		byID[stored[i].Id] = &stored[i]
+	}
+	for i := range messages {
+		msg := &messages[i]
+		existing, ok := byID[msg.Id]
+		if !ok {
+			continue
+		}
+		if existing.SessionId != msg.SessionId || existing.Role != msg.Role || existing.Content != msg.Content {
+			return fmt.Errorf("%w: id %d stored for session %d role %s, got session %d role %s",
+				ErrMessageIDConflict, msg.Id, existing.SessionId, existing.Role, msg.SessionId, msg.Role)
+		}
+	}
+	return nil
 }
 
 // FindBySessionID 根据会话 ID 查找所有消息，按创建时间正序
@@ -80,6 +161,18 @@ func (d *MessageDAO) CountBySessionID(ctx context.Context, sessionID int64) (int
 	return count, err
 }
 
+// UpdateParents 在同一事务中批量更新消息的父消息 ID (消息 ID -> 父消息 ID)
+func (d *MessageDAO) UpdateParents(ctx context.Context, parents map[int64]int64) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, parentID := range parents {
+			if err := tx.Model(&Message{}).Where("id = ?", id).Update("parent_id", parentID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // DeleteBySessionID 删除会话的所有消息
 func (d *MessageDAO) DeleteBySessionID(ctx context.Context, sessionID int64) error {
 	return d.db.WithContext(ctx).Where("session_id = ?", sessionID).Delete(&Message{}).Error
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"coca-ai/internal/repository/dao/daotest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMessageDAO_CreateOrIgnore(t *testing.T) {
+	d := NewMessageDAO(daotest.NewDB(t, &Message{}))
+	ctx := context.Background()
+
+	created, err := d.CreateOrIgnore(ctx, &Message{Id: 1001, SessionId: 1, Role: "user", Content: "hello"})
+	if err != nil || !created {
+		t.Fatalf("first CreateOrIgnore() = %v, %v, want created", created, err)
+	}
+	// 重复投递的同一条消息为空操作
+	created, err = d.CreateOrIgnore(ctx, &Message{Id: 1001, SessionId: 1, Role: "user", Content: "hello", CreatedAt: 1})
+	if err != nil || created {
+		t.Fatalf("duplicate CreateOrIgnore() = %v, %v, want ignored", created, err)
+	}
+	// 同一 ID 的不同消息不能被静默丢弃
+	created, err = d.CreateOrIgnore(ctx, &Message{Id: 1001, SessionId: 2, Role: "user", Content: "hello again"})
+	if !errors.Is(err, ErrMessageIDConflict) || created {
+		t.Fatalf("conflicting CreateOrIgnore() = %v, %v, want %v", created, err, ErrMessageIDConflict)
+	}
+
+	messages, err := d.FindBySessionID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Content != "hello" {
+		t.Fatalf("messages = %+v, want the first write only", messages)
+	}
+	if messages[0].CreatedAt == 0 {
+		t.Fatal("CreatedAt not set")
+	}
+}
+
+func TestMessageDAO_BatchCreate(t *testing.T) {
+	d := NewMessageDAO(daotest.NewDB(t, &Message{}))
+	ctx := context.Background()
+
+	if err := d.BatchCreate(ctx, []Message{
+		{Id: 1001, SessionId: 1, Role: "user", Content: "hello"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 重复投递的消息与新消息混在同一批中
+	if err := d.BatchCreate(ctx, []Message{
+		{Id: 1001, SessionId: 1, Role: "user", Content: "hello"},
+		{Id: 1002, SessionId: 1, Role: "assistant", Content: "hi"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.BatchCreate(ctx, nil); err != nil {
+		t.Fatalf("BatchCreate(nil) error = %v", err)
+	}
+	// 批内任一消息 ID 冲突时整批回滚
+	err := d.BatchCreate(ctx, []Message{
+		{Id: 1003, SessionId: 1, Role: "user", Content: "again"},
+		{Id: 1002, SessionId: 1, Role: "assistant", Content: "other reply"},
+	})
+	if !errors.Is(err, ErrMessageIDConflict) {
+		t.Fatalf("conflicting BatchCreate() error = %v, want %v", err, ErrMessageIDConflict)
+	}
+
+	messages, err := d.FindBySessionID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "hello" || messages[1].Content != "hi" {
+		t.Fatalf("messages = %+v, want hello and hi", messages)
+	}
+}
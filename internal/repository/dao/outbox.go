@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// OutboxStatusPending 等待发布
+	OutboxStatusPending = "pending"
+	// OutboxStatusSent 已发布
+	OutboxStatusSent = "sent"
+	// OutboxStatusDead 重试次数耗尽，需人工处理
+	OutboxStatusDead = "dead"
+)
+
+// OutboxEvent 数据库实体 (对应 message_outbox 表)
+// 记录未能发送到 Kafka 的消息事件，由 OutboxRelay 重新发布
+type OutboxEvent struct {
+	Id            int64  `gorm:"primaryKey,autoIncrement"`
+	Topic         string `gorm:"type:varchar(128);not null"`
+	MessageKey    string `gorm:"type:varchar(128);not null"`
+	Payload       string `gorm:"type:mediumtext;not null"` // 事件 JSON
+	RequestId     string `gorm:"type:varchar(64);default:''"`
+	Headers       string `gorm:"type:text"` // 消息头 JSON (链路上下文与请求 ID)
+	Status        string `gorm:"type:varchar(16);not null;index:idx_outbox_status_next,priority:1"`
+	Attempts      int    `gorm:"default:0"`
+	NextAttemptAt int64  `gorm:"not null;index:idx_outbox_status_next,priority:2"` // Unix 毫秒
+	LastError     string `gorm:"type:varchar(1024);default:''"`
+	CreatedAt     int64  `gorm:"autoCreateTime:milli"`
+	UpdatedAt     int64  `gorm:"autoUpdateTime:milli"`
+}
+
+// TableName 指定表名
+func (OutboxEvent) TableName() string {
+	return "message_outbox"
+}
+
+// OutboxDAO 消息发件箱数据访问对象
+type OutboxDAO struct {
+	db *gorm.DB
+}
+
+// NewOutboxDAO 创建 OutboxDAO 实例
+func NewOutboxDAO(db *gorm.DB) *OutboxDAO {
+	return &OutboxDAO{db: db}
+}
+
+// Create 写入待发布事件，立即可被重新发布
+func (d *OutboxDAO) Create(ctx context.Context, event *OutboxEvent) error {
+	event.Status = OutboxStatusPending
+	if event.NextAttemptAt == 0 {
+		event.NextAttemptAt = time.Now().UnixMilli()
+	}
+	return d.db.WithContext(ctx).Create(event).Error
+}
+
+// FindDue 按写入顺序查找已到重试时间的待发布事件
+func (d *OutboxDAO) FindDue(ctx context.Context, now int64, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, now).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// MarkSent 标记事件已发布
+func (d *OutboxDAO) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.db.WithContext(ctx).Model(&OutboxEvent{}).
+		Where("id IN ? AND status = ?", ids, OutboxStatusPending).
+		Update("status", OutboxStatusSent).Error
+}
+
+// MarkFailed 记录一次发布失败，dead 为 true 时不再重试
+func (d *OutboxDAO) MarkFailed(ctx context.Context, id int64, attempts int, nextAttemptAt int64, lastErr string, dead bool) error {
+	status := OutboxStatusPending
+	if dead {
+		status = OutboxStatusDead
+	}
+	if len(lastErr) > 1024 {
+		lastErr = lastErr[:1024]
+	}
+	return d.db.WithContext(ctx).Model(&OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":          status,
+			"attempts":        attempts,
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastErr,
+		}).Error
+}
+
+// DeleteSentBefore 清理指定时间 (Unix 毫秒) 之前已发布的事件，返回删除条数
+func (d *OutboxDAO) DeleteSentBefore(ctx context.Context, before int64) (int64, error) {
+	res := d.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ?", OutboxStatusSent, before).
+		Delete(&OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// CountPending 统计待发布事件数
+func (d *OutboxDAO) CountPending(ctx context.Context) (int64, error) {
+	var count int64
+	err := d.db.WithContext(ctx).Model(&OutboxEvent{}).Where("status = ?", OutboxStatusPending).Count(&count).Error
+	return count, err
+}
@@ -9,11 +9,21 @@ import (
 
 // Session 数据库实体 (对应 sessions 表)
 type Session struct {
-	Id        int64  `gorm:"primaryKey,autoIncrement"`
-	UserId    int64  `gorm:"index"`
-	Title     string `gorm:"type:varchar(255);default:''"`
-	CreatedAt int64  `gorm:"autoCreateTime:milli"`
-	UpdatedAt int64  `gorm:"autoUpdateTime:milli;index"`
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	UserId int64  `gorm:"index"`
+	Title  string `gorm:"type:varchar(255);default:''"`
+
+	// 生成参数 (NULL 表示使用 Provider 默认值)
+	Model       string   `gorm:"type:varchar(64);default:''"`
+	Temperature *float32 `gorm:"type:float"`
+	TopP        *float32 `gorm:"type:float"`
+	MaxTokens   *int
+	Stop        string `gorm:"type:varchar(1024);default:''"` // JSON 数组
+
+	ActiveMessageId int64 `gorm:"default:0"` // 当前分支的末端消息
+
+	CreatedAt int64 `gorm:"autoCreateTime:milli"`
+	UpdatedAt int64 `gorm:"autoUpdateTime:milli;index"`
 }
 
 // TableName 指定表名
@@ -64,6 +74,29 @@ func (d *SessionDAO) UpdateTitle(ctx context.Context, id int64, title string) er
 		Update("title", title).Error
 }
 
+// UpdateSettings 更新会话的生成参数
+func (d *SessionDAO) UpdateSettings(ctx context.Context, session *Session) error {
+	return d.db.WithContext(ctx).
+		Model(&Session{}).
+		Where("id = ?", session.Id).
+		Updates(map[string]any{
+			"model":       session.Model,
+			"temperature": session.Temperature,
+			"top_p":       session.TopP,
+			"max_tokens":  session.MaxTokens,
+			"stop":        session.Stop,
+			"updated_at":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// UpdateActiveMessage 更新会话当前分支的末端消息
+func (d *SessionDAO) UpdateActiveMessage(ctx context.Context, id int64, messageID int64) error {
+	return d.db.WithContext(ctx).
+		Model(&Session{}).
+		Where("id = ?", id).
+		Update("active_message_id", messageID).Error
+}
+
 // Delete 删除会话
 func (d *SessionDAO) Delete(ctx context.Context, id int64) error {
 	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&Session{}).Error
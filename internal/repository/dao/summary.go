@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Summary 数据库实体 (对应 session_summaries 表)
+type Summary struct {
+	SessionId        int64  `gorm:"primaryKey;autoIncrement:false"`
+	Content          string `gorm:"type:text;not null"`
+	CoveredMessageId int64  `gorm:"not null"`
+	CoveredCount     int    `gorm:"not null"`
+	UpdatedAt        int64  `gorm:"autoUpdateTime:milli"`
+}
+
+// TableName 指定表名
+func (Summary) TableName() string {
+	return "session_summaries"
+}
+
+// SummaryDAO 会话摘要数据访问对象
+type SummaryDAO struct {
+	db *gorm.DB
+}
+
+// NewSummaryDAO 创建 SummaryDAO 实例
+func NewSummaryDAO(db *gorm.DB) *SummaryDAO {
+	return &SummaryDAO{db: db}
+}
+
+// Upsert 写入摘要，已存在时覆盖
+func (d *SummaryDAO) Upsert(ctx context.Context, summary *Summary) error {
+	summary.UpdatedAt = time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "covered_message_id", "covered_count", "updated_at"}),
+	}).Create(summary).Error
+}
+
+// FindBySessionID 根据会话 ID 查找摘要
+func (d *SummaryDAO) FindBySessionID(ctx context.Context, sessionID int64) (*Summary, error) {
+	var summary Summary
+	err := d.db.WithContext(ctx).Where("session_id = ?", sessionID).First(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// DeleteBySessionID 删除会话摘要
+func (d *SummaryDAO) DeleteBySessionID(ctx context.Context, sessionID int64) error {
+	return d.db.WithContext(ctx).Where("session_id = ?", sessionID).Delete(&Summary{}).Error
+}
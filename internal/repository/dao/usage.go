@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserDailyUsage 数据库实体 (对应 user_daily_usage 表)
+// 每个用户每天一行，记录当天的 token 用量
+type UserDailyUsage struct {
+	Id               int64  `gorm:"primaryKey,autoIncrement"`
+	UserId           int64  `gorm:"uniqueIndex:idx_user_day;not null"`
+	Day              string `gorm:"type:varchar(10);uniqueIndex:idx_user_day;not null"` // 2006-01-02
+	PromptTokens     int64  `gorm:"default:0"`
+	CompletionTokens int64  `gorm:"default:0"`
+	Requests         int64  `gorm:"default:0"`
+	UpdatedAt        int64  `gorm:"autoUpdateTime:milli"`
+}
+
+// TableName 指定表名
+func (UserDailyUsage) TableName() string {
+	return "user_daily_usage"
+}
+
+// UsageDAO 用量数据访问对象
+type UsageDAO struct {
+	db *gorm.DB
+}
+
+// NewUsageDAO 创建 UsageDAO 实例
+func NewUsageDAO(db *gorm.DB) *UsageDAO {
+	return &UsageDAO{db: db}
+}
+
+// Increment 累加用户当天的用量，当天没有记录时插入
+func (d *UsageDAO) Increment(ctx context.Context, userID int64, day string, promptTokens, completionTokens, requests int64) error {
+	now := time.Now().UnixMilli()
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", promptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", completionTokens),
+			"requests":          gorm.Expr("requests + ?", requests),
+			"updated_at":        now,
+		}),
+	}).Create(&UserDailyUsage{
+		UserId:           userID,
+		Day:              day,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Requests:         requests,
+		UpdatedAt:        now,
+	}).Error
+}
+
+// FindRange 查询用户在 [from, to] 日期区间内的每日用量，按日期升序
+func (d *UsageDAO) FindRange(ctx context.Context, userID int64, from, to string) ([]UserDailyUsage, error) {
+	var rows []UserDailyUsage
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND day >= ? AND day <= ?", userID, from, to).
+		Order("day ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// SumTokens 统计用户在 [from, to] 日期区间内的总 token 数
+func (d *UsageDAO) SumTokens(ctx context.Context, userID int64, from, to string) (int64, error) {
+	var total int64
+	err := d.db.WithContext(ctx).
+		Model(&UserDailyUsage{}).
+		Select("COALESCE(SUM(prompt_tokens + completion_tokens), 0)").
+		Where("user_id = ? AND day >= ? AND day <= ?", userID, from, to).
+		Scan(&total).Error
+	return total, err
+}
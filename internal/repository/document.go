@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrDocumentNotFound = dao.ErrRecordNotFound
+
+// DocumentRepository 文档仓储层
+type DocumentRepository struct {
+	dao *dao.DocumentDAO
+}
+
+// NewDocumentRepository 创建 DocumentRepository 实例
+func NewDocumentRepository(dao *dao.DocumentDAO) *DocumentRepository {
+	return &DocumentRepository{dao: dao}
+}
+
+// Create 创建文档
+func (r *DocumentRepository) Create(ctx context.Context, doc *domain.Document) (*domain.Document, error) {
+	entity := r.toEntity(doc)
+	if err := r.dao.Create(ctx, entity); err != nil {
+		return nil, err
+	}
+	return r.toDomain(entity), nil
+}
+
+// FindByID 根据 ID 查找文档
+func (r *DocumentRepository) FindByID(ctx context.Context, id int64) (*domain.Document, error) {
+	entity, err := r.dao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(entity), nil
+}
+
+// FindByIDs 批量查找文档，返回 ID -> 文档 映射
+func (r *DocumentRepository) FindByIDs(ctx context.Context, ids []int64) (map[int64]*domain.Document, error) {
+	result := make(map[int64]*domain.Document, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	entities, err := r.dao.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entities {
+		result[entities[i].Id] = r.toDomain(&entities[i])
+	}
+	return result, nil
+}
+
+// FindByUserID 查找用户的所有文档
+func (r *DocumentRepository) FindByUserID(ctx context.Context, userID int64) ([]domain.Document, error) {
+	entities, err := r.dao.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]domain.Document, len(entities))
+	for i, entity := range entities {
+		docs[i] = *r.toDomain(&entity)
+	}
+	return docs, nil
+}
+
+// Delete 删除文档
+func (r *DocumentRepository) Delete(ctx context.Context, id int64) error {
+	return r.dao.Delete(ctx, id)
+}
+
+// ==================== 转换方法 ====================
+
+// toEntity 将 Domain 转换为 DAO Entity
+func (r *DocumentRepository) toEntity(doc *domain.Document) *dao.Document {
+	return &dao.Document{
+		Id:          doc.ID,
+		UserId:      doc.UserID,
+		Title:       doc.Title,
+		ContentType: doc.ContentType,
+		Size:        doc.Size,
+		ChunkCount:  doc.ChunkCount,
+		CreatedAt:   doc.CreatedAt.UnixMilli(),
+	}
+}
+
+// toDomain 将 DAO Entity 转换为 Domain
+func (r *DocumentRepository) toDomain(entity *dao.Document) *domain.Document {
+	return &domain.Document{
+		ID:          entity.Id,
+		UserID:      entity.UserId,
+		Title:       entity.Title,
+		ContentType: entity.ContentType,
+		Size:        entity.Size,
+		ChunkCount:  entity.ChunkCount,
+		CreatedAt:   time.UnixMilli(entity.CreatedAt),
+	}
+}
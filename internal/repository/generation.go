@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/cache"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrGenerationNotFound 生成不存在或已过期
+var ErrGenerationNotFound = errors.New("generation not found")
+
+// GenerationRepository 生成事件仓储层 (仅 Redis)
+type GenerationRepository struct {
+	cache *cache.GenerationCache
+}
+
+// NewGenerationRepository 创建 GenerationRepository 实例
+func NewGenerationRepository(cache *cache.GenerationCache) *GenerationRepository {
+	return &GenerationRepository{cache: cache}
+}
+
+// Create 登记一次生成
+func (r *GenerationRepository) Create(ctx context.Context, generation *domain.Generation) error {
+	return r.cache.Create(ctx, generation.ID, cache.GenerationMeta{
+		UserID:    generation.UserID,
+		SessionID: generation.SessionID,
+	})
+}
+
+// FindByID 查找生成，不存在或已过期返回 ErrGenerationNotFound
+func (r *GenerationRepository) FindByID(ctx context.Context, generationID int64) (*domain.Generation, error) {
+	meta, err := r.cache.GetMeta(ctx, generationID)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, ErrGenerationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Generation{
+		ID:        generationID,
+		UserID:    meta.UserID,
+		SessionID: meta.SessionID,
+	}, nil
+}
+
+// AppendEvent 追加一条事件，返回事件 ID
+func (r *GenerationRepository) AppendEvent(ctx context.Context, generationID int64, event string, data string) (string, error) {
+	return r.cache.Append(ctx, generationID, event, data)
+}
+
+// Touch 续期生成的事件流，避免长时间无事件的生成在结束前过期
+func (r *GenerationRepository) Touch(ctx context.Context, generationID int64) error {
+	return r.cache.Touch(ctx, generationID)
+}
+
+// ReadEvents 读取 afterID 之后的事件，没有新事件时最多阻塞 block
+func (r *GenerationRepository) ReadEvents(ctx context.Context, generationID int64, afterID string, block time.Duration) ([]domain.GenerationEvent, error) {
+	cached, err := r.cache.Read(ctx, generationID, afterID, block)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]domain.GenerationEvent, len(cached))
+	for i, e := range cached {
+		events[i] = domain.GenerationEvent{ID: e.ID, Event: e.Event, Data: e.Data}
+	}
+	return events, nil
+}
+
+// ReserveIdempotencyKey 占用幂等 Key，Key 已被占用时返回首次请求与 false
+func (r *GenerationRepository) ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string) (*domain.IdempotentRequest, bool, error) {
+	record, ok, err := r.cache.ReserveIdempotencyKey(ctx, userID, key, fingerprint)
+	if err != nil || ok {
+		return nil, ok, err
+	}
+	return &domain.IdempotentRequest{Fingerprint: record.Fingerprint, GenerationID: record.GenerationID}, false, nil
+}
+
+// BindIdempotencyKey 记录幂等 Key 启动的生成
+func (r *GenerationRepository) BindIdempotencyKey(ctx context.Context, userID int64, key string, req domain.IdempotentRequest) error {
+	return r.cache.BindIdempotencyKey(ctx, userID, key, cache.IdempotencyRecord{
+		Fingerprint:  req.Fingerprint,
+		GenerationID: req.GenerationID,
+	})
+}
+
+// TouchIdempotencyKey 续期幂等 Key，使其与生成事件流同时过期
+func (r *GenerationRepository) TouchIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	return r.cache.TouchIdempotencyKey(ctx, userID, key)
+}
+
+// ReleaseIdempotencyKey 释放幂等 Key
+func (r *GenerationRepository) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	return r.cache.ReleaseIdempotencyKey(ctx, userID, key)
+}
@@ -2,9 +2,11 @@ package repository
 
 import (
 	"coca-ai/internal/domain"
+	"coca-ai/internal/metrics"
 	"coca-ai/internal/repository/cache"
 	"coca-ai/internal/repository/dao"
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -52,8 +54,10 @@ func (r *MessageRepository) FindBySessionID(ctx context.Context, sessionID int64
 	if r.cache != nil {
 		cached, err := r.cache.GetAll(ctx, sessionID)
 		if err == nil && len(cached) > 0 {
+			metrics.MessageCacheRequests.WithLabelValues("get_all", "hit").Inc()
 			return r.cachedToDomainList(cached), nil
 		}
+		metrics.MessageCacheRequests.WithLabelValues("get_all", "miss").Inc()
 	}
 
 	// 2. 缓存未命中，从数据库读取
@@ -75,14 +79,57 @@ func (r *MessageRepository) FindBySessionID(ctx context.Context, sessionID int64
 	return messages, nil
 }
 
+// FindTreeBySessionID 获取会话的完整消息树，用于沿父消息遍历分支
+// 缓存只保留最近的消息，可能已截断时从数据库读取完整历史，再补上缓存中尚未落库的消息
+func (r *MessageRepository) FindTreeBySessionID(ctx context.Context, sessionID int64) ([]domain.Message, error) {
+	// 1. 缓存未截断时即为完整消息树
+	var cached []*cache.CachedMessage
+	if r.cache != nil {
+		all, err := r.cache.GetAll(ctx, sessionID)
+		if err == nil && len(all) > 0 && !r.cache.Truncated(len(all)) {
+			metrics.MessageCacheRequests.WithLabelValues("get_tree", "hit").Inc()
+			return r.cachedToDomainList(all), nil
+		}
+		metrics.MessageCacheRequests.WithLabelValues("get_tree", "miss").Inc()
+		cached = all
+	}
+
+	// 2. 从数据库读取完整历史
+	entities, err := r.dao.FindBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]domain.Message, len(entities), len(entities)+len(cached))
+	persisted := make(map[int64]bool, len(entities))
+	for i, entity := range entities {
+		messages[i] = *r.toDomain(&entity)
+		persisted[entity.Id] = true
+	}
+
+	// 3. 补上缓存中尚未落库的最新消息
+	for _, msg := range cached {
+		if msg.ID != 0 && !persisted[msg.ID] {
+			messages = append(messages, *r.cachedToDomain(msg))
+		}
+	}
+
+	// 4. 缓存不存在时回填 (异步，不阻塞主流程)
+	if r.cache != nil && len(cached) == 0 && len(messages) > 0 {
+		go r.warmupCache(context.Background(), sessionID, messages)
+	}
+	return messages, nil
+}
+
 // FindRecentBySessionID 获取会话的最近 N 条消息 (Read-Through)
 func (r *MessageRepository) FindRecentBySessionID(ctx context.Context, sessionID int64, limit int) ([]domain.Message, error) {
 	// 1. 尝试从缓存读取
 	if r.cache != nil {
 		cached, err := r.cache.GetRecent(ctx, sessionID, limit)
 		if err == nil && len(cached) > 0 {
+			metrics.MessageCacheRequests.WithLabelValues("get_recent", "hit").Inc()
 			return r.cachedToDomainList(cached), nil
 		}
+		metrics.MessageCacheRequests.WithLabelValues("get_recent", "miss").Inc()
 	}
 
 	// 2. 缓存未命中，从数据库读取
@@ -105,8 +152,10 @@ func (r *MessageRepository) CountBySessionID(ctx context.Context, sessionID int6
 	if r.cache != nil {
 		count, err := r.cache.GetCount(ctx, sessionID)
 		if err == nil && count > 0 {
+			metrics.MessageCacheRequests.WithLabelValues("count", "hit").Inc()
 			return count, nil
 		}
+		metrics.MessageCacheRequests.WithLabelValues("count", "miss").Inc()
 	}
 	return r.dao.CountBySessionID(ctx, sessionID)
 }
@@ -128,6 +177,37 @@ func (r *MessageRepository) AppendToCache(ctx context.Context, message *domain.M
 	return r.cache.Append(ctx, r.toCachedMessage(message))
 }
 
+// LinkSequential 为尚未启用分支的会话按时间顺序补全父消息 ID
+// 在同一事务中更新 MySQL 并刷新缓存，返回补全后的消息列表
+func (r *MessageRepository) LinkSequential(ctx context.Context, sessionID int64) ([]domain.Message, error) {
+	messages, err := r.FindTreeBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make(map[int64]int64)
+	for i := 1; i < len(messages); i++ {
+		if messages[i].ParentID != 0 || messages[i].ID == 0 {
+			continue
+		}
+		messages[i].ParentID = messages[i-1].ID
+		parents[messages[i].ID] = messages[i].ParentID
+	}
+	if len(parents) == 0 {
+		return messages, nil
+	}
+
+	// 尚未落库的消息更新 0 行，仅在缓存中补全
+	if err := r.dao.UpdateParents(ctx, parents); err != nil {
+		return nil, err
+	}
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, sessionID)
+		r.warmupCache(ctx, sessionID, messages)
+	}
+	return messages, nil
+}
+
 // ==================== 私有方法 ====================
 
 // warmupCache 将数据库数据回填到缓存
@@ -144,45 +224,91 @@ func (r *MessageRepository) warmupCache(ctx context.Context, sessionID int64, me
 // toEntity 将 Domain 转换为 DAO Entity
 func (r *MessageRepository) toEntity(message *domain.Message) *dao.Message {
 	return &dao.Message{
-		Id:        message.ID,
-		SessionId: message.SessionID,
-		Role:      string(message.Role),
-		Content:   message.Content,
-		CreatedAt: message.CreatedAt.UnixMilli(),
+		Id:               message.ID,
+		SessionId:        message.SessionID,
+		ParentId:         message.ParentID,
+		Role:             string(message.Role),
+		Content:          message.Content,
+		ToolCalls:        EncodeToolCalls(message.ToolCalls),
+		ToolCallId:       message.ToolCallID,
+		FinishReason:     message.FinishReason,
+		PromptTokens:     message.PromptTokens,
+		CompletionTokens: message.CompletionTokens,
+		Provider:         message.Provider,
+		Model:            message.Model,
+		CreatedAt:        message.CreatedAt.UnixMilli(),
 	}
 }
 
 // toDomain 将 DAO Entity 转换为 Domain
 func (r *MessageRepository) toDomain(entity *dao.Message) *domain.Message {
 	return &domain.Message{
-		ID:        entity.Id,
-		SessionID: entity.SessionId,
-		Role:      domain.MessageRole(entity.Role),
-		Content:   entity.Content,
-		CreatedAt: time.UnixMilli(entity.CreatedAt),
+		ID:               entity.Id,
+		SessionID:        entity.SessionId,
+		ParentID:         entity.ParentId,
+		Role:             domain.MessageRole(entity.Role),
+		Content:          entity.Content,
+		ToolCalls:        DecodeToolCalls(entity.ToolCalls),
+		ToolCallID:       entity.ToolCallId,
+		FinishReason:     entity.FinishReason,
+		PromptTokens:     entity.PromptTokens,
+		CompletionTokens: entity.CompletionTokens,
+		Provider:         entity.Provider,
+		Model:            entity.Model,
+		CreatedAt:        time.UnixMilli(entity.CreatedAt),
 	}
 }
 
 // toCachedMessage 将 Domain 转换为 CachedMessage
 func (r *MessageRepository) toCachedMessage(msg *domain.Message) *cache.CachedMessage {
-	return &cache.CachedMessage{
-		ID:        msg.ID,
-		SessionID: msg.SessionID,
-		Role:      string(msg.Role),
-		Content:   msg.Content,
-		CreatedAt: msg.CreatedAt.UnixMilli(),
+	cached := &cache.CachedMessage{
+		ID:               msg.ID,
+		SessionID:        msg.SessionID,
+		ParentID:         msg.ParentID,
+		Role:             string(msg.Role),
+		Content:          msg.Content,
+		ToolCallID:       msg.ToolCallID,
+		FinishReason:     msg.FinishReason,
+		PromptTokens:     msg.PromptTokens,
+		CompletionTokens: msg.CompletionTokens,
+		Provider:         msg.Provider,
+		Model:            msg.Model,
+		CreatedAt:        msg.CreatedAt.UnixMilli(),
 	}
+	for _, call := range msg.ToolCalls {
+		cached.ToolCalls = append(cached.ToolCalls, cache.CachedToolCall{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
+	}
+	return cached
 }
 
 // cachedToDomain 将 CachedMessage 转换为 Domain
 func (r *MessageRepository) cachedToDomain(cached *cache.CachedMessage) *domain.Message {
-	return &domain.Message{
-		ID:        cached.ID,
-		SessionID: cached.SessionID,
-		Role:      domain.MessageRole(cached.Role),
-		Content:   cached.Content,
-		CreatedAt: time.UnixMilli(cached.CreatedAt),
+	msg := &domain.Message{
+		ID:               cached.ID,
+		SessionID:        cached.SessionID,
+		ParentID:         cached.ParentID,
+		Role:             domain.MessageRole(cached.Role),
+		Content:          cached.Content,
+		ToolCallID:       cached.ToolCallID,
+		FinishReason:     cached.FinishReason,
+		PromptTokens:     cached.PromptTokens,
+		CompletionTokens: cached.CompletionTokens,
+		Provider:         cached.Provider,
+		Model:            cached.Model,
+		CreatedAt:        time.UnixMilli(cached.CreatedAt),
+	}
+	for _, call := range cached.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, domain.ToolCall{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
 	}
+	return msg
 }
 
 // cachedToDomainList 批量转换
@@ -193,3 +319,25 @@ func (r *MessageRepository) cachedToDomainList(cached []*cache.CachedMessage) []
 	}
 	return messages
 }
+
+// EncodeToolCalls 将工具调用序列化为 JSON (用于 MySQL 存储)
+func EncodeToolCalls(calls []domain.ToolCall) string {
+	if len(calls) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(calls)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// DecodeToolCalls 反序列化工具调用
+func DecodeToolCalls(data string) []domain.ToolCall {
+	if data == "" {
+		return nil
+	}
+	var calls []domain.ToolCall
+	_ = json.Unmarshal([]byte(data), &calls)
+	return calls
+}
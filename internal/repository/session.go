@@ -4,9 +4,12 @@ import (
 	"coca-ai/internal/domain"
 	"coca-ai/internal/repository/dao"
 	"context"
+	"encoding/json"
 	"time"
 )
 
+var ErrSessionNotFound = dao.ErrRecordNotFound
+
 // SessionRepository 会话仓储层
 type SessionRepository struct {
 	dao *dao.SessionDAO
@@ -54,6 +57,16 @@ func (r *SessionRepository) UpdateTitle(ctx context.Context, id int64, title str
 	return r.dao.UpdateTitle(ctx, id, title)
 }
 
+// UpdateSettings 更新会话的生成参数
+func (r *SessionRepository) UpdateSettings(ctx context.Context, session *domain.Session) error {
+	return r.dao.UpdateSettings(ctx, r.toEntity(session))
+}
+
+// UpdateActiveMessage 更新会话当前分支的末端消息
+func (r *SessionRepository) UpdateActiveMessage(ctx context.Context, id int64, messageID int64) error {
+	return r.dao.UpdateActiveMessage(ctx, id, messageID)
+}
+
 // Delete 删除会话
 func (r *SessionRepository) Delete(ctx context.Context, id int64) error {
 	return r.dao.Delete(ctx, id)
@@ -68,10 +81,23 @@ func (r *SessionRepository) TouchUpdatedAt(ctx context.Context, id int64) error
 
 // toEntity 将 Domain 转换为 DAO Entity
 func (r *SessionRepository) toEntity(session *domain.Session) *dao.Session {
+	var stop string
+	if len(session.Stop) > 0 {
+		data, _ := json.Marshal(session.Stop)
+		stop = string(data)
+	}
 	return &dao.Session{
-		Id:        session.ID,
-		UserId:    session.UserID,
-		Title:     session.Title,
+		Id:          session.ID,
+		UserId:      session.UserID,
+		Title:       session.Title,
+		Model:       session.Model,
+		Temperature: session.Temperature,
+		TopP:        session.TopP,
+		MaxTokens:   session.MaxTokens,
+		Stop:        stop,
+
+		ActiveMessageId: session.ActiveMessageID,
+
 		CreatedAt: session.CreatedAt.UnixMilli(),
 		UpdatedAt: session.UpdatedAt.UnixMilli(),
 	}
@@ -79,10 +105,22 @@ func (r *SessionRepository) toEntity(session *domain.Session) *dao.Session {
 
 // toDomain 将 DAO Entity 转换为 Domain
 func (r *SessionRepository) toDomain(entity *dao.Session) *domain.Session {
+	var stop []string
+	if entity.Stop != "" {
+		_ = json.Unmarshal([]byte(entity.Stop), &stop)
+	}
 	return &domain.Session{
-		ID:        entity.Id,
-		UserID:    entity.UserId,
-		Title:     entity.Title,
+		ID:          entity.Id,
+		UserID:      entity.UserId,
+		Title:       entity.Title,
+		Model:       entity.Model,
+		Temperature: entity.Temperature,
+		TopP:        entity.TopP,
+		MaxTokens:   entity.MaxTokens,
+		Stop:        stop,
+
+		ActiveMessageID: entity.ActiveMessageId,
+
 		CreatedAt: time.UnixMilli(entity.CreatedAt),
 		UpdatedAt: time.UnixMilli(entity.UpdatedAt),
 	}
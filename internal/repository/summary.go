@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/cache"
+	"coca-ai/internal/repository/dao"
+	"coca-ai/pkg/logger"
+	"context"
+	"log/slog"
+	"time"
+)
+
+var ErrSummaryNotFound = dao.ErrRecordNotFound
+
+// SummaryRepository 会话摘要仓储层 (MySQL + Redis)
+type SummaryRepository struct {
+	dao   *dao.SummaryDAO
+	cache *cache.SummaryCache
+	l     *slog.Logger
+}
+
+// NewSummaryRepository 创建 SummaryRepository 实例
+func NewSummaryRepository(dao *dao.SummaryDAO, cache *cache.SummaryCache, l *slog.Logger) *SummaryRepository {
+	return &SummaryRepository{dao: dao, cache: cache, l: l.With("component", "summary_repository")}
+}
+
+// FindBySessionID 获取会话摘要 (Read-Through: 优先读缓存)
+func (r *SummaryRepository) FindBySessionID(ctx context.Context, sessionID int64) (*domain.Summary, error) {
+	if r.cache != nil {
+		cached, err := r.cache.Get(ctx, sessionID)
+		if err == nil {
+			return r.cachedToDomain(cached), nil
+		}
+	}
+
+	entity, err := r.dao.FindBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	summary := r.toDomain(entity)
+
+	if r.cache != nil {
+		if err := r.cache.Set(ctx, r.toCached(summary)); err != nil {
+			r.l.WarnContext(ctx, "warmup summary cache failed", logger.Error(err), "session_id", sessionID)
+		}
+	}
+	return summary, nil
+}
+
+// Save 保存摘要 (先写 MySQL，再更新缓存)
+func (r *SummaryRepository) Save(ctx context.Context, summary *domain.Summary) error {
+	entity := &dao.Summary{
+		SessionId:        summary.SessionID,
+		Content:          summary.Content,
+		CoveredMessageId: summary.CoveredMessageID,
+		CoveredCount:     summary.CoveredCount,
+	}
+	if err := r.dao.Upsert(ctx, entity); err != nil {
+		return err
+	}
+	summary.UpdatedAt = time.UnixMilli(entity.UpdatedAt)
+
+	if r.cache != nil {
+		if err := r.cache.Set(ctx, r.toCached(summary)); err != nil {
+			// 缓存写入失败时删除旧值，避免读到过期摘要
+			_ = r.cache.Delete(ctx, summary.SessionID)
+		}
+	}
+	return nil
+}
+
+// DeleteBySessionID 删除会话摘要
+func (r *SummaryRepository) DeleteBySessionID(ctx context.Context, sessionID int64) error {
+	if r.cache != nil {
+		_ = r.cache.Delete(ctx, sessionID)
+	}
+	return r.dao.DeleteBySessionID(ctx, sessionID)
+}
+
+func (r *SummaryRepository) toDomain(entity *dao.Summary) *domain.Summary {
+	return &domain.Summary{
+		SessionID:        entity.SessionId,
+		Content:          entity.Content,
+		CoveredMessageID: entity.CoveredMessageId,
+		CoveredCount:     entity.CoveredCount,
+		UpdatedAt:        time.UnixMilli(entity.UpdatedAt),
+	}
+}
+
+func (r *SummaryRepository) toCached(summary *domain.Summary) *cache.CachedSummary {
+	return &cache.CachedSummary{
+		SessionID:        summary.SessionID,
+		Content:          summary.Content,
+		CoveredMessageID: summary.CoveredMessageID,
+		CoveredCount:     summary.CoveredCount,
+		UpdatedAt:        summary.UpdatedAt.UnixMilli(),
+	}
+}
+
+func (r *SummaryRepository) cachedToDomain(cached *cache.CachedSummary) *domain.Summary {
+	return &domain.Summary{
+		SessionID:        cached.SessionID,
+		Content:          cached.Content,
+		CoveredMessageID: cached.CoveredMessageID,
+		CoveredCount:     cached.CoveredCount,
+		UpdatedAt:        time.UnixMilli(cached.UpdatedAt),
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository/dao"
+	"context"
+	"time"
+)
+
+// dayLayout 每日用量的日期格式
+const dayLayout = "2006-01-02"
+
+// UsageRepository 用量仓储层
+type UsageRepository struct {
+	dao *dao.UsageDAO
+}
+
+// NewUsageRepository 创建 UsageRepository 实例
+func NewUsageRepository(dao *dao.UsageDAO) *UsageRepository {
+	return &UsageRepository{dao: dao}
+}
+
+// Add 将一次模型调用的用量累加到 at 所在日期
+func (r *UsageRepository) Add(ctx context.Context, userID int64, at time.Time, promptTokens, completionTokens int) error {
+	return r.dao.Increment(ctx, userID, at.Format(dayLayout), int64(promptTokens), int64(completionTokens), 1)
+}
+
+// ListDaily 查询 [from, to] 日期区间内的每日用量，没有用量的日期不返回
+func (r *UsageRepository) ListDaily(ctx context.Context, userID int64, from, to time.Time) ([]domain.DailyUsage, error) {
+	rows, err := r.dao.FindRange(ctx, userID, from.Format(dayLayout), to.Format(dayLayout))
+	if err != nil {
+		return nil, err
+	}
+	days := make([]domain.DailyUsage, len(rows))
+	for i, row := range rows {
+		days[i] = domain.DailyUsage{
+			Day:              row.Day,
+			PromptTokens:     row.PromptTokens,
+			CompletionTokens: row.CompletionTokens,
+			Requests:         row.Requests,
+		}
+	}
+	return days, nil
+}
+
+// SumTokens 统计 [from, to] 日期区间内的总 token 数
+func (r *UsageRepository) SumTokens(ctx context.Context, userID int64, from, to time.Time) (int64, error) {
+	return r.dao.SumTokens(ctx, userID, from.Format(dayLayout), to.Format(dayLayout))
+}
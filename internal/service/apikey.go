@@ -0,0 +1,149 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound    = errors.New("API Key 不存在")
+	ErrInvalidAPIKey     = errors.New("API Key 无效、已过期或已吊销")
+	ErrInvalidScope      = errors.New("无效的权限范围")
+	ErrTooManyAPIKeys    = errors.New("API Key 数量已达上限")
+	ErrAPIKeyScopeDenied = errors.New("API Key 无权访问该接口")
+)
+
+const (
+	// MaxAPIKeysPerUser 每个用户最多持有的有效 API Key 数
+	MaxAPIKeysPerUser = 20
+	// apiKeySecretBytes API Key 随机部分的字节数
+	apiKeySecretBytes = 24
+	// apiKeyDisplayLen 展示用前缀长度 (含 coca_)
+	apiKeyDisplayLen = 12
+	// lastUsedInterval 最后使用时间的更新间隔，避免每次请求都写库
+	lastUsedInterval = time.Minute
+)
+
+// APIKeyService 个人 API Key 管理与校验
+type APIKeyService struct {
+	repo *repository.APIKeyRepository
+	l    *slog.Logger
+}
+
+// NewAPIKeyService 创建 APIKeyService 实例
+func NewAPIKeyService(repo *repository.APIKeyRepository, l *slog.Logger) *APIKeyService {
+	return &APIKeyService{repo: repo, l: l.With("component", "api_key_service")}
+}
+
+// Create 创建 API Key，返回 Key 信息与明文 (明文只在此时返回一次)
+// scopes 为空表示拥有全部权限；expiresAt 为零值表示永不过期
+func (s *APIKeyService) Create(ctx context.Context, userID int64, name string, scopes []string, expiresAt time.Time) (*domain.APIKey, string, error) {
+	if len(scopes) == 0 {
+		scopes = domain.APIKeyScopes
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	count, err := s.repo.CountActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= MaxAPIKeysPerUser {
+		return nil, "", ErrTooManyAPIKeys
+	}
+
+	buf := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := domain.APIKeyPrefix + hex.EncodeToString(buf)
+
+	key := &domain.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:apiKeyDisplayLen],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.Create(ctx, key, hashAPIKey(secret)); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// List 列出用户的所有 API Key
+func (s *APIKeyService) List(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// Rename 重命名 API Key
+func (s *APIKeyService) Rename(ctx context.Context, userID int64, id int64, name string) error {
+	err := s.repo.UpdateName(ctx, id, userID, name)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// Revoke 吊销 API Key，吊销后立即失效
+func (s *APIKeyService) Revoke(ctx context.Context, userID int64, id int64) error {
+	err := s.repo.Revoke(ctx, id, userID)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// Authenticate 校验 API Key 明文并检查权限范围，返回对应的 Key
+// 无效、过期或已吊销返回 ErrInvalidAPIKey，缺少权限返回 ErrAPIKeyScopeDenied
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string, scope string) (*domain.APIKey, error) {
+	key, err := s.repo.FindByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.Revoked() || key.Expired(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	if scope != "" && !key.HasScope(scope) {
+		return nil, ErrAPIKeyScopeDenied
+	}
+
+	if now.Sub(key.LastUsedAt) >= lastUsedInterval {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			s.l.WarnContext(ctx, "update last used failed", logger.Error(err), "key_id", key.ID)
+		}
+		key.LastUsedAt = now
+	}
+	return key, nil
+}
+
+// hashAPIKey 计算 API Key 明文的哈希
+// Key 为高熵随机串，无需加盐的慢哈希
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScope(scope string) bool {
+	for _, s := range domain.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package service
+
+import "coca-ai/internal/domain"
+
+// activePath 返回从根消息到 leafID 的消息路径 (按对话顺序)
+// leafID 为 0 或不存在时返回空路径；父消息缺失时路径从最早可达的消息开始
+// messages 需为完整消息树 (MessageRepository.FindTreeBySessionID)
+func activePath(messages []domain.Message, leafID int64) []domain.Message {
+	if leafID == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(messages))
+	for i := range messages {
+		index[messages[i].ID] = i
+	}
+
+	var path []domain.Message
+	for id := leafID; id != 0 && len(path) <= len(messages); {
+		i, ok := index[id]
+		if !ok {
+			break
+		}
+		path = append(path, messages[i])
+		id = messages[i].ParentID
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// childrenOf 返回 父消息 ID -> 子消息 ID 列表 (按时间顺序)
+func childrenOf(messages []domain.Message) map[int64][]int64 {
+	children := make(map[int64][]int64)
+	for _, msg := range messages {
+		children[msg.ParentID] = append(children[msg.ParentID], msg.ID)
+	}
+	return children
+}
+
+// fillSiblings 为路径上的消息填充同一父消息下的分支列表
+func fillSiblings(messages []domain.Message, path []domain.Message) {
+	children := childrenOf(messages)
+	for i := range path {
+		path[i].SiblingIDs = children[path[i].ParentID]
+	}
+}
+
+// latestLeaf 从 messageID 出发沿最新的子消息向下，返回该分支的末端消息 ID
+func latestLeaf(messages []domain.Message, messageID int64) int64 {
+	children := childrenOf(messages)
+	for depth := 0; depth <= len(messages); depth++ {
+		next := children[messageID]
+		if len(next) == 0 {
+			break
+		}
+		messageID = next[len(next)-1]
+	}
+	return messageID
+}
+
+// findMessage 在消息列表中查找指定 ID 的消息
+func findMessage(messages []domain.Message, id int64) (*domain.Message, bool) {
+	for i := range messages {
+		if messages[i].ID == id {
+			return &messages[i], true
+		}
+	}
+	return nil, false
+}
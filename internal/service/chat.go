@@ -5,39 +5,108 @@ import (
 	"coca-ai/internal/llm"
 	"coca-ai/internal/mq"
 	"coca-ai/internal/repository"
+	"coca-ai/internal/tool"
+	"coca-ai/pkg/logger"
+	"coca-ai/pkg/snowflake"
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 )
 
+var (
+	ErrSessionNotFound    = errors.New("会话不存在")
+	ErrSessionForbidden   = errors.New("无权访问该会话")
+	ErrModelNotSupported  = errors.New("不支持的模型")
+	ErrMessageNotFound    = errors.New("消息不存在")
+	ErrMessageNotEditable = errors.New("只能编辑用户消息")
+)
+
+// SessionStore ChatService 依赖的会话存储，由 repository.SessionRepository 实现
+// 会话不存在时 FindByID 返回 repository.ErrSessionNotFound
+type SessionStore interface {
+	Create(ctx context.Context, session *domain.Session) (*domain.Session, error)
+	FindByID(ctx context.Context, id int64) (*domain.Session, error)
+	FindByUserID(ctx context.Context, userID int64) ([]domain.Session, error)
+	UpdateTitle(ctx context.Context, id int64, title string) error
+	UpdateSettings(ctx context.Context, session *domain.Session) error
+	UpdateActiveMessage(ctx context.Context, id int64, messageID int64) error
+	Delete(ctx context.Context, id int64) error
+	TouchUpdatedAt(ctx context.Context, id int64) error
+}
+
 // ChatService 聊天核心业务服务
 type ChatService struct {
-	sessionRepo *repository.SessionRepository
+	sessionRepo SessionStore
 	messageRepo *repository.MessageRepository
 	llmClient   llm.ChatClient
-	producer    *mq.Producer
+	publisher   *mq.MessagePublisher
 	contextSvc  *ContextService
+	tools       *tool.Registry
+	summarySvc  *SummaryService
+	idGen       *snowflake.Node
+	generations *GenerationManager
+	events      *SessionEventBus
+	usageSvc    *UsageService
+	l           *slog.Logger
 }
 
 // NewChatService 创建 ChatService 实例
 func NewChatService(
-	sessionRepo *repository.SessionRepository,
+	sessionRepo SessionStore,
 	messageRepo *repository.MessageRepository,
 	llmClient llm.ChatClient,
-	producer *mq.Producer,
+	publisher *mq.MessagePublisher,
 	contextSvc *ContextService,
+	tools *tool.Registry,
+	summarySvc *SummaryService,
+	idGen *snowflake.Node,
+	generations *GenerationManager,
+	events *SessionEventBus,
+	usageSvc *UsageService,
+	l *slog.Logger,
 ) *ChatService {
 	return &ChatService{
 		sessionRepo: sessionRepo,
 		messageRepo: messageRepo,
 		llmClient:   llmClient,
-		producer:    producer,
+		publisher:   publisher,
 		contextSvc:  contextSvc,
+		tools:       tools,
+		summarySvc:  summarySvc,
+		idGen:       idGen,
+		generations: generations,
+		events:      events,
+		usageSvc:    usageSvc,
+		l:           l.With("component", "chat_service"),
 	}
 }
 
+// MaxToolSteps 单轮对话中最多执行的工具调用轮数
+const MaxToolSteps = 5
+
+// StreamEventType 流式事件类型
+type StreamEventType string
+
+const (
+	StreamEventDelta      StreamEventType = "message"     // 文本增量
+	StreamEventToolCall   StreamEventType = "tool_call"   // 开始调用工具
+	StreamEventToolResult StreamEventType = "tool_result" // 工具调用结果
+	StreamEventContext    StreamEventType = "context"     // 上下文 token 用量
+)
+
+// StreamEvent 流式响应事件
+type StreamEvent struct {
+	Type       StreamEventType
+	Delta      string
+	ToolCall   *domain.ToolCall
+	ToolResult string
+	Context    *ContextUsage
+}
+
 // StreamCallback 流式响应回调函数
-type StreamCallback func(delta string) error
+type StreamCallback func(event StreamEvent) error
 
 // CreateSession 创建新会话
 func (s *ChatService) CreateSession(ctx context.Context, userID int64) (*domain.Session, error) {
@@ -48,7 +117,12 @@ func (s *ChatService) CreateSession(ctx context.Context, userID int64) (*domain.
 		UpdatedAt: time.Now(),
 	}
 
-	return s.sessionRepo.Create(ctx, session)
+	session, err := s.sessionRepo.Create(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, domain.SessionEvent{Type: domain.SessionEventCreated, UserID: userID, SessionID: session.ID, Title: session.Title})
+	return session, nil
 }
 
 // GetSessionList 获取用户的会话列表
@@ -56,100 +130,614 @@ func (s *ChatService) GetSessionList(ctx context.Context, userID int64) ([]domai
 	return s.sessionRepo.FindByUserID(ctx, userID)
 }
 
-// GetMessages 获取会话的历史消息
-func (s *ChatService) GetMessages(ctx context.Context, sessionID int64) ([]domain.Message, error) {
-	return s.messageRepo.FindBySessionID(ctx, sessionID)
+// GetSession 获取会话，并校验会话归属
+// 会话不存在返回 ErrSessionNotFound，不属于该用户返回 ErrSessionForbidden
+func (s *ChatService) GetSession(ctx context.Context, userID int64, sessionID int64) (*domain.Session, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrSessionForbidden
+	}
+	return session, nil
+}
+
+// GetMessages 获取会话当前分支的历史消息
+// 尚未启用分支的会话按时间顺序返回全部消息
+func (s *ChatService) GetMessages(ctx context.Context, userID int64, sessionID int64) ([]domain.Message, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.messageRepo.FindTreeBySessionID(ctx, sessionID)
+	if err != nil || session.ActiveMessageID == 0 {
+		return messages, err
+	}
+	path := activePath(messages, session.ActiveMessageID)
+	fillSiblings(messages, path)
+	return path, nil
 }
 
 // DeleteSession 删除会话
-func (s *ChatService) DeleteSession(ctx context.Context, sessionID int64) error {
-	// 先删除消息
+func (s *ChatService) DeleteSession(ctx context.Context, userID int64, sessionID int64) error {
+	if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	// 先删除消息和摘要
 	if err := s.messageRepo.DeleteBySessionID(ctx, sessionID); err != nil {
 		return err
 	}
+	if err := s.summarySvc.DeleteSummary(ctx, sessionID); err != nil {
+		return err
+	}
 	// 再删除会话
-	return s.sessionRepo.Delete(ctx, sessionID)
+	if err := s.sessionRepo.Delete(ctx, sessionID); err != nil {
+		return err
+	}
+	s.publish(ctx, domain.SessionEvent{Type: domain.SessionEventDeleted, UserID: userID, SessionID: sessionID})
+	return nil
 }
 
 // SendMessage 发送消息并流式返回 AI 回复
-func (s *ChatService) SendMessage(ctx context.Context, sessionID int64, content string, callback StreamCallback) (*domain.Message, error) {
-	// 1. 创建用户消息
+// 新消息追加在当前分支末端；模型请求调用工具时，执行工具并将结果回传给模型，最多循环 MaxToolSteps 轮
+func (s *ChatService) SendMessage(ctx context.Context, userID int64, sessionID int64, content string, callback StreamCallback) (*domain.Message, error) {
+	// 0. 校验会话归属
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// 配额已用完时不保存用户消息，直接拒绝
+	if err := s.usageSvc.CheckQuota(ctx, userID); err != nil {
+		return nil, err
+	}
+	count, _ := s.messageRepo.CountBySessionID(ctx, sessionID)
+	parentID, err := s.currentLeaf(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 创建用户消息，写入 Redis 缓存 (热数据) 并发送到 Kafka (异步落库)
 	userMsg := &domain.Message{
 		SessionID: sessionID,
+		ParentID:  parentID,
 		Role:      domain.RoleUser,
 		Content:   content,
 		CreatedAt: time.Now(),
 	}
+	s.saveMessage(ctx, userMsg)
+	s.setActiveMessage(ctx, session, userMsg.ID)
+
+	// 2. 生成 AI 回复
+	assistantMsg, err := s.reply(ctx, session, userMsg, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 如果是第一条消息，自动生成会话标题
+	if count == 0 {
+		s.autoTitle(ctx, userID, sessionID, content)
+	}
+
+	return assistantMsg, nil
+}
+
+// CheckQuota 校验用户本月 token 配额，已用完时返回 ErrQuotaExceeded
+func (s *ChatService) CheckQuota(ctx context.Context, userID int64) error {
+	return s.usageSvc.CheckQuota(ctx, userID)
+}
+
+// RecordCompletion 将外部发起的一轮补全 (如 OpenAI 兼容接口) 追加到会话当前分支
+// userContent 为空时只记录回复；回复包含工具调用时记录为工具调用消息
+func (s *ChatService) RecordCompletion(ctx context.Context, userID int64, sessionID int64, userContent string, reply *llm.Message) (*domain.Message, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	count, _ := s.messageRepo.CountBySessionID(ctx, sessionID)
+	parentID, err := s.currentLeaf(ctx, session)
+	if err != nil {
+		return nil, err
+	}
 
-	// 2. 写入 Redis 缓存 (热数据)
-	if err := s.messageRepo.AppendToCache(ctx, userMsg); err != nil {
-		log.Printf("[ChatService] append user message cache failed: %v", err)
+	if userContent != "" {
+		userMsg := &domain.Message{
+			SessionID: sessionID,
+			ParentID:  parentID,
+			Role:      domain.RoleUser,
+			Content:   userContent,
+			CreatedAt: time.Now(),
+		}
+		s.saveMessage(ctx, userMsg)
+		parentID = userMsg.ID
 	}
 
-	// 3. 发送到 Kafka (异步落库)
-	if s.producer != nil {
-		if err := s.producer.SendMessage(ctx, mq.DomainToEvent(userMsg)); err != nil {
-			log.Printf("[ChatService] send user message to kafka failed: %v", err)
+	replyMsg := &domain.Message{
+		SessionID:        sessionID,
+		ParentID:         parentID,
+		Role:             domain.RoleAssistant,
+		Content:          reply.Content,
+		FinishReason:     reply.FinishReason,
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		Provider:         reply.Provider,
+		Model:            reply.Model,
+		CreatedAt:        time.Now(),
+	}
+	if len(reply.ToolCalls) > 0 {
+		replyMsg.Role = domain.RoleToolCall
+		for _, call := range reply.ToolCalls {
+			replyMsg.ToolCalls = append(replyMsg.ToolCalls, domain.ToolCall{
+				ID:        call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			})
 		}
 	}
+	s.saveMessage(ctx, replyMsg)
+	s.setActiveMessage(ctx, session, replyMsg.ID)
+	_ = s.sessionRepo.TouchUpdatedAt(ctx, sessionID)
 
-	// 4. 构建 LLM 上下文
-	llmMessages, err := s.contextSvc.BuildContext(ctx, sessionID, content)
+	if count == 0 && userContent != "" {
+		s.autoTitle(ctx, userID, sessionID, userContent)
+	}
+	return replyMsg, nil
+}
+
+// autoTitle 用用户第一条消息作为会话标题（截取前 50 字符）
+func (s *ChatService) autoTitle(ctx context.Context, userID int64, sessionID int64, content string) {
+	title := content
+	if len(title) > 50 {
+		title = title[:50] + "..."
+	}
+	if err := s.sessionRepo.UpdateTitle(ctx, sessionID, title); err == nil {
+		s.publish(ctx, domain.SessionEvent{Type: domain.SessionEventTitleChanged, UserID: userID, SessionID: sessionID, Title: title})
+	}
+}
+
+// RegenerateMessage 重新生成回复
+// messageID 为要重新生成的消息，0 表示当前分支的最后一条；
+// 以其最近的用户消息为父消息生成新回复，新回复与原回复互为兄弟分支并成为当前分支
+func (s *ChatService) RegenerateMessage(ctx context.Context, userID int64, sessionID int64, messageID int64, callback StreamCallback) (*domain.Message, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.usageSvc.CheckQuota(ctx, userID); err != nil {
+		return nil, err
+	}
+	leaf, err := s.currentLeaf(ctx, session)
 	if err != nil {
 		return nil, err
 	}
+	if messageID == 0 {
+		messageID = leaf
+	}
+
+	messages, err := s.messageRepo.FindTreeBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := findMessage(messages, messageID)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
 
-	// 5. 调用 LLM 流式接口
-	var fullResponse string
-	err = s.llmClient.StreamChat(ctx, llmMessages, func(delta string) error {
-		fullResponse += delta
-		if callback != nil {
-			return callback(delta)
+	// 向上找到触发该回复的用户消息
+	path := activePath(messages, target.ID)
+	var userMsg *domain.Message
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].IsUser() {
+			userMsg = &path[i]
+			break
 		}
-		return nil
-	})
+	}
+	if userMsg == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	s.setActiveMessage(ctx, session, userMsg.ID)
+	return s.reply(ctx, session, userMsg, callback)
+}
+
+// EditMessage 编辑用户消息并重新发送
+// 编辑后的消息作为原消息的兄弟分支保存，原分支保留，可通过 SwitchBranch 切回
+func (s *ChatService) EditMessage(ctx context.Context, userID int64, sessionID int64, messageID int64, content string, callback StreamCallback) (*domain.Message, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.usageSvc.CheckQuota(ctx, userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.currentLeaf(ctx, session); err != nil {
+		return nil, err
+	}
 
-	// 6. 创建 AI 回复消息
-	assistantMsg := &domain.Message{
+	messages, err := s.messageRepo.FindTreeBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	original, ok := findMessage(messages, messageID)
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	if !original.IsUser() {
+		return nil, ErrMessageNotEditable
+	}
+
+	userMsg := &domain.Message{
 		SessionID: sessionID,
-		Role:      domain.RoleAssistant,
-		Content:   fullResponse,
+		ParentID:  original.ParentID,
+		Role:      domain.RoleUser,
+		Content:   content,
 		CreatedAt: time.Now(),
 	}
+	s.saveMessage(ctx, userMsg)
+	s.setActiveMessage(ctx, session, userMsg.ID)
 
-	// 7. 写入 Redis 缓存
-	if err := s.messageRepo.AppendToCache(ctx, assistantMsg); err != nil {
-		log.Printf("[ChatService] append assistant message cache failed: %v", err)
+	return s.reply(ctx, session, userMsg, callback)
+}
+
+// SwitchBranch 切换当前分支
+// 切换到 messageID 所在分支，并沿最新的子消息定位到该分支末端，返回切换后的消息路径
+func (s *ChatService) SwitchBranch(ctx context.Context, userID int64, sessionID int64, messageID int64) ([]domain.Message, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.currentLeaf(ctx, session); err != nil {
+		return nil, err
 	}
 
-	// 8. 发送到 Kafka (异步落库)
-	if s.producer != nil {
-		if err := s.producer.SendMessage(ctx, mq.DomainToEvent(assistantMsg)); err != nil {
-			log.Printf("[ChatService] send assistant message to kafka failed: %v", err)
-		}
+	messages, err := s.messageRepo.FindTreeBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findMessage(messages, messageID); !ok {
+		return nil, ErrMessageNotFound
 	}
 
-	// 9. 更新会话的 updated_at
-	_ = s.sessionRepo.TouchUpdatedAt(ctx, sessionID)
+	leaf := latestLeaf(messages, messageID)
+	if err := s.sessionRepo.UpdateActiveMessage(ctx, sessionID, leaf); err != nil {
+		return nil, err
+	}
 
-	// 10. 如果是第一条消息，自动生成会话标题
-	count, _ := s.messageRepo.CountBySessionID(ctx, sessionID)
-	if count <= 2 {
-		// 用用户第一条消息作为标题（截取前 50 字符）
-		title := content
-		if len(title) > 50 {
-			title = title[:50] + "..."
+	path := activePath(messages, leaf)
+	fillSiblings(messages, path)
+	return path, nil
+}
+
+// reply 基于用户消息所在的分支路径生成 AI 回复
+// 工具调用、工具结果与最终回复依次挂在 userMsg 之下，并逐条推进当前分支
+func (s *ChatService) reply(ctx context.Context, session *domain.Session, userMsg *domain.Message, callback StreamCallback) (*domain.Message, error) {
+	if callback == nil {
+		callback = func(StreamEvent) error { return nil }
+	}
+
+	// 1. 构建 LLM 上下文 (含知识库检索)
+	built, err := s.contextSvc.BuildContext(ctx, session, userMsg.ParentID, userMsg.Content)
+	if err != nil {
+		return nil, err
+	}
+	llmMessages := built.Messages
+	if err := callback(StreamEvent{Type: StreamEventContext, Context: &built.Usage}); err != nil {
+		return nil, err
+	}
+
+	// 2. 调用 LLM 流式接口，处理工具调用
+	// 生成可被 StopGeneration 或客户端断开取消，取消时保存已生成的部分回复
+	genCtx, release := s.generations.Begin(ctx, session.ID)
+	defer release()
+	opts := sessionOptions(session)
+	tools := s.tools.Definitions()
+	parentID := userMsg.ID
+	var reply *llm.Message
+	for step := 0; ; step++ {
+		stepOpts := opts
+		// 达到最大轮数后不再提供工具，强制模型给出最终回复
+		if len(tools) > 0 && step < MaxToolSteps {
+			stepOpts = append(stepOpts, llm.WithTools(tools))
+		}
+
+		var partial strings.Builder
+		reply, err = s.llmClient.StreamChat(genCtx, llmMessages, func(delta string) error {
+			partial.WriteString(delta)
+			return callback(StreamEvent{Type: StreamEventDelta, Delta: delta})
+		}, stepOpts...)
+		if err != nil && genCtx.Err() != nil {
+			// 生成被取消：与正常结束一样落库，客户端已断开时不再依赖请求 ctx
+			// Provider 不会返回被取消调用的用量，按已生成的内容估算
+			ctx = context.WithoutCancel(ctx)
+			model := resolveModel(s.llmClient, opts)
+			reply = &llm.Message{Role: "assistant", Content: partial.String(), FinishReason: domain.FinishReasonCancelled,
+				Usage: llm.EstimateUsage(model, llmMessages, partial.String()), Model: model}
+			s.usageSvc.Record(ctx, session.UserID, reply.Usage)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.usageSvc.Record(ctx, session.UserID, reply.Usage)
+		if len(reply.ToolCalls) == 0 {
+			break
 		}
-		_ = s.sessionRepo.UpdateTitle(ctx, sessionID, title)
+
+		llmMessages = append(llmMessages, *reply)
+		var results []llm.Message
+		results, parentID, err = s.callTools(genCtx, session, parentID, reply, callback)
+		if genCtx.Err() != nil {
+			// 工具执行期间生成被取消：与流式输出被取消一样，在最后一条工具消息之后保存空的部分回复
+			ctx = context.WithoutCancel(ctx)
+			reply = &llm.Message{Role: "assistant", FinishReason: domain.FinishReasonCancelled, Model: resolveModel(s.llmClient, opts)}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		llmMessages = append(llmMessages, results...)
+	}
+
+	// 3. 创建 AI 回复消息，写入 Redis 缓存并发送到 Kafka
+	assistantMsg := &domain.Message{
+		SessionID:        session.ID,
+		ParentID:         parentID,
+		Role:             domain.RoleAssistant,
+		Content:          reply.Content,
+		FinishReason:     reply.FinishReason,
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		Provider:         reply.Provider,
+		Model:            reply.Model,
+		Citations:        built.Citations,
+		CreatedAt:        time.Now(),
 	}
+	s.saveMessage(ctx, assistantMsg)
+	s.setActiveMessage(ctx, session, assistantMsg.ID)
+
+	// 4. 更新会话的 updated_at
+	_ = s.sessionRepo.TouchUpdatedAt(ctx, session.ID)
 
 	return assistantMsg, nil
 }
 
+// callTools 持久化工具调用消息，依次执行工具并持久化结果
+// 返回回传给模型的 tool 消息，以及最后一条持久化消息的 ID (作为后续消息的父消息)
+// ctx 为生成的 ctx：取消后不再执行后续工具，已产生的消息仍然落库
+func (s *ChatService) callTools(ctx context.Context, session *domain.Session, parentID int64, reply *llm.Message, callback StreamCallback) ([]llm.Message, int64, error) {
+	saveCtx := context.WithoutCancel(ctx)
+	callMsg := &domain.Message{
+		SessionID:        session.ID,
+		ParentID:         parentID,
+		Role:             domain.RoleToolCall,
+		Content:          reply.Content,
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		Provider:         reply.Provider,
+		Model:            reply.Model,
+		CreatedAt:        time.Now(),
+	}
+	for _, call := range reply.ToolCalls {
+		callMsg.ToolCalls = append(callMsg.ToolCalls, domain.ToolCall{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
+	}
+	s.saveMessage(saveCtx, callMsg)
+	s.setActiveMessage(saveCtx, session, callMsg.ID)
+	parentID = callMsg.ID
+
+	results := make([]llm.Message, 0, len(callMsg.ToolCalls))
+	for i := range callMsg.ToolCalls {
+		if err := ctx.Err(); err != nil {
+			return nil, parentID, err
+		}
+		call := &callMsg.ToolCalls[i]
+		if err := callback(StreamEvent{Type: StreamEventToolCall, ToolCall: call}); err != nil {
+			return nil, parentID, err
+		}
+
+		// 工具执行失败时将错误信息回传给模型，由模型决定如何处理；生成被取消时不保存中断的结果
+		result, err := s.tools.Call(ctx, call.Name, call.Arguments)
+		if err != nil && ctx.Err() != nil {
+			return nil, parentID, ctx.Err()
+		}
+		if err != nil {
+			s.l.WarnContext(ctx, "call tool failed", logger.Error(err), "tool", call.Name)
+			result = "error: " + err.Error()
+		}
+
+		resultMsg := &domain.Message{
+			SessionID:  session.ID,
+			ParentID:   parentID,
+			Role:       domain.RoleTool,
+			Content:    result,
+			ToolCallID: call.ID,
+			CreatedAt:  time.Now(),
+		}
+		s.saveMessage(saveCtx, resultMsg)
+		s.setActiveMessage(saveCtx, session, resultMsg.ID)
+		parentID = resultMsg.ID
+
+		if err := callback(StreamEvent{Type: StreamEventToolResult, ToolCall: call, ToolResult: result}); err != nil {
+			return nil, parentID, err
+		}
+		results = append(results, llm.Message{
+			Role:       "tool",
+			Content:    result,
+			ToolCallID: call.ID,
+		})
+	}
+	return results, parentID, nil
+}
+
+// StopGeneration 停止会话正在进行的生成
+// 生成可能在其他实例上进行，停止请求通过 Redis 广播；已生成的部分回复会以 cancelled 结束原因保存
+func (s *ChatService) StopGeneration(ctx context.Context, userID int64, sessionID int64) error {
+	if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	_, err := s.generations.Stop(ctx, sessionID)
+	return err
+}
+
+// currentLeaf 返回会话当前分支的末端消息 ID
+// 尚未启用分支的旧会话在首次使用时按时间顺序补全父消息，末端为最后一条消息
+func (s *ChatService) currentLeaf(ctx context.Context, session *domain.Session) (int64, error) {
+	if session.ActiveMessageID != 0 {
+		return session.ActiveMessageID, nil
+	}
+	messages, err := s.messageRepo.LinkSequential(ctx, session.ID)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	leaf := messages[len(messages)-1].ID
+	s.setActiveMessage(ctx, session, leaf)
+	return leaf, nil
+}
+
+// setActiveMessage 推进会话当前分支的末端消息
+func (s *ChatService) setActiveMessage(ctx context.Context, session *domain.Session, messageID int64) {
+	session.ActiveMessageID = messageID
+	if err := s.sessionRepo.UpdateActiveMessage(ctx, session.ID, messageID); err != nil {
+		s.l.ErrorContext(ctx, "update active message failed", logger.Error(err), "session_id", session.ID, "message_id", messageID)
+	}
+}
+
+// saveMessage 分配消息 ID，写入 Redis 缓存 (热数据) 并发布消息事件落库
+// ID 在发布前生成，缓存与 MySQL 中的同一条消息 ID 一致；Kafka 不可用时事件暂存于发件箱
+func (s *ChatService) saveMessage(ctx context.Context, msg *domain.Message) {
+	if msg.ID == 0 {
+		msg.ID = s.idGen.Generate()
+	}
+	if err := s.messageRepo.AppendToCache(ctx, msg); err != nil {
+		s.l.WarnContext(ctx, "append message cache failed", logger.Error(err), "session_id", msg.SessionID, "role", msg.Role)
+	}
+	if err := s.publisher.Publish(ctx, mq.DomainToEvent(msg)); err != nil {
+		s.l.ErrorContext(ctx, "publish message failed", logger.Error(err), "session_id", msg.SessionID, "message_id", msg.ID)
+	}
+}
+
+// GetSummary 获取会话摘要 (模型"记住"的早期对话)，尚未生成时返回 nil
+func (s *ChatService) GetSummary(ctx context.Context, userID int64, sessionID int64) (*domain.Summary, error) {
+	if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+		return nil, err
+	}
+	return s.summarySvc.GetSummary(ctx, sessionID)
+}
+
 // UpdateSessionTitle 更新会话标题
-func (s *ChatService) UpdateSessionTitle(ctx context.Context, sessionID int64, title string) error {
-	return s.sessionRepo.UpdateTitle(ctx, sessionID, title)
+func (s *ChatService) UpdateSessionTitle(ctx context.Context, userID int64, sessionID int64, title string) error {
+	if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.UpdateTitle(ctx, sessionID, title); err != nil {
+		return err
+	}
+	s.publish(ctx, domain.SessionEvent{Type: domain.SessionEventTitleChanged, UserID: userID, SessionID: sessionID, Title: title})
+	return nil
+}
+
+// NotifyTyping 通知用户的其他设备该会话正在输入，origin 为发起通知的连接
+func (s *ChatService) NotifyTyping(ctx context.Context, userID int64, sessionID int64, origin string) error {
+	if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	s.publish(ctx, domain.SessionEvent{Type: domain.SessionEventTyping, UserID: userID, SessionID: sessionID, Origin: origin})
+	return nil
+}
+
+// publish 发布会话事件，推送到该用户在线的各个设备
+func (s *ChatService) publish(ctx context.Context, event domain.SessionEvent) {
+	if s.events != nil {
+		s.events.Publish(ctx, event)
+	}
+}
+
+// ListModels 返回当前 Provider 可用的模型列表
+func (s *ChatService) ListModels() []string {
+	if lister, ok := s.llmClient.(llm.ModelLister); ok {
+		return lister.Models()
+	}
+	return nil
+}
+
+// UpdateSessionSettings 更新会话的模型与生成参数
+// settings 中仅生成参数字段生效，整体覆盖原有设置
+func (s *ChatService) UpdateSessionSettings(ctx context.Context, userID int64, sessionID int64, settings domain.Session) (*domain.Session, error) {
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Model != "" && !supportsModel(s.llmClient, settings.Model) {
+		return nil, ErrModelNotSupported
+	}
+
+	session.Model = settings.Model
+	session.Temperature = settings.Temperature
+	session.TopP = settings.TopP
+	session.MaxTokens = settings.MaxTokens
+	session.Stop = settings.Stop
+	if err := s.sessionRepo.UpdateSettings(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// supportsModel 判断 Provider 是否支持该模型
+func supportsModel(client llm.ChatClient, model string) bool {
+	lister, ok := client.(llm.ModelLister)
+	if !ok {
+		return true
+	}
+	models := lister.Models()
+	if models == nil {
+		// 无法获取模型列表时不做限制
+		return true
+	}
+	for _, m := range models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveModel 返回本次调用实际使用的模型，未指定时为客户端的默认模型
+func resolveModel(client llm.ChatClient, opts []llm.Option) string {
+	if model := llm.ApplyOptions(opts).Model; model != "" {
+		return model
+	}
+	if lister, ok := client.(llm.ModelLister); ok {
+		return lister.DefaultModel()
+	}
+	return ""
+}
+
+// sessionOptions 将会话的生成参数转换为 LLM 调用参数
+func sessionOptions(session *domain.Session) []llm.Option {
+	var opts []llm.Option
+	if session.Model != "" {
+		opts = append(opts, llm.WithModel(session.Model))
+	}
+	if session.Temperature != nil {
+		opts = append(opts, llm.WithTemperature(*session.Temperature))
+	}
+	if session.TopP != nil {
+		opts = append(opts, llm.WithTopP(*session.TopP))
+	}
+	if session.MaxTokens != nil {
+		opts = append(opts, llm.WithMaxTokens(*session.MaxTokens))
+	}
+	if len(session.Stop) > 0 {
+		opts = append(opts, llm.WithStop(session.Stop))
+	}
+	return opts
 }
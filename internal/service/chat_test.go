@@ -0,0 +1,130 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/service/servicetest"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// newTestChatService 创建仅依赖会话存储的 ChatService，越权请求在访问其他依赖前即被拒绝
+func newTestChatService(store SessionStore) *ChatService {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewChatService(store, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, l)
+}
+
+const (
+	ownerID     = servicetest.OwnerID
+	otherUserID = servicetest.OtherUserID
+	sessionID   = servicetest.SessionID
+	missingID   = servicetest.MissingID
+
+	anyMessageID int64 = 100
+)
+
+func TestChatService_GetSession(t *testing.T) {
+	svc := newTestChatService(servicetest.NewSessionStore(domain.Session{ID: sessionID, UserID: ownerID}))
+
+	tests := []struct {
+		name      string
+		userID    int64
+		sessionID int64
+		wantErr   error
+	}{
+		{name: "owner", userID: ownerID, sessionID: sessionID},
+		{name: "other user", userID: otherUserID, sessionID: sessionID, wantErr: ErrSessionForbidden},
+		{name: "missing session", userID: ownerID, sessionID: missingID, wantErr: ErrSessionNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			session, err := svc.GetSession(context.Background(), tc.userID, tc.sessionID)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("GetSession() error = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && session.ID != tc.sessionID {
+				t.Fatalf("GetSession() session ID = %d, want %d", session.ID, tc.sessionID)
+			}
+			if tc.wantErr != nil && session != nil {
+				t.Fatalf("GetSession() returned session %+v on error", session)
+			}
+		})
+	}
+}
+
+// TestChatService_RejectsForeignSession 每个按会话操作的方法都校验会话归属
+func TestChatService_RejectsForeignSession(t *testing.T) {
+	ops := map[string]func(svc *ChatService, userID, sessionID int64) error{
+		"GetMessages": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.GetMessages(context.Background(), userID, sessionID)
+			return err
+		},
+		"DeleteSession": func(svc *ChatService, userID, sessionID int64) error {
+			return svc.DeleteSession(context.Background(), userID, sessionID)
+		},
+		"SendMessage": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.SendMessage(context.Background(), userID, sessionID, "hello", nil)
+			return err
+		},
+		"RegenerateMessage": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.RegenerateMessage(context.Background(), userID, sessionID, 0, nil)
+			return err
+		},
+		"EditMessage": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.EditMessage(context.Background(), userID, sessionID, anyMessageID, "hello", nil)
+			return err
+		},
+		"SwitchBranch": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.SwitchBranch(context.Background(), userID, sessionID, anyMessageID)
+			return err
+		},
+		"StopGeneration": func(svc *ChatService, userID, sessionID int64) error {
+			return svc.StopGeneration(context.Background(), userID, sessionID)
+		},
+		"GetSummary": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.GetSummary(context.Background(), userID, sessionID)
+			return err
+		},
+		"UpdateSessionTitle": func(svc *ChatService, userID, sessionID int64) error {
+			return svc.UpdateSessionTitle(context.Background(), userID, sessionID, "title")
+		},
+		"UpdateSessionSettings": func(svc *ChatService, userID, sessionID int64) error {
+			_, err := svc.UpdateSessionSettings(context.Background(), userID, sessionID, domain.Session{})
+			return err
+		},
+	}
+
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			store := servicetest.NewSessionStore(domain.Session{ID: sessionID, UserID: ownerID})
+			svc := newTestChatService(store)
+
+			if err := op(svc, otherUserID, sessionID); !errors.Is(err, ErrSessionForbidden) {
+				t.Errorf("other user: error = %v, want %v", err, ErrSessionForbidden)
+			}
+			if err := op(svc, ownerID, missingID); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("missing session: error = %v, want %v", err, ErrSessionNotFound)
+			}
+			if store.Writes != 0 {
+				t.Errorf("session store modified %d times by rejected requests", store.Writes)
+			}
+		})
+	}
+}
+
+func TestChatService_GetSessionListOnlyOwnSessions(t *testing.T) {
+	svc := newTestChatService(servicetest.NewSessionStore(
+		domain.Session{ID: sessionID, UserID: ownerID},
+		domain.Session{ID: sessionID + 1, UserID: otherUserID},
+	))
+
+	sessions, err := svc.GetSessionList(context.Background(), otherUserID)
+	if err != nil {
+		t.Fatalf("GetSessionList() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != sessionID+1 {
+		t.Fatalf("GetSessionList() = %+v, want only session %d", sessions, sessionID+1)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/llm"
+	"coca-ai/pkg/logger"
+	"context"
+	"log/slog"
+)
+
+// CompletionRequest 无状态补全请求 (OpenAI Chat Completions 语义)
+type CompletionRequest struct {
+	UserID int64
+	// SessionID 绑定的会话，非 0 时本轮问答会追加到该会话的历史中
+	SessionID int64
+	Messages  []llm.Message
+	Options   []llm.Option
+}
+
+// CompletionService OpenAI 兼容接口的补全服务
+// 由调用方提供完整上下文，直接转发给 LLM，不使用会话历史、知识库与内置工具
+type CompletionService struct {
+	llmClient llm.ChatClient
+	chatSvc   *ChatService
+	usageSvc  *UsageService
+	l         *slog.Logger
+}
+
+// NewCompletionService 创建 CompletionService 实例
+func NewCompletionService(llmClient llm.ChatClient, chatSvc *ChatService, usageSvc *UsageService, l *slog.Logger) *CompletionService {
+	return &CompletionService{llmClient: llmClient, chatSvc: chatSvc, usageSvc: usageSvc, l: l.With("component", "completion_service")}
+}
+
+// Prepare 校验请求并补全默认模型，返回实际使用的模型
+// 绑定会话时校验会话归属；本月配额已用完时返回 ErrQuotaExceeded
+func (s *CompletionService) Prepare(ctx context.Context, req *CompletionRequest) (string, error) {
+	if model := llm.ApplyOptions(req.Options).Model; model != "" && !supportsModel(s.llmClient, model) {
+		return "", ErrModelNotSupported
+	}
+	if req.SessionID != 0 {
+		if _, err := s.chatSvc.GetSession(ctx, req.UserID, req.SessionID); err != nil {
+			return "", err
+		}
+	}
+	if err := s.usageSvc.CheckQuota(ctx, req.UserID); err != nil {
+		return "", err
+	}
+	return resolveModel(s.llmClient, req.Options), nil
+}
+
+// Complete 调用 LLM 生成回复，callback 为 nil 时不输出增量
+// 绑定会话时将最后一条用户消息与回复写入会话 (Redis 缓存 + Kafka 异步落库)
+func (s *CompletionService) Complete(ctx context.Context, req *CompletionRequest, callback llm.StreamCallback) (*llm.Message, error) {
+	if callback == nil {
+		callback = func(string) error { return nil }
+	}
+	reply, err := s.llmClient.StreamChat(ctx, req.Messages, callback, req.Options...)
+	if err != nil {
+		return nil, err
+	}
+	s.usageSvc.Record(ctx, req.UserID, reply.Usage)
+
+	if req.SessionID != 0 {
+		var userContent string
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == string(domain.RoleUser) {
+			userContent = req.Messages[n-1].Content
+		}
+		if _, err := s.chatSvc.RecordCompletion(ctx, req.UserID, req.SessionID, userContent, reply); err != nil {
+			s.l.ErrorContext(ctx, "record completion failed", logger.Error(err), "session_id", req.SessionID)
+		}
+	}
+	return reply, nil
+}
+
+// ListModels 返回可用模型列表
+func (s *CompletionService) ListModels() []string {
+	return s.chatSvc.ListModels()
+}
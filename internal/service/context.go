@@ -1,119 +1,340 @@
 package service
 
 import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/domain"
 	"coca-ai/internal/llm"
 	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 )
 
 const (
-	// 最大上下文消息数 (不包括系统提示和当前输入)
-	MaxContextMessages = 20
-	// 触发摘要压缩的阈值
-	SummaryThreshold = 20
+	// MaxHistoryMessages 构建上下文时读取的候选历史消息上限
+	MaxHistoryMessages = 200
+	// DefaultContextWindow 未知模型的默认上下文窗口 (token)
+	DefaultContextWindow = 8192
+	// DefaultReservedOutput 默认为模型输出预留的 token 数
+	DefaultReservedOutput = 1024
+	// MaxSummaryTokens 历史摘要最多占用的 token 数
+	MaxSummaryTokens = 1024
 )
 
 // ContextService 上下文构建服务
 type ContextService struct {
-	messageRepo  *repository.MessageRepository
-	llmClient    llm.ChatClient
-	systemPrompt string
+	messageRepo    *repository.MessageRepository
+	llmClient      llm.ChatClient
+	documentSvc    *DocumentService
+	summarySvc     *SummaryService
+	systemPrompt   string
+	contextWindow  int
+	contextWindows map[string]int
+	reservedOutput int
+	l              *slog.Logger
+}
+
+// ContextUsage 上下文各部分的 token 用量
+type ContextUsage struct {
+	Model           string `json:"model"`
+	Window          int    `json:"window"`           // 模型上下文窗口
+	Budget          int    `json:"budget"`           // 扣除输出预留后的输入预算
+	System          int    `json:"system"`           // 系统提示
+	Summary         int    `json:"summary"`          // 历史摘要
+	Retrieval       int    `json:"retrieval"`        // 检索资料
+	History         int    `json:"history"`          // 历史消息
+	Input           int    `json:"input"`            // 当前用户输入
+	Total           int    `json:"total"`            // 合计
+	HistoryMessages int    `json:"history_messages"` // 纳入上下文的历史消息数
+	DroppedMessages int    `json:"dropped_messages"` // 因预算不足未纳入的历史消息数
+	Truncated       bool   `json:"truncated"`        // 是否有内容被截断
+}
+
+// ContextResult 上下文构建结果
+type ContextResult struct {
+	Messages  []llm.Message
+	Citations []domain.Citation // 注入上下文的检索资料
+	Usage     ContextUsage
 }
 
 // NewContextService 创建 ContextService 实例
 func NewContextService(
 	messageRepo *repository.MessageRepository,
 	llmClient llm.ChatClient,
+	documentSvc *DocumentService,
+	summarySvc *SummaryService,
+	l *slog.Logger,
 ) *ContextService {
+	cfg := config.Get()
+	window := cfg.LLM.ContextWindow
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	reserved := cfg.LLM.ReservedOutput
+	if reserved <= 0 {
+		reserved = DefaultReservedOutput
+	}
 	return &ContextService{
 		messageRepo: messageRepo,
 		llmClient:   llmClient,
+		documentSvc: documentSvc,
+		summarySvc:  summarySvc,
 		systemPrompt: `你是 Coca AI，一个友好、专业的 AI 助手。
 						你可以帮助用户解答问题、提供建议、进行对话。
 						请使用简洁、清晰的语言回复用户。
 						如果不确定答案，请诚实地表明。`,
+		contextWindow:  window,
+		contextWindows: cfg.LLM.ContextWindows,
+		reservedOutput: reserved,
+		l:              l.With("component", "context_service"),
 	}
 }
 
 // BuildContext 构建 LLM 对话上下文
-// 返回格式: [System Prompt, (可选)历史摘要, 最近消息..., 用户输入]
-func (s *ContextService) BuildContext(ctx context.Context, sessionID int64, userInput string) ([]llm.Message, error) {
-	// 1. 获取历史消息数量
-	totalCount, err := s.messageRepo.CountBySessionID(ctx, sessionID)
-	if err != nil {
-		totalCount = 0
-	}
-
-	var messages []llm.Message
-
-	// 2. 添加 System Prompt
-	messages = append(messages, llm.Message{
-		Role:    "system",
-		Content: s.systemPrompt,
-	})
-
-	// 3. 如果历史消息较多，生成摘要
-	if totalCount > SummaryThreshold {
-		summary, err := s.generateSummary(ctx, sessionID)
-		if err == nil && summary != "" {
-			messages = append(messages, llm.Message{
+// 历史消息为从根消息到 parentID 的当前分支路径，parentID 为 0 表示没有历史
+// 返回格式: [System Prompt, (可选)历史摘要, (可选)检索资料, 最近消息..., 用户输入]
+// 按模型上下文窗口扣除输出预留后的 token 预算打包：
+// 系统提示与用户输入必选 (输入过长时截断)，检索资料最多占剩余预算的 1/4，
+// 其余由新到旧装入历史消息，放不下的早期消息由持久化摘要替代 (摘要在后台增量前移)
+func (s *ContextService) BuildContext(ctx context.Context, session *domain.Session, parentID int64, userInput string) (*ContextResult, error) {
+	model := s.resolveModel(session.Model)
+	tk := llm.TokenizerFor(model)
+	usage := ContextUsage{Model: model, Window: s.windowOf(model)}
+	usage.Budget = s.budgetOf(usage.Window, session.MaxTokens)
+
+	// 1. System Prompt
+	system := llm.Message{Role: "system", Content: s.systemPrompt}
+	usage.System = llm.CountMessage(tk, system)
+
+	// 2. 当前用户输入，最多占用一半预算
+	input := llm.Message{Role: "user", Content: userInput}
+	if limit := usage.Budget / 2; llm.CountMessage(tk, input) > limit {
+		input.Content = llm.Truncate(tk, userInput, limit-llm.MessageOverheadTokens)
+		usage.Truncated = true
+	}
+	usage.Input = llm.CountMessage(tk, input)
+	remaining := usage.Budget - usage.System - usage.Input
+
+	// 3. 检索用户知识库，按相关度装入资料
+	var citations []domain.Citation
+	var retrieval *llm.Message
+	if s.documentSvc != nil {
+		found, err := s.documentSvc.Retrieve(ctx, session.UserID, userInput)
+		if err != nil {
+			// 检索失败不影响对话
+			s.l.WarnContext(ctx, "retrieve documents failed", logger.Error(err), "session_id", session.ID)
+		}
+		limit := remaining / 4
+		for i := len(found); i > 0; i-- {
+			msg := llm.Message{Role: "system", Content: formatCitations(found[:i])}
+			if n := llm.CountMessage(tk, msg); n <= limit {
+				citations, retrieval = found[:i], &msg
+				usage.Retrieval = n
+				break
+			}
+		}
+	}
+	remaining -= usage.Retrieval
+
+	// 4. 获取当前分支路径上的候选历史消息
+	var path []domain.Message
+	if parentID != 0 {
+		all, err := s.messageRepo.FindTreeBySessionID(ctx, session.ID)
+		if err != nil {
+			// 如果获取失败，继续执行（只有用户输入）
+			s.l.WarnContext(ctx, "load messages failed", logger.Error(err), "session_id", session.ID)
+		}
+		path = activePath(all, parentID)
+	}
+	history := path
+	if len(history) > MaxHistoryMessages {
+		history = history[len(history)-MaxHistoryMessages:]
+	}
+
+	// 5. 由新到旧装入历史消息，装不下时为摘要预留空间后重新装入
+	packed, kept, truncated := packHistory(tk, history, remaining)
+	var summary *llm.Message
+	if s.summarySvc != nil && kept < len(path) {
+		stored, err := s.summarySvc.GetSummary(ctx, session.ID)
+		if err != nil {
+			s.l.WarnContext(ctx, "load summary failed", logger.Error(err), "session_id", session.ID)
+		}
+		// 摘要属于其他分支时不使用，由后台按当前分支重新生成
+		if stored != nil && !onPath(path, stored.CoveredMessageID) {
+			stored = nil
+		}
+		if stored != nil && stored.Content != "" {
+			summaryBudget := min(MaxSummaryTokens, remaining/4)
+			msg := llm.Message{
 				Role:    "system",
-				Content: fmt.Sprintf("对话历史摘要：%s", summary),
-			})
+				Content: fmt.Sprintf("对话历史摘要：%s", stored.Content),
+			}
+			if llm.CountMessage(tk, msg) > summaryBudget {
+				msg.Content = llm.Truncate(tk, msg.Content, summaryBudget-llm.MessageOverheadTokens)
+				usage.Truncated = true
+			}
+			summary = &msg
+			usage.Summary = llm.CountMessage(tk, msg)
+			packed, kept, truncated = packHistory(tk, history, remaining-usage.Summary)
+		}
+
+		// 摘要未覆盖所有移出窗口的消息时，请求后台前移
+		if until := path[len(path)-kept-1].ID; until > 0 && (stored == nil || stored.CoveredMessageID < until) {
+			s.summarySvc.RequestRefresh(ctx, session.ID, until, model)
 		}
 	}
+	usage.History = llm.CountMessages(tk, packed)
+	usage.HistoryMessages = kept
+	usage.DroppedMessages = len(path) - kept
+	usage.Truncated = usage.Truncated || truncated
 
-	// 4. 获取最近的消息
-	recentMessages, err := s.messageRepo.FindRecentBySessionID(ctx, sessionID, MaxContextMessages)
-	if err != nil {
-		// 如果获取失败，继续执行（只有用户输入）
-		recentMessages = nil
+	// 6. 组装上下文
+	messages := []llm.Message{system}
+	if summary != nil {
+		messages = append(messages, *summary)
+	}
+	if retrieval != nil {
+		messages = append(messages, *retrieval)
 	}
+	messages = append(messages, packed...)
+	messages = append(messages, input)
+
+	usage.Total = usage.System + usage.Summary + usage.Retrieval + usage.History + usage.Input
+	s.l.DebugContext(ctx, "context built", "session_id", session.ID, "model", model,
+		"budget", usage.Budget, "total", usage.Total, "history", kept, "path", len(path), "truncated", usage.Truncated)
 
-	// 5. 将历史消息加入上下文
-	for _, msg := range recentMessages {
-		messages = append(messages, llm.Message{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		})
+	return &ContextResult{Messages: messages, Citations: citations, Usage: usage}, nil
+}
+
+// resolveModel 返回实际使用的模型，未指定时使用客户端默认模型
+func (s *ContextService) resolveModel(model string) string {
+	if model != "" {
+		return model
+	}
+	if lister, ok := s.llmClient.(llm.ModelLister); ok {
+		return lister.DefaultModel()
 	}
+	return ""
+}
 
-	// 6. 添加当前用户输入
-	messages = append(messages, llm.Message{
-		Role:    "user",
-		Content: userInput,
-	})
+// windowOf 返回模型的上下文窗口
+func (s *ContextService) windowOf(model string) int {
+	if window := llm.ContextWindow(model, s.contextWindows); window > 0 {
+		return window
+	}
+	return s.contextWindow
+}
 
-	return messages, nil
+// budgetOf 计算输入预算：窗口减去输出预留，会话设置了 max_tokens 时以其为预留量
+func (s *ContextService) budgetOf(window int, maxTokens *int) int {
+	reserved := s.reservedOutput
+	if maxTokens != nil && *maxTokens > 0 {
+		reserved = *maxTokens
+	}
+	// 预留过大时至少保留一半窗口给输入
+	return max(window-reserved, window/2)
 }
 
-// generateSummary 生成历史对话摘要
-func (s *ContextService) generateSummary(ctx context.Context, sessionID int64) (string, error) {
-	// 获取所有历史消息（排除最近的）
-	allMessages, err := s.messageRepo.FindBySessionID(ctx, sessionID)
-	if err != nil {
-		return "", err
+// onPath 判断消息是否在分支路径上
+func onPath(path []domain.Message, messageID int64) bool {
+	for i := range path {
+		if path[i].ID == messageID {
+			return true
+		}
 	}
+	return false
+}
 
-	// 只对前面的消息生成摘要，保留最近的 MaxContextMessages 条
-	if len(allMessages) <= MaxContextMessages {
-		return "", nil
+// packHistory 由新到旧装入不超过 budget 的历史消息
+// 工具调用与其结果作为整体装入；最新一条普通消息单独超出预算时截断装入
+// 返回转换后的 LLM 消息、装入的领域消息条数 (history 的后缀长度) 及是否发生截断
+func packHistory(tk llm.Tokenizer, history []domain.Message, budget int) ([]llm.Message, int, bool) {
+	used, start := 0, len(history)
+	for start > 0 {
+		// 向前找到一个完整单元的起点：工具结果需与其调用一起装入
+		unitStart := start - 1
+		for unitStart > 0 && history[unitStart].IsToolResult() {
+			unitStart--
+		}
+		unit := ToLLMMessages(history[unitStart:start])
+		n := llm.CountMessages(tk, unit)
+		if used+n > budget {
+			if start == len(history) && len(unit) == 1 && unit[0].Role != "tool" {
+				if limit := budget - llm.MessageOverheadTokens; limit > 0 {
+					unit[0].Content = llm.Truncate(tk, unit[0].Content, limit)
+					return unit, len(history) - unitStart, true
+				}
+			}
+			break
+		}
+		used += n
+		start = unitStart
 	}
+	return ToLLMMessages(history[start:]), len(history) - start, false
+}
 
-	messagesToSummarize := allMessages[:len(allMessages)-MaxContextMessages]
+// formatCitations 将检索资料格式化为系统消息，要求模型以 [n] 标注引用
+func formatCitations(citations []domain.Citation) string {
+	var b strings.Builder
+	b.WriteString("以下是从用户知识库中检索到的参考资料。回答时如使用了其中的内容，请在相应句子后用 [编号] 标注来源；资料与问题无关时请忽略。\n\n")
+	for i, c := range citations {
+		b.WriteString(fmt.Sprintf("[%d] 《%s》\n%s\n\n", i+1, c.Title, c.Snippet))
+	}
+	return b.String()
+}
 
-	// 转换为 LLM 消息格式
-	llmMessages := make([]llm.Message, len(messagesToSummarize))
-	for i, msg := range messagesToSummarize {
-		llmMessages[i] = llm.Message{
-			Role:    string(msg.Role),
-			Content: msg.Content,
+// ToLLMMessages 将领域消息转换为 LLM 消息
+// 工具调用消息转换为带 ToolCalls 的 assistant 消息；
+// 缺少对应调用的工具结果 (被窗口截断) 和缺少结果的工具调用会被丢弃，避免模型接口报错
+func ToLLMMessages(history []domain.Message) []llm.Message {
+	answered := make(map[string]bool)
+	for _, msg := range history {
+		if msg.IsToolResult() {
+			answered[msg.ToolCallID] = true
 		}
 	}
 
-	// 调用 LLM 生成摘要
-	return s.llmClient.Summarize(ctx, llmMessages)
+	called := make(map[string]bool)
+	result := make([]llm.Message, 0, len(history))
+	for _, msg := range history {
+		switch msg.Role {
+		case domain.RoleToolCall:
+			complete := len(msg.ToolCalls) > 0
+			for _, call := range msg.ToolCalls {
+				complete = complete && answered[call.ID]
+			}
+			if !complete {
+				continue
+			}
+			llmMsg := llm.Message{Role: "assistant", Content: msg.Content}
+			for _, call := range msg.ToolCalls {
+				called[call.ID] = true
+				llmMsg.ToolCalls = append(llmMsg.ToolCalls, llm.ToolCall{
+					ID:        call.ID,
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				})
+			}
+			result = append(result, llmMsg)
+		case domain.RoleTool:
+			if !called[msg.ToolCallID] {
+				continue
+			}
+			result = append(result, llm.Message{
+				Role:       "tool",
+				Content:    msg.Content,
+				ToolCallID: msg.ToolCallID,
+			})
+		default:
+			result = append(result, llm.Message{
+				Role:    string(msg.Role),
+				Content: msg.Content,
+			})
+		}
+	}
+	return result
 }
 
 // SetSystemPrompt 设置系统提示
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/llm"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// runeTokenizer 每个字符计 1 个 token，每条消息另计 llm.MessageOverheadTokens
+type runeTokenizer struct{}
+
+func (runeTokenizer) Count(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+func TestPackHistory(t *testing.T) {
+	user := func(content string) domain.Message {
+		return domain.Message{Role: domain.RoleUser, Content: content}
+	}
+	assistant := func(content string) domain.Message {
+		return domain.Message{Role: domain.RoleAssistant, Content: content}
+	}
+	// 工具调用 4+6 tokens，工具结果 4+6 tokens
+	toolCall := domain.Message{Role: domain.RoleToolCall, ToolCalls: []domain.ToolCall{{ID: "c1", Name: "search"}}}
+	toolResult := domain.Message{Role: domain.RoleTool, Content: "result", ToolCallID: "c1"}
+
+	tests := []struct {
+		name          string
+		history       []domain.Message
+		budget        int
+		wantRoles     []string
+		wantKept      int
+		wantTruncated bool
+	}{
+		{
+			name:      "all fit",
+			history:   []domain.Message{user("aaaaaa"), assistant("bbbbbb")},
+			budget:    20,
+			wantRoles: []string{"user", "assistant"},
+			wantKept:  2,
+		},
+		{
+			name:      "drops oldest first",
+			history:   []domain.Message{user("aaaaaa"), assistant("bbbbbb"), user("cccccc")},
+			budget:    25,
+			wantRoles: []string{"assistant", "user"},
+			wantKept:  2,
+		},
+		{
+			name:      "keeps tool call with its result",
+			history:   []domain.Message{user("aaaaaa"), toolCall, toolResult, assistant("dddddd")},
+			budget:    30,
+			wantRoles: []string{"assistant", "tool", "assistant"},
+			wantKept:  3,
+		},
+		{
+			name:      "drops tool call with its result",
+			history:   []domain.Message{user("aaaaaa"), toolCall, toolResult, assistant("dddddd")},
+			budget:    29,
+			wantRoles: []string{"assistant"},
+			wantKept:  1,
+		},
+		{
+			name:          "truncates oversized latest message",
+			history:       []domain.Message{user("aaaaaa"), user(strings.Repeat("x", 100))},
+			budget:        40,
+			wantRoles:     []string{"user"},
+			wantKept:      1,
+			wantTruncated: true,
+		},
+		{
+			name:     "budget below message overhead",
+			history:  []domain.Message{user(strings.Repeat("x", 100))},
+			budget:   llm.MessageOverheadTokens,
+			wantKept: 0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tk := runeTokenizer{}
+			packed, kept, truncated := packHistory(tk, tc.history, tc.budget)
+
+			roles := make([]string, 0, len(packed))
+			for _, msg := range packed {
+				roles = append(roles, msg.Role)
+			}
+			if strings.Join(roles, ",") != strings.Join(tc.wantRoles, ",") {
+				t.Fatalf("packHistory() roles = %v, want %v", roles, tc.wantRoles)
+			}
+			if kept != tc.wantKept || truncated != tc.wantTruncated {
+				t.Fatalf("packHistory() kept = %d, truncated = %v, want %d, %v", kept, truncated, tc.wantKept, tc.wantTruncated)
+			}
+			if n := llm.CountMessages(tk, packed); n > tc.budget {
+				t.Fatalf("packHistory() = %d tokens, want at most %d", n, tc.budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/domain"
+	"coca-ai/internal/llm"
+	"coca-ai/internal/rag"
+	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDocumentSize 单个文档的最大字符数
+	MaxDocumentSize = 200000
+	// DefaultRetrieveTopK 默认检索切片数
+	DefaultRetrieveTopK = 4
+)
+
+var (
+	ErrDocumentNotFound  = errors.New("文档不存在")
+	ErrDocumentForbidden = errors.New("无权访问该文档")
+	ErrDocumentEmpty     = errors.New("文档内容为空")
+	ErrDocumentTooLarge  = errors.New("文档内容过大")
+)
+
+// DocumentService 知识库文档服务 (RAG)
+type DocumentService struct {
+	repo     *repository.DocumentRepository
+	embedder llm.Embedder
+	store    rag.VectorStore
+	chunker  *rag.Chunker
+	topK     int
+	minScore float32
+	l        *slog.Logger
+}
+
+// NewDocumentService 创建 DocumentService 实例
+func NewDocumentService(
+	repo *repository.DocumentRepository,
+	embedder llm.Embedder,
+	store rag.VectorStore,
+	chunker *rag.Chunker,
+	l *slog.Logger,
+) *DocumentService {
+	cfg := config.Get()
+	topK := cfg.RAG.TopK
+	if topK <= 0 {
+		topK = DefaultRetrieveTopK
+	}
+	return &DocumentService{
+		repo:     repo,
+		embedder: embedder,
+		store:    store,
+		chunker:  chunker,
+		topK:     topK,
+		minScore: cfg.RAG.MinScore,
+		l:        l.With("component", "document_service"),
+	}
+}
+
+// Ingest 导入文档：切片、向量化并写入向量存储
+func (s *DocumentService) Ingest(ctx context.Context, userID int64, title, contentType, content string) (*domain.Document, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrDocumentEmpty
+	}
+	size := utf8.RuneCountInString(content)
+	if size > MaxDocumentSize {
+		return nil, ErrDocumentTooLarge
+	}
+
+	// 1. 切片并向量化 (先于落库，失败时不留下空文档)
+	texts := s.chunker.Split(content)
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embed document failed: %w", err)
+	}
+
+	// 2. 保存文档元数据
+	doc, err := s.repo.Create(ctx, &domain.Document{
+		UserID:      userID,
+		Title:       title,
+		ContentType: contentType,
+		Size:        size,
+		ChunkCount:  len(texts),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 写入向量存储
+	chunks := make([]domain.DocumentChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = domain.DocumentChunk{
+			DocumentID: doc.ID,
+			UserID:     userID,
+			Index:      i,
+			Content:    text,
+			Embedding:  vectors[i],
+		}
+	}
+	if err := s.store.Upsert(ctx, chunks); err != nil {
+		_ = s.repo.Delete(ctx, doc.ID)
+		return nil, fmt.Errorf("store document chunks failed: %w", err)
+	}
+
+	return doc, nil
+}
+
+// ListDocuments 获取用户的文档列表
+func (s *DocumentService) ListDocuments(ctx context.Context, userID int64) ([]domain.Document, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// DeleteDocument 删除文档及其切片
+func (s *DocumentService) DeleteDocument(ctx context.Context, userID int64, documentID int64) error {
+	doc, err := s.repo.FindByID(ctx, documentID)
+	if errors.Is(err, repository.ErrDocumentNotFound) {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if doc.UserID != userID {
+		return ErrDocumentForbidden
+	}
+
+	if err := s.store.DeleteByDocument(ctx, documentID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, documentID)
+}
+
+// Retrieve 检索与 query 最相关的文档切片
+func (s *DocumentService) Retrieve(ctx context.Context, userID int64, query string) ([]domain.Citation, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query failed: %w", err)
+	}
+
+	results, err := s.store.Search(ctx, userID, vectors[0], s.topK)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.Chunk.DocumentID)
+	}
+	docs, err := s.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		s.l.WarnContext(ctx, "load documents failed", logger.Error(err))
+		docs = nil
+	}
+
+	citations := make([]domain.Citation, 0, len(results))
+	for _, r := range results {
+		if r.Score < s.minScore || r.Score <= 0 {
+			continue
+		}
+		citation := domain.Citation{
+			DocumentID: r.Chunk.DocumentID,
+			ChunkIndex: r.Chunk.Index,
+			Snippet:    r.Chunk.Content,
+			Score:      r.Score,
+		}
+		if doc, ok := docs[r.Chunk.DocumentID]; ok {
+			citation.Title = doc.Title
+		}
+		citations = append(citations, citation)
+	}
+	return citations, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// generationStopChannel 跨实例停止生成的 Redis Pub/Sub 频道，消息内容为会话 ID
+const generationStopChannel = "chat:generation:stop"
+
+// subscriber 支持 Pub/Sub 订阅的 Redis 客户端 (*redis.Client, *redis.ClusterClient 等)
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// GenerationManager 管理本实例上正在进行的生成
+// 停止请求通过 Redis Pub/Sub 广播，由持有该会话生成的实例取消
+type GenerationManager struct {
+	client redis.Cmdable
+	l      *slog.Logger
+
+	mu     sync.Mutex
+	nextID uint64
+	active map[int64]map[uint64]context.CancelFunc // sessionID -> 生成 -> 取消函数
+}
+
+// NewGenerationManager 创建 GenerationManager 实例
+func NewGenerationManager(client redis.Cmdable, l *slog.Logger) *GenerationManager {
+	return &GenerationManager{
+		client: client,
+		l:      l.With("component", "generation_manager"),
+		active: make(map[int64]map[uint64]context.CancelFunc),
+	}
+}
+
+// Begin 登记一次生成，返回可被 Stop 取消的 context 及结束时调用的释放函数
+func (m *GenerationManager) Begin(ctx context.Context, sessionID int64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	if m.active[sessionID] == nil {
+		m.active[sessionID] = make(map[uint64]context.CancelFunc)
+	}
+	m.active[sessionID][id] = cancel
+	m.mu.Unlock()
+
+	return ctx, func() {
+		m.mu.Lock()
+		delete(m.active[sessionID], id)
+		if len(m.active[sessionID]) == 0 {
+			delete(m.active, sessionID)
+		}
+		m.mu.Unlock()
+		cancel()
+	}
+}
+
+// Stop 停止会话正在进行的生成
+// 先取消本实例上的生成，再广播给其他实例；返回本实例是否有被取消的生成
+func (m *GenerationManager) Stop(ctx context.Context, sessionID int64) (bool, error) {
+	stopped := m.cancelLocal(sessionID)
+	if m.client == nil {
+		return stopped, nil
+	}
+	err := m.client.Publish(ctx, generationStopChannel, strconv.FormatInt(sessionID, 10)).Err()
+	return stopped, err
+}
+
+// Subscribe 订阅其他实例发出的停止请求 (阻塞，直到 ctx 结束)
+func (m *GenerationManager) Subscribe(ctx context.Context) {
+	sub, ok := m.client.(subscriber)
+	if !ok {
+		m.l.Warn("redis client does not support pub/sub, stop requests are local only")
+		return
+	}
+
+	pubsub := sub.Subscribe(ctx, generationStopChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			sessionID, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			if m.cancelLocal(sessionID) {
+				m.l.Info("stopped generation by remote request", "session_id", sessionID)
+			}
+		}
+	}
+}
+
+// cancelLocal 取消本实例上该会话的所有生成
+func (m *GenerationManager) cancelLocal(sessionID int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cancels := m.active[sessionID]
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return len(cancels) > 0
+}
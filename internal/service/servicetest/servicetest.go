@@ -0,0 +1,83 @@
+// Package servicetest 提供 service 与 handler 测试共用的内存依赖
+package servicetest
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository"
+	"context"
+)
+
+// 测试会话 SessionID 属于 OwnerID，MissingID 不存在
+const (
+	OwnerID     int64 = 1
+	OtherUserID int64 = 2
+	SessionID   int64 = 10
+	MissingID   int64 = 404
+)
+
+// SessionStore 内存中的会话存储
+type SessionStore struct {
+	sessions map[int64]*domain.Session
+	// Writes 记录写操作次数，用于确认越权请求未修改数据
+	Writes int
+}
+
+// NewSessionStore 创建包含 sessions 的会话存储
+func NewSessionStore(sessions ...domain.Session) *SessionStore {
+	store := &SessionStore{sessions: make(map[int64]*domain.Session)}
+	for i := range sessions {
+		store.sessions[sessions[i].ID] = &sessions[i]
+	}
+	return store
+}
+
+func (s *SessionStore) Create(_ context.Context, session *domain.Session) (*domain.Session, error) {
+	s.Writes++
+	session.ID = int64(len(s.sessions) + 1)
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *SessionStore) FindByID(_ context.Context, id int64) (*domain.Session, error) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, repository.ErrSessionNotFound
+	}
+	cp := *session
+	return &cp, nil
+}
+
+func (s *SessionStore) FindByUserID(_ context.Context, userID int64) ([]domain.Session, error) {
+	var sessions []domain.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *SessionStore) UpdateTitle(context.Context, int64, string) error {
+	s.Writes++
+	return nil
+}
+
+func (s *SessionStore) UpdateSettings(context.Context, *domain.Session) error {
+	s.Writes++
+	return nil
+}
+
+func (s *SessionStore) UpdateActiveMessage(context.Context, int64, int64) error {
+	s.Writes++
+	return nil
+}
+
+func (s *SessionStore) Delete(context.Context, int64) error {
+	s.Writes++
+	return nil
+}
+
+func (s *SessionStore) TouchUpdatedAt(context.Context, int64) error {
+	s.Writes++
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/pkg/logger"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sessionEventChannel 会话事件的 Redis Pub/Sub 频道
+const sessionEventChannel = "chat:session:events"
+
+// sessionEventBuffer 每个订阅者缓冲的事件数，消费过慢时丢弃新事件
+const sessionEventBuffer = 64
+
+// SessionEventBus 会话事件总线
+// 事件经 Redis Pub/Sub 广播到所有实例，再分发给本实例上该用户的订阅者 (如 WebSocket 连接)
+type SessionEventBus struct {
+	client redis.Cmdable
+	l      *slog.Logger
+
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[int64]map[uint64]chan domain.SessionEvent // userID -> 订阅者
+}
+
+// NewSessionEventBus 创建 SessionEventBus 实例
+func NewSessionEventBus(client redis.Cmdable, l *slog.Logger) *SessionEventBus {
+	return &SessionEventBus{
+		client: client,
+		l:      l.With("component", "session_event_bus"),
+		subs:   make(map[int64]map[uint64]chan domain.SessionEvent),
+	}
+}
+
+// Publish 发布会话事件
+// 广播失败或 Redis 不支持订阅时仅分发给本实例的订阅者
+func (b *SessionEventBus) Publish(ctx context.Context, event domain.SessionEvent) {
+	if _, ok := b.client.(subscriber); ok {
+		data, err := json.Marshal(event)
+		if err == nil {
+			err = b.client.Publish(ctx, sessionEventChannel, data).Err()
+		}
+		if err == nil {
+			return
+		}
+		b.l.WarnContext(ctx, "publish event failed", logger.Error(err), "type", event.Type)
+	}
+	b.dispatch(event)
+}
+
+// Subscribe 订阅用户的会话事件，返回事件通道与取消订阅函数
+func (b *SessionEventBus) Subscribe(userID int64) (<-chan domain.SessionEvent, func()) {
+	ch := make(chan domain.SessionEvent, sessionEventBuffer)
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[uint64]chan domain.SessionEvent)
+	}
+	b.subs[userID][id] = ch
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs[userID], id)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Run 接收其他实例广播的会话事件 (阻塞，直到 ctx 结束)
+func (b *SessionEventBus) Run(ctx context.Context) {
+	sub, ok := b.client.(subscriber)
+	if !ok {
+		b.l.Warn("redis client does not support pub/sub, session events are local only")
+		return
+	}
+
+	pubsub := sub.Subscribe(ctx, sessionEventChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var event domain.SessionEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.l.Warn("unmarshal event failed", logger.Error(err))
+				continue
+			}
+			b.dispatch(event)
+		}
+	}
+}
+
+// dispatch 将事件分发给本实例上该用户的订阅者
+func (b *SessionEventBus) dispatch(event domain.SessionEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subs[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
+	"coca-ai/pkg/snowflake"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGenerationNotFound  = errors.New("生成不存在或已过期")
+	ErrIdempotencyKeyReuse = errors.New("Idempotency-Key 已用于其他请求")
+	ErrRequestInProgress   = errors.New("相同 Idempotency-Key 的请求正在处理中")
+)
+
+const (
+	GenerationEventStart = "generation" // 生成开始，携带生成 ID
+	GenerationEventDone  = "done"       // 生成完成
+	GenerationEventError = "error"      // 生成失败
+
+	// generationPollInterval 读取事件流时单次阻塞等待的时长
+	generationPollInterval = 5 * time.Second
+	// generationTouchInterval 生成期间续期事件流与幂等 Key 的间隔，远小于其过期时间
+	generationTouchInterval = time.Minute
+)
+
+// EmitFunc 向生成事件流写入一条事件，data 编码为 JSON
+type EmitFunc func(event string, data any)
+
+// GenerateFunc 生成过程，返回 done 事件的内容
+type GenerateFunc func(ctx context.Context, emit EmitFunc) (any, error)
+
+// StreamService 可续传的流式生成
+// 生成在后台运行，事件缓冲在 Redis Stream 中；客户端断开不影响生成，重连后可从断点重放
+type StreamService struct {
+	repo  *repository.GenerationRepository
+	idGen *snowflake.Node
+	l     *slog.Logger
+
+	// touchInterval 生成期间的续期间隔
+	touchInterval time.Duration
+	// running 本实例上正在后台运行的生成，停机时等待其结束
+	running sync.WaitGroup
+}
+
+// NewStreamService 创建 StreamService 实例
+func NewStreamService(repo *repository.GenerationRepository, idGen *snowflake.Node, l *slog.Logger) *StreamService {
+	return &StreamService{
+		repo:          repo,
+		idGen:         idGen,
+		l:             l.With("component", "stream_service"),
+		touchInterval: generationTouchInterval,
+	}
+}
+
+// Start 在后台启动一次生成，返回生成 ID
+// 生成不随请求结束而取消 (仅能通过 StopGeneration 停止)，结束时写入 done 或 error 事件
+func (s *StreamService) Start(ctx context.Context, userID int64, sessionID int64, run GenerateFunc) (int64, error) {
+	return s.start(ctx, userID, sessionID, run, nil)
+}
+
+// start 启动生成，生成期间定期续期事件流，长时间没有新事件 (如等待工具调用) 也不会过期；
+// touch 不为空时随事件流一同续期，并在生成结束时再调用一次
+func (s *StreamService) start(ctx context.Context, userID int64, sessionID int64, run GenerateFunc, touch func(ctx context.Context)) (int64, error) {
+	generation := &domain.Generation{
+		ID:        s.idGen.Generate(),
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+	if err := s.repo.Create(ctx, generation); err != nil {
+		return 0, err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	emit := func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			s.l.ErrorContext(ctx, "marshal event failed", logger.Error(err), "event", event, "generation_id", generation.ID)
+			return
+		}
+		if _, err := s.repo.AppendEvent(ctx, generation.ID, event, string(payload)); err != nil {
+			s.l.ErrorContext(ctx, "append event failed", logger.Error(err), "event", event, "generation_id", generation.ID)
+			return
+		}
+	}
+	emit(GenerationEventStart, map[string]any{"generation_id": generation.ID, "session_id": sessionID})
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		stop := s.keepAlive(ctx, generation.ID, touch)
+		result, err := run(ctx, emit)
+		stop()
+		if err != nil {
+			emit(GenerationEventError, map[string]any{"msg": err.Error()})
+		} else {
+			emit(GenerationEventDone, result)
+		}
+		if touch != nil {
+			touch(ctx)
+		}
+	}()
+	return generation.ID, nil
+}
+
+// keepAlive 按 touchInterval 续期生成的事件流，返回停止续期的函数 (等待续期协程退出)
+func (s *StreamService) keepAlive(ctx context.Context, generationID int64, touch func(ctx context.Context)) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(s.touchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := s.repo.Touch(ctx, generationID); err != nil {
+				s.l.WarnContext(ctx, "touch generation failed", logger.Error(err), "generation_id", generationID)
+			}
+			if touch != nil {
+				touch(ctx)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// StartIdempotent 按 Idempotency-Key 启动生成
+// 同一 Key 的重试不会再次发送，而是返回首次请求启动的生成 (replayed 为 true)；key 为空时等同于 Start
+func (s *StreamService) StartIdempotent(ctx context.Context, userID int64, sessionID int64, key string, fingerprint string, run GenerateFunc) (generationID int64, replayed bool, err error) {
+	if key == "" {
+		generationID, err = s.Start(ctx, userID, sessionID, run)
+		return generationID, false, err
+	}
+
+	existing, ok, err := s.repo.ReserveIdempotencyKey(ctx, userID, key, fingerprint)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		switch {
+		case existing.Fingerprint != fingerprint:
+			return 0, false, ErrIdempotencyKeyReuse
+		case existing.GenerationID == 0:
+			return 0, false, ErrRequestInProgress
+		}
+		return existing.GenerationID, true, nil
+	}
+
+	// 幂等 Key 与事件流同时过期：生成期间定期续期，结束时再续期一次，
+	// 保证 Key 有效期间首次请求的生成总能重放
+	touch := func(ctx context.Context) {
+		if err := s.repo.TouchIdempotencyKey(ctx, userID, key); err != nil {
+			s.l.WarnContext(ctx, "touch idempotency key failed", logger.Error(err), "session_id", sessionID)
+		}
+	}
+
+	generationID, err = s.start(ctx, userID, sessionID, run, touch)
+	if err != nil {
+		// 未能启动时释放 Key，允许客户端重试
+		if releaseErr := s.repo.ReleaseIdempotencyKey(ctx, userID, key); releaseErr != nil {
+			s.l.WarnContext(ctx, "release idempotency key failed", logger.Error(releaseErr), "session_id", sessionID)
+		}
+		return 0, false, err
+	}
+	if err := s.repo.BindIdempotencyKey(ctx, userID, key, domain.IdempotentRequest{
+		Fingerprint:  fingerprint,
+		GenerationID: generationID,
+	}); err != nil {
+		s.l.WarnContext(ctx, "bind idempotency key failed", logger.Error(err), "session_id", sessionID, "generation_id", generationID)
+	}
+	return generationID, false, nil
+}
+
+// Track 登记一个不经过 Start 的后台任务 (如 WebSocket 连接与生成、直接输出的生成)，停机时同样等待其结束
+// 返回任务结束时调用的函数
+func (s *StreamService) Track() (done func()) {
+	s.running.Add(1)
+	return s.running.Done
+}
+
+// Wait 等待本实例上的生成全部结束，ctx 结束时返回其错误
+func (s *StreamService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetGeneration 获取生成，并校验归属
+func (s *StreamService) GetGeneration(ctx context.Context, userID int64, generationID int64) (*domain.Generation, error) {
+	generation, err := s.repo.FindByID(ctx, generationID)
+	if errors.Is(err, repository.ErrGenerationNotFound) {
+		return nil, ErrGenerationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if generation.UserID != userID {
+		return nil, ErrSessionForbidden
+	}
+	return generation, nil
+}
+
+// Follow 从 afterID 之后重放生成事件，并持续跟随直到生成结束或 ctx 结束
+// afterID 为 "0" 时从头重放
+func (s *StreamService) Follow(ctx context.Context, userID int64, generationID int64, afterID string, write func(event domain.GenerationEvent) error) error {
+	if _, err := s.GetGeneration(ctx, userID, generationID); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		events, err := s.repo.ReadEvents(ctx, generationID, afterID, generationPollInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, event := range events {
+			if err := write(event); err != nil {
+				return err
+			}
+			afterID = event.ID
+			if event.Event == GenerationEventDone || event.Event == GenerationEventError {
+				return nil
+			}
+		}
+		// 长时间无新事件时确认生成仍然存在 (如生成所在实例崩溃，事件流会过期)
+		if len(events) == 0 {
+			if _, err := s.repo.FindByID(ctx, generationID); errors.Is(err, repository.ErrGenerationNotFound) {
+				return ErrGenerationNotFound
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"coca-ai/internal/repository"
+	"coca-ai/internal/repository/cache"
+	"coca-ai/pkg/snowflake"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamService_KeepsLongGenerationAlive(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	node, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewStreamService(repository.NewGenerationRepository(cache.NewGenerationCache(client)), node,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.touchInterval = 10 * time.Millisecond
+
+	release := make(chan struct{})
+	generationID, replayed, err := svc.StartIdempotent(context.Background(), ownerID, sessionID, "key-1", "fp",
+		func(ctx context.Context, emit EmitFunc) (any, error) {
+			<-release
+			return map[string]any{}, nil
+		})
+	if err != nil || replayed {
+		t.Fatalf("StartIdempotent() = %d, %v, %v", generationID, replayed, err)
+	}
+
+	keys := []string{
+		fmt.Sprintf("chat:generation:%d:stream", generationID),
+		fmt.Sprintf("chat:generation:%d:meta", generationID),
+		fmt.Sprintf("chat:idempotency:%d:key-1", ownerID),
+	}
+	// 生成长时间没有新事件，事件流与幂等 Key 仍在过期前被续期
+	for range 3 {
+		mr.FastForward(9 * time.Minute)
+		waitFor(t, func() bool {
+			for _, key := range keys {
+				if mr.TTL(key) <= time.Minute {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	for _, key := range keys {
+		if !mr.Exists(key) {
+			t.Fatalf("%s expired while the generation was running", key)
+		}
+	}
+
+	close(release)
+	if err := svc.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor 等待 cond 成立，超时则失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
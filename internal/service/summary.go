@@ -0,0 +1,193 @@
+package service
+
+import (
+	"coca-ai/internal/domain"
+	"coca-ai/internal/llm"
+	"coca-ai/internal/mq"
+	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// SummaryBatchTokens 单次增量摘要输入的 token 上限
+const SummaryBatchTokens = 6000
+
+// SummaryService 会话摘要服务
+// 摘要持久化在 MySQL + Redis，只在旧消息移出上下文窗口时增量前移，由 Kafka 任务在后台生成
+type SummaryService struct {
+	summaryRepo *repository.SummaryRepository
+	messageRepo *repository.MessageRepository
+	llmClient   llm.ChatClient
+	producer    *mq.Producer
+	l           *slog.Logger
+
+	// 无 Kafka 时在本地协程中生成，同一会话同时只运行一个任务
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+// NewSummaryService 创建 SummaryService 实例
+func NewSummaryService(
+	summaryRepo *repository.SummaryRepository,
+	messageRepo *repository.MessageRepository,
+	llmClient llm.ChatClient,
+	producer *mq.Producer,
+	l *slog.Logger,
+) *SummaryService {
+	return &SummaryService{
+		summaryRepo: summaryRepo,
+		messageRepo: messageRepo,
+		llmClient:   llmClient,
+		producer:    producer,
+		l:           l.With("component", "summary_service"),
+		running:     make(map[int64]bool),
+	}
+}
+
+// GetSummary 获取会话摘要，尚未生成时返回 nil
+func (s *SummaryService) GetSummary(ctx context.Context, sessionID int64) (*domain.Summary, error) {
+	summary, err := s.summaryRepo.FindBySessionID(ctx, sessionID)
+	if errors.Is(err, repository.ErrSummaryNotFound) {
+		return nil, nil
+	}
+	return summary, err
+}
+
+// RequestRefresh 请求后台将摘要前移至 untilMessageID (含)
+// 优先投递 Kafka 任务，无 Kafka 或投递失败时在本地协程中执行
+func (s *SummaryService) RequestRefresh(ctx context.Context, sessionID int64, untilMessageID int64, model string) {
+	event := &mq.SummaryEvent{SessionID: sessionID, UntilMessageID: untilMessageID, Model: model}
+	if s.producer != nil {
+		err := s.producer.SendSummaryTask(ctx, event)
+		if err == nil {
+			return
+		}
+		s.l.WarnContext(ctx, "send summary task to kafka failed", logger.Error(err), "session_id", sessionID)
+	}
+
+	s.mu.Lock()
+	if s.running[sessionID] {
+		s.mu.Unlock()
+		return
+	}
+	s.running[sessionID] = true
+	s.mu.Unlock()
+
+	// 脱离请求的生命周期，但保留请求 ID 与链路信息
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, sessionID)
+			s.mu.Unlock()
+		}()
+		if err := s.Refresh(ctx, event); err != nil {
+			s.l.ErrorContext(ctx, "refresh summary failed", logger.Error(err), "session_id", sessionID)
+		}
+	}()
+}
+
+// Refresh 将摘要增量前移至 event.UntilMessageID
+// 摘要覆盖从根消息到 UntilMessageID 的分支路径：已覆盖的消息不会重复摘要，
+// 新增消息按 SummaryBatchTokens 分批，与上一版摘要合并生成新摘要；
+// 已有摘要不在该路径上 (切换了分支) 时从头重新生成
+func (s *SummaryService) Refresh(ctx context.Context, event *mq.SummaryEvent) error {
+	summary, err := s.GetSummary(ctx, event.SessionID)
+	if err != nil {
+		return err
+	}
+	if summary != nil && summary.CoveredMessageID == event.UntilMessageID {
+		return nil
+	}
+
+	// 摘要需要覆盖分支上的全部历史，不能使用已截断的缓存列表
+	messages, err := s.messageRepo.FindTreeBySessionID(ctx, event.SessionID)
+	if err != nil {
+		return err
+	}
+	pending := activePath(messages, event.UntilMessageID)
+	coveredCount := 0
+	if summary != nil && onPath(activePath(messages, summary.CoveredMessageID), event.UntilMessageID) {
+		// 摘要已覆盖到更靠后的位置，只前移不回退
+		return nil
+	}
+	if summary != nil {
+		covered := -1
+		for i := range pending {
+			if pending[i].ID == summary.CoveredMessageID {
+				covered = i
+				break
+			}
+		}
+		if covered >= 0 {
+			pending = pending[covered+1:]
+			coveredCount = summary.CoveredCount
+		} else {
+			summary = nil
+		}
+	}
+
+	tk := llm.TokenizerFor(event.Model)
+	for len(pending) > 0 {
+		batch, input := s.nextBatch(tk, pending)
+		pending = pending[len(batch):]
+
+		if summary != nil && summary.Content != "" {
+			input = append([]llm.Message{{Role: "system", Content: summary.Content}}, input...)
+		}
+		content, err := s.llmClient.Summarize(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		coveredCount += len(batch)
+		summary = &domain.Summary{
+			SessionID:        event.SessionID,
+			Content:          content,
+			CoveredMessageID: batch[len(batch)-1].ID,
+			CoveredCount:     coveredCount,
+		}
+		if err := s.summaryRepo.Save(ctx, summary); err != nil {
+			return err
+		}
+		s.l.InfoContext(ctx, "summary rolled forward", "session_id", event.SessionID,
+			"covered_message_id", summary.CoveredMessageID, "covered_count", coveredCount)
+	}
+	return nil
+}
+
+// HandleEvent 处理 Kafka 摘要任务
+func (s *SummaryService) HandleEvent(ctx context.Context, event *mq.SummaryEvent) error {
+	return s.Refresh(ctx, event)
+}
+
+// DeleteSummary 删除会话摘要
+func (s *SummaryService) DeleteSummary(ctx context.Context, sessionID int64) error {
+	return s.summaryRepo.DeleteBySessionID(ctx, sessionID)
+}
+
+// nextBatch 取出不超过 SummaryBatchTokens 的下一批消息 (至少一条，过长时截断)
+func (s *SummaryService) nextBatch(tk llm.Tokenizer, pending []domain.Message) ([]domain.Message, []llm.Message) {
+	used, n := 0, 0
+	for n < len(pending) {
+		tokens := llm.MessageOverheadTokens + tk.Count(pending[n].Content)
+		if n > 0 && used+tokens > SummaryBatchTokens {
+			break
+		}
+		used += tokens
+		n++
+	}
+
+	batch := pending[:n]
+	input := make([]llm.Message, 0, n)
+	for _, msg := range batch {
+		input = append(input, llm.Message{
+			Role:    string(msg.Role),
+			Content: llm.Truncate(tk, msg.Content, SummaryBatchTokens),
+		})
+	}
+	return batch, input
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"coca-ai/internal/config"
+	"coca-ai/internal/domain"
+	"coca-ai/internal/llm"
+	"coca-ai/internal/repository"
+	"coca-ai/pkg/logger"
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("本月 token 配额已用完")
+
+const (
+	// DefaultUsageDays 查询用量时默认返回的天数
+	DefaultUsageDays = 30
+	// MaxUsageDays 查询用量时最多返回的天数
+	MaxUsageDays = 366
+)
+
+// UsageService token 用量统计与配额控制
+// 每次模型调用的用量按用户按天累加到 MySQL，配额按自然月计算
+type UsageService struct {
+	repo         *repository.UsageRepository
+	defaultQuota int64
+	userQuotas   map[int64]int64
+	l            *slog.Logger
+}
+
+// NewUsageService 创建 UsageService 实例
+func NewUsageService(repo *repository.UsageRepository, l *slog.Logger) *UsageService {
+	l = l.With("component", "usage_service")
+	cfg := config.Get().Quota
+	userQuotas := make(map[int64]int64, len(cfg.Users))
+	for key, quota := range cfg.Users {
+		userID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			l.Warn("ignore quota of invalid user id", "user_id", key)
+			continue
+		}
+		userQuotas[userID] = quota
+	}
+	return &UsageService{
+		repo:         repo,
+		defaultQuota: cfg.MonthlyTokens,
+		userQuotas:   userQuotas,
+		l:            l,
+	}
+}
+
+// Quota 返回用户每月的 token 配额，0 表示不限额
+func (s *UsageService) Quota(userID int64) int64 {
+	quota, ok := s.userQuotas[userID]
+	if !ok {
+		quota = s.defaultQuota
+	}
+	return max(quota, 0)
+}
+
+// CheckQuota 校验用户本月用量是否已达配额，达到时返回 ErrQuotaExceeded
+// 用量查询失败时放行，避免统计故障影响对话
+func (s *UsageService) CheckQuota(ctx context.Context, userID int64) error {
+	quota := s.Quota(userID)
+	if quota == 0 {
+		return nil
+	}
+	now := time.Now()
+	used, err := s.repo.SumTokens(ctx, userID, monthStart(now), now)
+	if err != nil {
+		s.l.ErrorContext(ctx, "sum usage failed", logger.Error(err), "user_id", userID)
+		return nil
+	}
+	if used >= quota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Record 记录一次模型调用的用量
+// 记录失败只打日志；请求已结束 (如客户端断开) 时仍然记录
+func (s *UsageService) Record(ctx context.Context, userID int64, usage llm.Usage) {
+	if usage.Total() == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := s.repo.Add(ctx, userID, time.Now(), usage.PromptTokens, usage.CompletionTokens); err != nil {
+		s.l.ErrorContext(ctx, "record usage failed", logger.Error(err), "user_id", userID)
+	}
+}
+
+// GetUsage 查询用户最近 days 天的每日用量与本月配额使用情况
+func (s *UsageService) GetUsage(ctx context.Context, userID int64, days int) (*domain.UsageReport, error) {
+	if days <= 0 {
+		days = DefaultUsageDays
+	}
+	days = min(days, MaxUsageDays)
+
+	now := time.Now()
+	daily, err := s.repo.ListDaily(ctx, userID, now.AddDate(0, 0, 1-days), now)
+	if err != nil {
+		return nil, err
+	}
+	monthTokens, err := s.repo.SumTokens(ctx, userID, monthStart(now), now)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UsageReport{
+		Days:         daily,
+		MonthTokens:  monthTokens,
+		MonthlyQuota: s.Quota(userID),
+	}, nil
+}
+
+// monthStart 返回 t 所在自然月的第一天
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
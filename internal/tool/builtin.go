@@ -0,0 +1,40 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CurrentTime 获取当前时间的内置工具
+func CurrentTime() Tool {
+	return Tool{
+		Name:        "current_time",
+		Description: "获取指定时区的当前日期和时间",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {"type": "string", "description": "IANA 时区名称，如 Asia/Shanghai，默认 Asia/Shanghai"}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			if arguments != "" {
+				if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+			}
+			if args.Timezone == "" {
+				args.Timezone = "Asia/Shanghai"
+			}
+			loc, err := time.LoadLocation(args.Timezone)
+			if err != nil {
+				return "", fmt.Errorf("unknown timezone: %s", args.Timezone)
+			}
+			return time.Now().In(loc).Format("2006-01-02 15:04:05 Monday MST"), nil
+		},
+	}
+}
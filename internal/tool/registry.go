@@ -0,0 +1,87 @@
+package tool
+
+import (
+	"coca-ai/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrToolNotFound = errors.New("tool not found")
+
+// Handler 工具执行函数
+// arguments 为模型生成的 JSON 参数，返回值作为工具结果回传给模型
+type Handler func(ctx context.Context, arguments string) (string, error)
+
+// Tool 可供模型调用的工具
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
+	Handler     Handler
+}
+
+// Registry 工具注册表
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+	order []string
+}
+
+// NewRegistry 创建工具注册表
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]*Tool)}
+}
+
+// Register 注册工具，同名工具会被覆盖
+func (r *Registry) Register(t Tool) error {
+	if t.Name == "" || t.Handler == nil {
+		return fmt.Errorf("tool name and handler are required")
+	}
+	if len(t.Parameters) > 0 && !json.Valid(t.Parameters) {
+		return fmt.Errorf("tool %s: invalid parameters schema", t.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[t.Name]; !ok {
+		r.order = append(r.order, t.Name)
+	}
+	r.tools[t.Name] = &t
+	return nil
+}
+
+// Get 根据名称获取工具
+func (r *Registry) Get(name string) (*Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Definitions 返回提供给模型的工具描述 (按注册顺序)
+func (r *Registry) Definitions() []llm.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]llm.ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		defs = append(defs, llm.ToolDefinition{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+		})
+	}
+	return defs
+}
+
+// Call 执行工具
+func (r *Registry) Call(ctx context.Context, name string, arguments string) (string, error) {
+	t, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	return t.Handler(ctx, arguments)
+}
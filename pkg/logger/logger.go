@@ -0,0 +1,123 @@
+// Package logger 基于 slog 的结构化日志
+// 日志自动携带 context 中的请求 ID 与链路 ID，并脱敏消息内容与凭证类字段
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys 需要脱敏的字段名 (小写)
+var sensitiveKeys = map[string]struct{}{
+	"content":       {},
+	"password":      {},
+	"token":         {},
+	"access_token":  {},
+	"refresh_token": {},
+	"api_key":       {},
+	"apikey":        {},
+	"authorization": {},
+	"cookie":        {},
+	"secret":        {},
+}
+
+// Options 日志配置
+type Options struct {
+	Level  string // debug, info, warn, error，默认 info
+	Format string // json 或 text，默认 text
+}
+
+// New 创建 Logger
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{
+		Level:       ParseLevel(opts.Level),
+		ReplaceAttr: redact,
+	}
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, "json") {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel 解析日志级别，无法识别时返回 info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Error 错误字段
+func Error(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// redact 脱敏敏感字段，只保留长度
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; !ok {
+		return a
+	}
+	if a.Value.Kind() == slog.KindString && a.Value.String() == "" {
+		return a
+	}
+	return slog.String(a.Key, redacted)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求 ID 写入 context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 获取 context 中的请求 ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler 为日志追加 context 中的请求 ID 与链路 ID
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle 处理日志记录
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs 返回追加字段后的 Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup 返回带分组的 Handler
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
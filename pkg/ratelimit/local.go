@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// localCleanupThreshold 记录的 key 超过该数量时清理已过期的 key
+const localCleanupThreshold = 10000
+
+// LocalLimiter 单机内存滑动窗口限流器
+// 计数不在实例间共享，仅作为 Redis 不可用时的降级方案
+type LocalLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*localWindow
+}
+
+// localWindow 单个 key 窗口内的请求时间 (升序)
+type localWindow struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+// NewLocalLimiter 创建 LocalLimiter 实例
+func NewLocalLimiter() *LocalLimiter {
+	return &LocalLimiter{windows: make(map[string]*localWindow)}
+}
+
+// Allow 判断 key 的本次请求是否允许
+func (l *LocalLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	if !rule.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.windows) > localCleanupThreshold {
+		l.cleanup(now)
+	}
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &localWindow{}
+		l.windows[key] = w
+	}
+	w.window = rule.Window
+	w.hits = prune(w.hits, now.Add(-rule.Window))
+	if len(w.hits) >= rule.Limit {
+		return Result{RetryAfter: w.hits[0].Add(rule.Window).Sub(now)}, nil
+	}
+	w.hits = append(w.hits, now)
+	return Result{Allowed: true, Remaining: rule.Limit - len(w.hits)}, nil
+}
+
+// cleanup 删除窗口内已无请求的 key
+func (l *LocalLimiter) cleanup(now time.Time) {
+	for key, w := range l.windows {
+		if len(prune(w.hits, now.Add(-w.window))) == 0 {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// prune 丢弃 since 之前 (含) 的请求时间
+func prune(hits []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(since) {
+		i++
+	}
+	return hits[i:]
+}
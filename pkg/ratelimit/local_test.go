@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocalLimiter_Allow(t *testing.T) {
+	l := NewLocalLimiter()
+	ctx := context.Background()
+	rule := Rule{Limit: 3, Window: 50 * time.Millisecond}
+
+	for i := 0; i < rule.Limit; i++ {
+		res, err := l.Allow(ctx, "user:1", rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != rule.Limit-i-1 {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, res, rule.Limit-i-1)
+		}
+	}
+
+	res, err := l.Allow(ctx, "user:1", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed {
+		t.Fatal("request over limit allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > rule.Window {
+		t.Fatalf("RetryAfter = %v, want within (0, %v]", res.RetryAfter, rule.Window)
+	}
+
+	// 其他 key 独立计数
+	if res, _ := l.Allow(ctx, "user:2", rule); !res.Allowed {
+		t.Fatal("request of another key rejected")
+	}
+
+	// 窗口滑过后恢复
+	time.Sleep(rule.Window + 10*time.Millisecond)
+	if res, _ := l.Allow(ctx, "user:1", rule); !res.Allowed {
+		t.Fatal("request after window rejected")
+	}
+}
+
+func TestLocalLimiter_DisabledRule(t *testing.T) {
+	l := NewLocalLimiter()
+	for _, rule := range []Rule{{}, {Limit: 1}, {Window: time.Second}} {
+		for i := 0; i < 3; i++ {
+			if res, _ := l.Allow(context.Background(), "user:1", rule); !res.Allowed {
+				t.Fatalf("rule %+v rejected request %d", rule, i)
+			}
+		}
+	}
+	if len(l.windows) != 0 {
+		t.Fatalf("disabled rules recorded %d keys", len(l.windows))
+	}
+}
+
+func TestLocalLimiter_Cleanup(t *testing.T) {
+	l := NewLocalLimiter()
+	now := time.Now()
+	l.windows["expired"] = &localWindow{hits: []time.Time{now.Add(-2 * time.Second)}, window: time.Second}
+	l.windows["active"] = &localWindow{hits: []time.Time{now}, window: time.Second}
+
+	l.cleanup(now)
+	if _, ok := l.windows["expired"]; ok {
+		t.Fatal("expired key not removed")
+	}
+	if _, ok := l.windows["active"]; !ok {
+		t.Fatal("active key removed")
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"coca-ai/pkg/logger"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Rule 限流规则：Window 时间窗口内最多 Limit 次请求
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Enabled 规则是否生效
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// Result 限流判定结果
+type Result struct {
+	Allowed    bool
+	Remaining  int           // 窗口内剩余可用次数
+	RetryAfter time.Duration // 被拒绝时距下次可用的时长
+}
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 判断 key 的本次请求是否允许，允许时计入窗口
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// FallbackLimiter 主限流器出错时改用备用限流器
+// 用于 Redis 不可用时退化为单机内存限流
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+// NewFallbackLimiter 创建 FallbackLimiter 实例
+func NewFallbackLimiter(primary Limiter, fallback Limiter) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, fallback: fallback}
+}
+
+// Allow 优先使用主限流器，出错时使用备用限流器
+func (l *FallbackLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	res, err := l.primary.Allow(ctx, key, rule)
+	if err == nil {
+		return res, nil
+	}
+	slog.WarnContext(ctx, "primary limiter failed, falling back to local", logger.Error(err), "component", "ratelimit")
+	return l.fallback.Allow(ctx, key, rule)
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubLimiter 返回固定结果的限流器
+type stubLimiter struct {
+	res   Result
+	err   error
+	calls int
+}
+
+func (s *stubLimiter) Allow(context.Context, string, Rule) (Result, error) {
+	s.calls++
+	return s.res, s.err
+}
+
+func TestFallbackLimiter_Allow(t *testing.T) {
+	rule := Rule{Limit: 1, Window: time.Second}
+
+	t.Run("primary ok", func(t *testing.T) {
+		primary := &stubLimiter{res: Result{Allowed: false, RetryAfter: time.Second}}
+		fallback := &stubLimiter{res: Result{Allowed: true}}
+		res, err := NewFallbackLimiter(primary, fallback).Allow(context.Background(), "k", rule)
+		if err != nil || res.Allowed {
+			t.Fatalf("Allow() = %+v, %v, want primary result", res, err)
+		}
+		if fallback.calls != 0 {
+			t.Fatalf("fallback called %d times", fallback.calls)
+		}
+	})
+
+	t.Run("primary failed", func(t *testing.T) {
+		primary := &stubLimiter{err: errors.New("redis down")}
+		fallback := &stubLimiter{res: Result{Allowed: true, Remaining: 5}}
+		res, err := NewFallbackLimiter(primary, fallback).Allow(context.Background(), "k", rule)
+		if err != nil || !res.Allowed || res.Remaining != 5 {
+			t.Fatalf("Allow() = %+v, %v, want fallback result", res, err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript 基于有序集合的滑动窗口
+// 返回 {是否允许, 剩余次数, 需等待的毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local retry = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	retry = tonumber(oldest[2]) + window - now
+end
+return {0, 0, retry}
+`)
+
+// RedisLimiter 基于 Redis 的滑动窗口限流器，多实例共享计数
+type RedisLimiter struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisLimiter 创建 RedisLimiter 实例，prefix 为 Redis Key 前缀
+func NewRedisLimiter(client redis.Cmdable, prefix string) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix}
+}
+
+// Allow 判断 key 的本次请求是否允许
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	if !rule.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+	now := time.Now().UnixMilli()
+	vals, err := slidingWindowScript.Run(ctx, l.client, []string{l.prefix + key},
+		now, rule.Window.Milliseconds(), rule.Limit, fmt.Sprintf("%d-%s", now, uuid.New().String())).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", vals)
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+	}, nil
+}
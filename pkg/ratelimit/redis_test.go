@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(client, "ratelimit:"), mr
+}
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	l, mr := newTestRedisLimiter(t)
+	ctx := context.Background()
+	rule := Rule{Limit: 3, Window: 100 * time.Millisecond}
+
+	for i := 0; i < rule.Limit; i++ {
+		res, err := l.Allow(ctx, "user:1", rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != rule.Limit-i-1 {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, res, rule.Limit-i-1)
+		}
+	}
+
+	res, err := l.Allow(ctx, "user:1", rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed {
+		t.Fatal("request over limit allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > rule.Window {
+		t.Fatalf("RetryAfter = %v, want within (0, %v]", res.RetryAfter, rule.Window)
+	}
+	// 被拒绝的请求不计入窗口
+	if n, _ := mr.ZMembers("ratelimit:user:1"); len(n) != rule.Limit {
+		t.Fatalf("window holds %d requests, want %d", len(n), rule.Limit)
+	}
+
+	if res, _ := l.Allow(ctx, "user:2", rule); !res.Allowed {
+		t.Fatal("request of another key rejected")
+	}
+
+	time.Sleep(rule.Window + 10*time.Millisecond)
+	if res, _ := l.Allow(ctx, "user:1", rule); !res.Allowed {
+		t.Fatal("request after window rejected")
+	}
+}
+
+func TestRedisLimiter_Unavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	mr.Close()
+
+	l := NewRedisLimiter(client, "ratelimit:")
+	if _, err := l.Allow(context.Background(), "user:1", Rule{Limit: 1, Window: time.Second}); err == nil {
+		t.Fatal("Allow() with Redis down returned no error")
+	}
+}
@@ -0,0 +1,70 @@
+package snowflake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 53 位 ID 布局 (可被 JavaScript Number 精确表示)：
+// 41 位毫秒时间戳 (自 epoch 起约 69 年) | 5 位节点 ID | 7 位序列号
+const (
+	nodeBits     = 5
+	sequenceBits = 7
+
+	MaxNodeID   = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+
+	timeShift = nodeBits + sequenceBits
+	nodeShift = sequenceBits
+)
+
+// epoch 2024-01-01 00:00:00 UTC
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Node ID 生成节点，同一节点生成的 ID 单调递增
+type Node struct {
+	mu       sync.Mutex
+	nodeID   int64
+	lastTime int64
+	sequence int64
+}
+
+// NewNode 创建 ID 生成节点，多实例部署时每个实例需使用不同的 nodeID
+func NewNode(nodeID int64) (*Node, error) {
+	if nodeID < 0 || nodeID > MaxNodeID {
+		return nil, fmt.Errorf("snowflake: node id must be between 0 and %d", MaxNodeID)
+	}
+	return &Node{nodeID: nodeID}, nil
+}
+
+// Generate 生成一个新 ID
+// 同一毫秒内序列号耗尽时等待下一毫秒；时钟回拨时沿用上次时间戳
+func (n *Node) Generate() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now().UnixMilli() - epoch
+	if now < n.lastTime {
+		now = n.lastTime
+	}
+	if now == n.lastTime {
+		n.sequence = (n.sequence + 1) & maxSequence
+		if n.sequence == 0 {
+			for now <= n.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli() - epoch
+			}
+		}
+	} else {
+		n.sequence = 0
+	}
+	n.lastTime = now
+
+	return now<<timeShift | n.nodeID<<nodeShift | n.sequence
+}
+
+// Time 返回 ID 中的生成时间
+func Time(id int64) time.Time {
+	return time.UnixMilli(id>>timeShift + epoch)
+}
@@ -0,0 +1,101 @@
+package snowflake
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewNode(t *testing.T) {
+	tests := []struct {
+		nodeID  int64
+		wantErr bool
+	}{
+		{nodeID: 0},
+		{nodeID: MaxNodeID},
+		{nodeID: -1, wantErr: true},
+		{nodeID: MaxNodeID + 1, wantErr: true},
+	}
+	for _, tc := range tests {
+		_, err := NewNode(tc.nodeID)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("NewNode(%d) error = %v, wantErr %v", tc.nodeID, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNode_Generate(t *testing.T) {
+	node, err := NewNode(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Truncate(time.Millisecond)
+	// 超过单毫秒序列号容量，覆盖序列号耗尽时等待下一毫秒
+	const n = 10 * (maxSequence + 1)
+	var last int64
+	for i := 0; i < n; i++ {
+		id := node.Generate()
+		if id <= last {
+			t.Fatalf("Generate() = %d after %d, want increasing", id, last)
+		}
+		last = id
+		if got := id >> nodeShift & MaxNodeID; got != 3 {
+			t.Fatalf("node id of %d = %d, want 3", id, got)
+		}
+	}
+	if got := Time(last); got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("Time() = %v, want between %v and now", got, before)
+	}
+	// 53 位以内，可被 JavaScript Number 精确表示
+	if last >= 1<<53 {
+		t.Fatalf("Generate() = %d, want below 2^53", last)
+	}
+}
+
+func TestNode_GenerateClockRollback(t *testing.T) {
+	node, err := NewNode(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := node.Generate()
+	// 模拟时钟回拨：上次时间戳位于未来
+	node.lastTime += 50
+	second := node.Generate()
+	if second <= first {
+		t.Fatalf("Generate() = %d after rollback, want greater than %d", second, first)
+	}
+	if second>>timeShift != node.lastTime {
+		t.Fatalf("timestamp of %d = %d, want last time %d", second, second>>timeShift, node.lastTime)
+	}
+}
+
+func TestNode_GenerateConcurrent(t *testing.T) {
+	node, err := NewNode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, perWorker = 8, 500
+	ids := make(chan int64, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				ids <- node.Generate()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int64]bool, workers*perWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}